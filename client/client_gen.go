@@ -0,0 +1,1358 @@
+// Code generated by cmd/clientgen from the OpenAPI document. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type Availability struct {
+	ProviderID   int64  `json:"provider_id"`
+	ProviderName string `json:"provider_name"`
+	Region       string `json:"region"`
+	Type         string `json:"type"`
+	URL          string `json:"url"`
+}
+
+type Erasure struct {
+	CancelledAt  *time.Time       `json:"cancelled_at,omitempty"`
+	CompletedAt  *time.Time       `json:"completed_at,omitempty"`
+	ID           int64            `json:"id"`
+	RequestedAt  time.Time        `json:"requested_at"`
+	ScheduledFor time.Time        `json:"scheduled_for"`
+	Summary      map[string]int64 `json:"summary,omitempty"`
+}
+
+type HistoryEntry struct {
+	MovieID int64 `json:"movie_id"`
+	// Seconds into the movie at the last watch, for resuming playback
+	Progress *int64 `json:"progress,omitempty"`
+	Title    string `json:"title"`
+	// How many watches were recorded
+	Views int64 `json:"views"`
+	// When the movie was last watched
+	WatchedAt time.Time `json:"watched_at"`
+}
+
+type IPRule struct {
+	CIDR      *string    `json:"cidr,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	ID        *int64     `json:"id,omitempty"`
+	List      *string    `json:"list,omitempty"`
+	Note      *string    `json:"note,omitempty"`
+}
+
+// Links: Hypermedia links keyed by relation, built from the server's route
+// table. Only present when requested with links=true.
+type Links map[string]LinksEntry
+
+type Metadata struct {
+	CurrentPage           *int64 `json:"current_page,omitempty"`
+	FirstPage             *int64 `json:"first_page,omitempty"`
+	LastPage              *int64 `json:"last_page,omitempty"`
+	PageSize              *int64 `json:"page_size,omitempty"`
+	TotalRecords          *int64 `json:"total_records,omitempty"`
+	TotalRecordsEstimated *bool  `json:"total_records_estimated,omitempty"`
+}
+
+type Movie struct {
+	Links Links `json:"_links,omitempty"`
+	// ISO 3166-1 alpha-2 codes of the only countries the movie is shown in;
+	// empty or absent for everywhere not blocked
+	AllowedRegions []string `json:"allowed_regions,omitempty"`
+	// ISO 3166-1 alpha-2 codes of countries the movie is not shown in
+	BlockedRegions []string `json:"blocked_regions,omitempty"`
+	// Whole US dollars
+	BoxOffice *int64 `json:"box_office,omitempty"`
+	// Whole US dollars
+	Budget        *int64  `json:"budget,omitempty"`
+	Certification *string `json:"certification,omitempty"`
+	// ISO 3166-1 alpha-2 country code
+	Country *string  `json:"country,omitempty"`
+	Genres  []string `json:"genres,omitempty"`
+	ID      int64    `json:"id"`
+	// ISO 639-1 language code
+	OriginalLanguage *string `json:"original_language,omitempty"`
+	ReleaseDate      *string `json:"release_date,omitempty"`
+	// Runtime in minutes, or a string such as "107 mins" when the server runs
+	// with -runtime-format=string
+	Runtime     interface{} `json:"runtime,omitempty"`
+	SeriesID    *int64      `json:"series_id,omitempty"`
+	SeriesOrder *int64      `json:"series_order,omitempty"`
+	Synopsis    *string     `json:"synopsis,omitempty"`
+	Title       string      `json:"title"`
+	Version     int64       `json:"version"`
+	Year        *int64      `json:"year,omitempty"`
+}
+
+// MovieDeletion: Rows deleted along with a movie
+type MovieDeletion struct {
+	// Where-to-watch entries
+	Availability int64 `json:"availability"`
+	// Saved search notifications, across all users
+	Notifications int64 `json:"notifications"`
+	// Watch history entries, across all users
+	WatchHistory int64 `json:"watch_history"`
+}
+
+// MovieFilter: Movie search criteria, as for GET /v1/movies. Genres must
+// all match
+type MovieFilter struct {
+	Certification    *string  `json:"certification,omitempty"`
+	Country          *string  `json:"country,omitempty"`
+	Genres           []string `json:"genres,omitempty"`
+	OriginalLanguage *string  `json:"original_language,omitempty"`
+	ReleasedAfter    *string  `json:"released_after,omitempty"`
+	ReleasedBefore   *string  `json:"released_before,omitempty"`
+	Title            *string  `json:"title,omitempty"`
+}
+
+type MovieInput struct {
+	// Whole US dollars
+	BoxOffice *int64 `json:"box_office,omitempty"`
+	// Whole US dollars
+	Budget        *int64  `json:"budget,omitempty"`
+	Certification *string `json:"certification,omitempty"`
+	// ISO 3166-1 alpha-2 country code
+	Country *string `json:"country,omitempty"`
+	// Between 1 and 5 genres by default (see -genres-min and -genres-max). Each
+	// genre is 1 to 50 letters or digits, which may be joined by spaces,
+	// hyphens, apostrophes or ampersands
+	Genres []string `json:"genres,omitempty"`
+	// ISO 639-1 language code
+	OriginalLanguage *string `json:"original_language,omitempty"`
+	// Full release date; year is filled in from it when omitted
+	ReleaseDate *string `json:"release_date,omitempty"`
+	// Runtime as a number of minutes, a string such as "107 mins" or a duration
+	// such as "1h47m"
+	Runtime interface{} `json:"runtime,omitempty"`
+	// Series the movie belongs to; 0 removes it from its series
+	SeriesID *int64 `json:"series_id,omitempty"`
+	// Position of the movie within its series
+	SeriesOrder *int64  `json:"series_order,omitempty"`
+	Synopsis    *string `json:"synopsis,omitempty"`
+	Title       *string `json:"title,omitempty"`
+	// Update only: the version the client last read. If the movie has changed
+	// since, the update is refused with 409
+	Version *int64 `json:"version,omitempty"`
+	Year    *int64 `json:"year,omitempty"`
+}
+
+type Notification struct {
+	CreatedAt     time.Time `json:"created_at"`
+	ID            int64     `json:"id"`
+	MovieID       int64     `json:"movie_id"`
+	SavedSearchID int64     `json:"saved_search_id"`
+	Title         string    `json:"title"`
+}
+
+type Operation struct {
+	CreatedAt *time.Time       `json:"created_at,omitempty"`
+	Errors    []string         `json:"errors,omitempty"`
+	ID        *int64           `json:"id,omitempty"`
+	Kind      *string          `json:"kind,omitempty"`
+	Progress  *int64           `json:"progress,omitempty"`
+	Result    *OperationResult `json:"result,omitempty"`
+	Status    *string          `json:"status,omitempty"`
+	UpdatedAt *time.Time       `json:"updated_at,omitempty"`
+}
+
+type Provider struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Version int64  `json:"version"`
+}
+
+type SavedSearch struct {
+	CreatedAt time.Time   `json:"created_at"`
+	Filter    MovieFilter `json:"filter"`
+	ID        int64       `json:"id"`
+	Name      string      `json:"name"`
+	// Channels through which new matching movies are notified
+	Notify []string `json:"notify"`
+}
+
+type Series struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Version int64  `json:"version"`
+}
+
+type SyncCursor struct {
+	// Cursor of the last batch applied, or 0
+	Cursor int64 `json:"cursor"`
+	// partner: followed by the signing key ID
+	Source    string    `json:"source"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SyncMovie struct {
+	// Whole US dollars
+	BoxOffice *int64 `json:"box_office,omitempty"`
+	// Whole US dollars
+	Budget        *int64  `json:"budget,omitempty"`
+	Certification *string `json:"certification,omitempty"`
+	// ISO 3166-1 alpha-2 country code
+	Country *string `json:"country,omitempty"`
+	// Between 1 and 5 genres by default (see -genres-min and -genres-max). Each
+	// genre is 1 to 50 letters or digits, which may be joined by spaces,
+	// hyphens, apostrophes or ampersands
+	Genres []string `json:"genres"`
+	// ISO 639-1 language code
+	OriginalLanguage *string `json:"original_language,omitempty"`
+	// Full release date; year is filled in from it when omitted
+	ReleaseDate *string `json:"release_date,omitempty"`
+	// Runtime as a number of minutes, a string such as "107 mins" or a duration
+	// such as "1h47m"
+	Runtime  interface{} `json:"runtime"`
+	Synopsis *string     `json:"synopsis,omitempty"`
+	Title    string      `json:"title"`
+	Year     *int64      `json:"year,omitempty"`
+}
+
+type SyncResult struct {
+	// False when the batch's cursor was not past the source's cursor, meaning
+	// it had already been applied and was skipped
+	Applied bool  `json:"applied"`
+	Created int64 `json:"created"`
+	// The source's cursor after the batch
+	Cursor  int64 `json:"cursor"`
+	Deleted int64 `json:"deleted"`
+	// Upserts that matched the stored movie and deletes of movies that did not
+	// exist
+	Unchanged int64 `json:"unchanged"`
+	Updated   int64 `json:"updated"`
+}
+
+type Token struct {
+	Expiry time.Time `json:"expiry"`
+	Token  string    `json:"token"`
+}
+
+type Usage struct {
+	Daily []UsageDaily `json:"daily"`
+	// YYYY-MM
+	Month string `json:"month"`
+	// Monthly request quota, when the server has one
+	Quota *int64 `json:"quota,omitempty"`
+	// Requests made in the month, across all clients
+	Requests int64 `json:"requests"`
+	// When the quota resets, when the server has one
+	ResetsAt *time.Time `json:"resets_at,omitempty"`
+}
+
+type User struct {
+	Activated bool `json:"activated"`
+	// Whether the user allows anonymised analytics of their searches and views;
+	// absent until they choose
+	AnalyticsConsent *bool     `json:"analytics_consent,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	Email            string    `json:"email"`
+	ID               int64     `json:"id"`
+	Name             string    `json:"name"`
+	Version          int64     `json:"version"`
+}
+
+type Watch struct {
+	ID      int64 `json:"id"`
+	MovieID int64 `json:"movie_id"`
+	// Seconds into the movie, for resuming playback
+	Progress  *int64    `json:"progress,omitempty"`
+	WatchedAt time.Time `json:"watched_at"`
+}
+
+type WriteFreeze struct {
+	CreatedAt time.Time `json:"created_at"`
+	// Sent to clients with the 503 response; empty for the default message
+	Message string `json:"message"`
+	// Name of the frozen route, such as movies.create
+	Route string `json:"route"`
+}
+
+type LinksEntry struct {
+	Href string `json:"href"`
+	// Omitted for GET
+	Method *string `json:"method,omitempty"`
+}
+
+type OperationResult struct {
+	Expiry   *time.Time `json:"expiry,omitempty"`
+	Failed   *int64     `json:"failed,omitempty"`
+	Imported *int64     `json:"imported,omitempty"`
+}
+
+type UsageDaily struct {
+	// The credential used: token:<hash prefix> or partner:<key id>
+	Client   *string `json:"client,omitempty"`
+	Day      *string `json:"day,omitempty"`
+	Requests *int64  `json:"requests,omitempty"`
+}
+
+// ActivateUser calls PUT /v1/users/activated: activate a user account. It
+// succeeds with 200.
+func (c *Client) ActivateUser(ctx context.Context, body ActivateUserRequest) (*ActivateUserResponse, error) {
+	path := "/v1/users/activated"
+	var out ActivateUserResponse
+	err := c.do(ctx, "PUT", path, nil, body, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type ActivateUserRequest struct {
+	Token string `json:"token"`
+}
+
+type ActivateUserResponse struct {
+	User User `json:"user"`
+}
+
+// BulkUpdateMoviesParams are the query parameters of BulkUpdateMovies.
+type BulkUpdateMoviesParams struct {
+	DryRun *bool
+}
+
+// BulkUpdateMovies calls PATCH /v1/movies: apply changes to every movie
+// matching a filter. It succeeds with 200.
+func (c *Client) BulkUpdateMovies(ctx context.Context, params BulkUpdateMoviesParams, body BulkUpdateMoviesRequest) (*BulkUpdateMoviesResponse, error) {
+	path := "/v1/movies"
+	query := url.Values{}
+	setQuery(query, "dry_run", params.DryRun)
+	var out BulkUpdateMoviesResponse
+	err := c.do(ctx, "PATCH", path, query, body, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type BulkUpdateMoviesRequest struct {
+	Changes BulkUpdateMoviesRequestChanges `json:"changes"`
+	// The same as the dry_run query parameter
+	DryRun *bool                         `json:"dry_run,omitempty"`
+	Filter BulkUpdateMoviesRequestFilter `json:"filter"`
+}
+
+type BulkUpdateMoviesResponse struct {
+	Affected int64 `json:"affected"`
+	DryRun   bool  `json:"dry_run"`
+}
+
+type BulkUpdateMoviesRequestChanges struct {
+	AddGenre         *string                                    `json:"add_genre,omitempty"`
+	Certification    *string                                    `json:"certification,omitempty"`
+	Country          *string                                    `json:"country,omitempty"`
+	OriginalLanguage *string                                    `json:"original_language,omitempty"`
+	RemoveGenre      *string                                    `json:"remove_genre,omitempty"`
+	RenameGenre      *BulkUpdateMoviesRequestChangesRenameGenre `json:"rename_genre,omitempty"`
+	RuntimeDelta     *int64                                     `json:"runtime_delta,omitempty"`
+}
+
+type BulkUpdateMoviesRequestFilter struct {
+	Certification    *string  `json:"certification,omitempty"`
+	Country          *string  `json:"country,omitempty"`
+	Genres           []string `json:"genres,omitempty"`
+	OriginalLanguage *string  `json:"original_language,omitempty"`
+	ReleasedAfter    *string  `json:"released_after,omitempty"`
+	ReleasedBefore   *string  `json:"released_before,omitempty"`
+	Title            *string  `json:"title,omitempty"`
+}
+
+type BulkUpdateMoviesRequestChangesRenameGenre struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// CancelUserErasure calls DELETE /v1/me/deletion: cancel a pending account
+// deletion. It succeeds with 200.
+func (c *Client) CancelUserErasure(ctx context.Context) (*CancelUserErasureResponse, error) {
+	path := "/v1/me/deletion"
+	var out CancelUserErasureResponse
+	err := c.do(ctx, "DELETE", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type CancelUserErasureResponse struct {
+	Message string `json:"message"`
+}
+
+// CreateAuthenticationToken calls PUT /v1/tokens/authentication: create an
+// authentication token. It succeeds with 201.
+func (c *Client) CreateAuthenticationToken(ctx context.Context, body CreateAuthenticationTokenRequest) (*CreateAuthenticationTokenResponse, error) {
+	path := "/v1/tokens/authentication"
+	var out CreateAuthenticationTokenResponse
+	err := c.do(ctx, "PUT", path, nil, body, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type CreateAuthenticationTokenRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type CreateAuthenticationTokenResponse struct {
+	AuthenticationToken Token `json:"authentication_token"`
+}
+
+// CreateIPRule calls POST /v1/admin/ip-rules: add an address or CIDR block
+// to the admin allowlist or denylist. It succeeds with 201.
+func (c *Client) CreateIPRule(ctx context.Context, body CreateIPRuleRequest) (*CreateIPRuleResponse, error) {
+	path := "/v1/admin/ip-rules"
+	var out CreateIPRuleResponse
+	err := c.do(ctx, "POST", path, nil, body, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type CreateIPRuleRequest struct {
+	CIDR string  `json:"cidr"`
+	List string  `json:"list"`
+	Note *string `json:"note,omitempty"`
+}
+
+type CreateIPRuleResponse struct {
+	IPRule IPRule `json:"ip_rule"`
+}
+
+// CreateMovieParams are the query parameters of CreateMovie.
+type CreateMovieParams struct {
+	Links *bool
+}
+
+// CreateMovie calls POST /v1/movies: create a movie. It succeeds with 200.
+func (c *Client) CreateMovie(ctx context.Context, params CreateMovieParams, body CreateMovieRequest) (*CreateMovieResponse, error) {
+	path := "/v1/movies"
+	query := url.Values{}
+	setQuery(query, "links", params.Links)
+	var out CreateMovieResponse
+	err := c.do(ctx, "POST", path, query, body, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type CreateMovieRequest struct {
+	// Whole US dollars
+	BoxOffice *int64 `json:"box_office,omitempty"`
+	// Whole US dollars
+	Budget        *int64  `json:"budget,omitempty"`
+	Certification *string `json:"certification,omitempty"`
+	// ISO 3166-1 alpha-2 country code
+	Country *string  `json:"country,omitempty"`
+	Genres  []string `json:"genres"`
+	// ISO 639-1 language code
+	OriginalLanguage *string `json:"original_language,omitempty"`
+	// Full release date; year is filled in from it when omitted
+	ReleaseDate *string `json:"release_date,omitempty"`
+	// Runtime as a number of minutes, a string such as "107 mins" or a duration
+	// such as "1h47m"
+	Runtime interface{} `json:"runtime"`
+	// Series the movie belongs to; 0 removes it from its series
+	SeriesID *int64 `json:"series_id,omitempty"`
+	// Position of the movie within its series
+	SeriesOrder *int64  `json:"series_order,omitempty"`
+	Synopsis    *string `json:"synopsis,omitempty"`
+	Title       string  `json:"title"`
+	Year        *int64  `json:"year,omitempty"`
+}
+
+type CreateMovieResponse struct {
+	Movie Movie `json:"movie"`
+}
+
+// CreateProvider calls POST /v1/providers: create a streaming provider. It
+// succeeds with 201.
+func (c *Client) CreateProvider(ctx context.Context, body CreateProviderRequest) (*CreateProviderResponse, error) {
+	path := "/v1/providers"
+	var out CreateProviderResponse
+	err := c.do(ctx, "POST", path, nil, body, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type CreateProviderRequest struct {
+	Name string `json:"name"`
+}
+
+type CreateProviderResponse struct {
+	Provider Provider `json:"provider"`
+}
+
+// CreateSavedSearch calls POST /v1/me/searches: save a movie search. It
+// succeeds with 201.
+func (c *Client) CreateSavedSearch(ctx context.Context, body CreateSavedSearchRequest) (*CreateSavedSearchResponse, error) {
+	path := "/v1/me/searches"
+	var out CreateSavedSearchResponse
+	err := c.do(ctx, "POST", path, nil, body, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type CreateSavedSearchRequest struct {
+	Filter MovieFilter `json:"filter"`
+	Name   string      `json:"name"`
+	Notify []string    `json:"notify,omitempty"`
+}
+
+type CreateSavedSearchResponse struct {
+	SavedSearch SavedSearch `json:"saved_search"`
+}
+
+// CreateSeries calls POST /v1/series: create a movie series. It succeeds
+// with 201.
+func (c *Client) CreateSeries(ctx context.Context, body CreateSeriesRequest) (*CreateSeriesResponse, error) {
+	path := "/v1/series"
+	var out CreateSeriesResponse
+	err := c.do(ctx, "POST", path, nil, body, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type CreateSeriesRequest struct {
+	Name string `json:"name"`
+}
+
+type CreateSeriesResponse struct {
+	Series Series `json:"series"`
+}
+
+// DeleteIPRule calls DELETE /v1/admin/ip-rules/{id}: remove an IP rule. It
+// succeeds with 200.
+func (c *Client) DeleteIPRule(ctx context.Context, id int64) (*DeleteIPRuleResponse, error) {
+	path := "/v1/admin/ip-rules/" + strconv.FormatInt(id, 10)
+	var out DeleteIPRuleResponse
+	err := c.do(ctx, "DELETE", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type DeleteIPRuleResponse struct {
+	Message string `json:"message"`
+}
+
+// DeleteMovieParams are the query parameters of DeleteMovie.
+type DeleteMovieParams struct {
+	DryRun *bool
+}
+
+// DeleteMovie calls DELETE /v1/movies/{id}: delete a movie. It succeeds
+// with 200.
+func (c *Client) DeleteMovie(ctx context.Context, id int64, params DeleteMovieParams) (*DeleteMovieResponse, error) {
+	path := "/v1/movies/" + strconv.FormatInt(id, 10)
+	query := url.Values{}
+	setQuery(query, "dry_run", params.DryRun)
+	var out DeleteMovieResponse
+	err := c.do(ctx, "DELETE", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type DeleteMovieResponse struct {
+	Deleted MovieDeletion `json:"deleted"`
+	DryRun  bool          `json:"dry_run"`
+	Message string        `json:"message"`
+}
+
+// DeleteMovieAvailabilityParams are the query parameters of DeleteMovieAvailability.
+type DeleteMovieAvailabilityParams struct {
+	ProviderID int64
+	Region     string
+	Type       string
+}
+
+// DeleteMovieAvailability calls DELETE /v1/movies/{id}/availability: remove
+// an availability entry from a movie. It succeeds with 200.
+func (c *Client) DeleteMovieAvailability(ctx context.Context, id int64, params DeleteMovieAvailabilityParams) (*DeleteMovieAvailabilityResponse, error) {
+	path := "/v1/movies/" + strconv.FormatInt(id, 10) + "/availability"
+	query := url.Values{}
+	setQuery(query, "provider_id", params.ProviderID)
+	setQuery(query, "region", params.Region)
+	setQuery(query, "type", params.Type)
+	var out DeleteMovieAvailabilityResponse
+	err := c.do(ctx, "DELETE", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type DeleteMovieAvailabilityResponse struct {
+	Message string `json:"message"`
+}
+
+// DeleteNotification calls DELETE /v1/me/notifications/{id}: dismiss a
+// notification. It succeeds with 200.
+func (c *Client) DeleteNotification(ctx context.Context, id int64) (*DeleteNotificationResponse, error) {
+	path := "/v1/me/notifications/" + strconv.FormatInt(id, 10)
+	var out DeleteNotificationResponse
+	err := c.do(ctx, "DELETE", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type DeleteNotificationResponse struct {
+	Message string `json:"message"`
+}
+
+// DeleteSavedSearch calls DELETE /v1/me/searches/{id}: delete one of your
+// saved searches and its notifications. It succeeds with 200.
+func (c *Client) DeleteSavedSearch(ctx context.Context, id int64) (*DeleteSavedSearchResponse, error) {
+	path := "/v1/me/searches/" + strconv.FormatInt(id, 10)
+	var out DeleteSavedSearchResponse
+	err := c.do(ctx, "DELETE", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type DeleteSavedSearchResponse struct {
+	Message string `json:"message"`
+}
+
+// DeleteWriteFreeze calls DELETE /v1/admin/freezes/{route}: lift a write
+// freeze. It succeeds with 200.
+func (c *Client) DeleteWriteFreeze(ctx context.Context, route string) (*DeleteWriteFreezeResponse, error) {
+	path := "/v1/admin/freezes/" + url.PathEscape(route)
+	var out DeleteWriteFreezeResponse
+	err := c.do(ctx, "DELETE", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type DeleteWriteFreezeResponse struct {
+	Message string `json:"message"`
+}
+
+// DownloadUserExportParams are the query parameters of DownloadUserExport.
+type DownloadUserExportParams struct {
+	Token string
+}
+
+// DownloadUserExport calls GET /v1/me/export: download a data export using
+// the emailed token. It succeeds with 200.
+// The caller must close the returned body.
+func (c *Client) DownloadUserExport(ctx context.Context, params DownloadUserExportParams) (io.ReadCloser, error) {
+	path := "/v1/me/export"
+	query := url.Values{}
+	setQuery(query, "token", params.Token)
+	resp, err := c.send(ctx, "GET", path, query, "application/zip", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Healthcheck calls GET /v1/healthcheck: report service status. It succeeds
+// with 200.
+func (c *Client) Healthcheck(ctx context.Context) (*HealthcheckResponse, error) {
+	path := "/v1/healthcheck"
+	var out HealthcheckResponse
+	err := c.do(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type HealthcheckResponse struct {
+	Status     string                        `json:"status"`
+	SystemInfo HealthcheckResponseSystemInfo `json:"system_info"`
+}
+
+type HealthcheckResponseSystemInfo struct {
+	Environment *string `json:"environment,omitempty"`
+	Version     *string `json:"version,omitempty"`
+}
+
+// ImportMovies calls POST /v1/movies/import: import movies from a CSV file
+// in the background. It succeeds with 202.
+func (c *Client) ImportMovies(ctx context.Context, body io.Reader) (*ImportMoviesResponse, error) {
+	path := "/v1/movies/import"
+	resp, err := c.send(ctx, "POST", path, nil, "application/json", "text/csv", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out ImportMoviesResponse
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type ImportMoviesResponse struct {
+	Operation Operation `json:"operation"`
+}
+
+// ListHistoryParams are the query parameters of ListHistory.
+type ListHistoryParams struct {
+	Page     *int64
+	PageSize *int64
+}
+
+// ListHistory calls GET /v1/me/history: list the movies you have watched,
+// most recent first, one entry per movie. It succeeds with 200.
+func (c *Client) ListHistory(ctx context.Context, params ListHistoryParams) (*ListHistoryResponse, error) {
+	path := "/v1/me/history"
+	query := url.Values{}
+	setQuery(query, "page", params.Page)
+	setQuery(query, "page_size", params.PageSize)
+	var out ListHistoryResponse
+	err := c.do(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListHistoryAll calls ListHistory for every page, starting from params.Page, and
+// returns the history from all of them.
+func (c *Client) ListHistoryAll(ctx context.Context, params ListHistoryParams) ([]HistoryEntry, error) {
+	var all []HistoryEntry
+	for {
+		resp, err := c.ListHistory(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, resp.History...)
+
+		next := nextPage(resp.Metadata, len(resp.History))
+		if next == 0 {
+			return all, nil
+		}
+		params.Page = &next
+	}
+}
+
+type ListHistoryResponse struct {
+	History  []HistoryEntry `json:"history"`
+	Metadata Metadata       `json:"metadata"`
+}
+
+// ListIPRules calls GET /v1/admin/ip-rules: list the admin allowlist and
+// denylist. It succeeds with 200.
+func (c *Client) ListIPRules(ctx context.Context) (*ListIPRulesResponse, error) {
+	path := "/v1/admin/ip-rules"
+	var out ListIPRulesResponse
+	err := c.do(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type ListIPRulesResponse struct {
+	IPRules []IPRule `json:"ip_rules"`
+}
+
+// ListMoviesParams are the query parameters of ListMovies.
+type ListMoviesParams struct {
+	Title            *string
+	Genres           *string
+	ReleasedAfter    *string
+	ReleasedBefore   *string
+	OriginalLanguage *string
+	Country          *string
+	Certification    *string
+	Page             *int64
+	PageSize         *int64
+	Sort             *string
+	Count            *string
+	Links            *bool
+}
+
+// ListMovies calls GET /v1/movies: list movies. It succeeds with 200.
+func (c *Client) ListMovies(ctx context.Context, params ListMoviesParams) (*ListMoviesResponse, error) {
+	path := "/v1/movies"
+	query := url.Values{}
+	setQuery(query, "title", params.Title)
+	setQuery(query, "genres", params.Genres)
+	setQuery(query, "released_after", params.ReleasedAfter)
+	setQuery(query, "released_before", params.ReleasedBefore)
+	setQuery(query, "original_language", params.OriginalLanguage)
+	setQuery(query, "country", params.Country)
+	setQuery(query, "certification", params.Certification)
+	setQuery(query, "page", params.Page)
+	setQuery(query, "page_size", params.PageSize)
+	setQuery(query, "sort", params.Sort)
+	setQuery(query, "count", params.Count)
+	setQuery(query, "links", params.Links)
+	var out ListMoviesResponse
+	err := c.do(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListMoviesAll calls ListMovies for every page, starting from params.Page, and
+// returns the movies from all of them.
+func (c *Client) ListMoviesAll(ctx context.Context, params ListMoviesParams) ([]Movie, error) {
+	var all []Movie
+	for {
+		resp, err := c.ListMovies(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, resp.Movies...)
+
+		next := nextPage(resp.Metadata, len(resp.Movies))
+		if next == 0 {
+			return all, nil
+		}
+		params.Page = &next
+	}
+}
+
+type ListMoviesResponse struct {
+	Links    Links    `json:"_links,omitempty"`
+	Metadata Metadata `json:"metadata"`
+	Movies   []Movie  `json:"movies"`
+}
+
+// ListNotificationsParams are the query parameters of ListNotifications.
+type ListNotificationsParams struct {
+	Page     *int64
+	PageSize *int64
+}
+
+// ListNotifications calls GET /v1/me/notifications: list notifications of
+// new movies matching your saved searches, newest first. It succeeds with
+// 200.
+func (c *Client) ListNotifications(ctx context.Context, params ListNotificationsParams) (*ListNotificationsResponse, error) {
+	path := "/v1/me/notifications"
+	query := url.Values{}
+	setQuery(query, "page", params.Page)
+	setQuery(query, "page_size", params.PageSize)
+	var out ListNotificationsResponse
+	err := c.do(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListNotificationsAll calls ListNotifications for every page, starting from params.Page, and
+// returns the notifications from all of them.
+func (c *Client) ListNotificationsAll(ctx context.Context, params ListNotificationsParams) ([]Notification, error) {
+	var all []Notification
+	for {
+		resp, err := c.ListNotifications(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, resp.Notifications...)
+
+		next := nextPage(resp.Metadata, len(resp.Notifications))
+		if next == 0 {
+			return all, nil
+		}
+		params.Page = &next
+	}
+}
+
+type ListNotificationsResponse struct {
+	Metadata      Metadata       `json:"metadata"`
+	Notifications []Notification `json:"notifications"`
+}
+
+// ListProviders calls GET /v1/providers: list streaming providers. It
+// succeeds with 200.
+func (c *Client) ListProviders(ctx context.Context) (*ListProvidersResponse, error) {
+	path := "/v1/providers"
+	var out ListProvidersResponse
+	err := c.do(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type ListProvidersResponse struct {
+	Providers []Provider `json:"providers"`
+}
+
+// ListRelatedMoviesParams are the query parameters of ListRelatedMovies.
+type ListRelatedMoviesParams struct {
+	Page           *int64
+	PageSize       *int64
+	IncludeWatched *bool
+	Links          *bool
+}
+
+// ListRelatedMovies calls GET /v1/movies/{id}/related: list movies similar
+// to a movie, leaving out ones you have watched. It succeeds with 200.
+func (c *Client) ListRelatedMovies(ctx context.Context, id int64, params ListRelatedMoviesParams) (*ListRelatedMoviesResponse, error) {
+	path := "/v1/movies/" + strconv.FormatInt(id, 10) + "/related"
+	query := url.Values{}
+	setQuery(query, "page", params.Page)
+	setQuery(query, "page_size", params.PageSize)
+	setQuery(query, "include_watched", params.IncludeWatched)
+	setQuery(query, "links", params.Links)
+	var out ListRelatedMoviesResponse
+	err := c.do(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListRelatedMoviesAll calls ListRelatedMovies for every page, starting from params.Page, and
+// returns the movies from all of them.
+func (c *Client) ListRelatedMoviesAll(ctx context.Context, id int64, params ListRelatedMoviesParams) ([]Movie, error) {
+	var all []Movie
+	for {
+		resp, err := c.ListRelatedMovies(ctx, id, params)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, resp.Movies...)
+
+		next := nextPage(resp.Metadata, len(resp.Movies))
+		if next == 0 {
+			return all, nil
+		}
+		params.Page = &next
+	}
+}
+
+type ListRelatedMoviesResponse struct {
+	Links    Links    `json:"_links,omitempty"`
+	Metadata Metadata `json:"metadata"`
+	Movies   []Movie  `json:"movies"`
+}
+
+// ListSavedSearches calls GET /v1/me/searches: list your saved searches. It
+// succeeds with 200.
+func (c *Client) ListSavedSearches(ctx context.Context) (*ListSavedSearchesResponse, error) {
+	path := "/v1/me/searches"
+	var out ListSavedSearchesResponse
+	err := c.do(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type ListSavedSearchesResponse struct {
+	SavedSearches []SavedSearch `json:"saved_searches"`
+}
+
+// ListWriteFreezes calls GET /v1/admin/freezes: list the write endpoints
+// that are read-only. It succeeds with 200.
+func (c *Client) ListWriteFreezes(ctx context.Context) (*ListWriteFreezesResponse, error) {
+	path := "/v1/admin/freezes"
+	var out ListWriteFreezesResponse
+	err := c.do(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type ListWriteFreezesResponse struct {
+	WriteFreezes []WriteFreeze `json:"write_freezes"`
+}
+
+// MovieFeed calls GET /v1/movies/feed.atom: atom feed of the 50 most
+// recently added movies. Regenerated on a schedule (-feed-refresh), so new
+// movies can take a while to appear. Public with -public-read. It succeeds
+// with 200.
+// The caller must close the returned body.
+func (c *Client) MovieFeed(ctx context.Context) (io.ReadCloser, error) {
+	path := "/v1/movies/feed.atom"
+	resp, err := c.send(ctx, "GET", path, nil, "application/atom+xml", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// RecordWatch calls POST /v1/me/history: record that you watched a movie,
+// optionally with how far you got. It succeeds with 201.
+func (c *Client) RecordWatch(ctx context.Context, body RecordWatchRequest) (*RecordWatchResponse, error) {
+	path := "/v1/me/history"
+	var out RecordWatchResponse
+	err := c.do(ctx, "POST", path, nil, body, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type RecordWatchRequest struct {
+	MovieID int64 `json:"movie_id"`
+	// Seconds into the movie; at most its runtime
+	Progress *int64 `json:"progress,omitempty"`
+	// Defaults to now
+	WatchedAt *time.Time `json:"watched_at,omitempty"`
+}
+
+type RecordWatchResponse struct {
+	Watch Watch `json:"watch"`
+}
+
+// RegisterUser calls POST /v1/users: register a user account. It succeeds
+// with 202.
+func (c *Client) RegisterUser(ctx context.Context, body RegisterUserRequest) (*RegisterUserResponse, error) {
+	path := "/v1/users"
+	var out RegisterUserResponse
+	err := c.do(ctx, "POST", path, nil, body, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type RegisterUserRequest struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type RegisterUserResponse struct {
+	User User `json:"user"`
+}
+
+// RequestUserErasure calls POST /v1/me/deletion: schedule deletion of your
+// account and personal data. It succeeds with 202.
+func (c *Client) RequestUserErasure(ctx context.Context) (*RequestUserErasureResponse, error) {
+	path := "/v1/me/deletion"
+	var out RequestUserErasureResponse
+	err := c.do(ctx, "POST", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type RequestUserErasureResponse struct {
+	Erasure Erasure `json:"erasure"`
+}
+
+// RequestUserExport calls POST /v1/me/export: request an export of your
+// data. It succeeds with 202.
+func (c *Client) RequestUserExport(ctx context.Context) (*RequestUserExportResponse, error) {
+	path := "/v1/me/export"
+	var out RequestUserExportResponse
+	err := c.do(ctx, "POST", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type RequestUserExportResponse struct {
+	Message   string    `json:"message"`
+	Operation Operation `json:"operation"`
+}
+
+// SetMovieAvailability calls PUT /v1/movies/{id}/availability: add or
+// update where a movie is available. It succeeds with 200.
+func (c *Client) SetMovieAvailability(ctx context.Context, id int64, body SetMovieAvailabilityRequest) (*SetMovieAvailabilityResponse, error) {
+	path := "/v1/movies/" + strconv.FormatInt(id, 10) + "/availability"
+	var out SetMovieAvailabilityResponse
+	err := c.do(ctx, "PUT", path, nil, body, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type SetMovieAvailabilityRequest struct {
+	ProviderID int64 `json:"provider_id"`
+	// ISO 3166-1 alpha-2 code
+	Region string `json:"region"`
+	Type   string `json:"type"`
+	URL    string `json:"url"`
+}
+
+type SetMovieAvailabilityResponse struct {
+	Availability Availability `json:"availability"`
+}
+
+// SetMovieRegions calls PUT /v1/movies/{id}/regions: restrict the countries
+// a movie is shown in. It succeeds with 200.
+func (c *Client) SetMovieRegions(ctx context.Context, id int64, body SetMovieRegionsRequest) (*SetMovieRegionsResponse, error) {
+	path := "/v1/movies/" + strconv.FormatInt(id, 10) + "/regions"
+	var out SetMovieRegionsResponse
+	err := c.do(ctx, "PUT", path, nil, body, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type SetMovieRegionsRequest struct {
+	AllowedRegions []string `json:"allowed_regions,omitempty"`
+	BlockedRegions []string `json:"blocked_regions,omitempty"`
+	// The version last read; the update fails with 409 if the movie has changed
+	// since
+	Version *int64 `json:"version,omitempty"`
+}
+
+type SetMovieRegionsResponse struct {
+	Movie Movie `json:"movie"`
+}
+
+// SetWriteFreeze calls PUT /v1/admin/freezes/{route}: make a write endpoint
+// read-only, or change the message of an existing freeze. It succeeds with
+// 200.
+func (c *Client) SetWriteFreeze(ctx context.Context, route string, body SetWriteFreezeRequest) (*SetWriteFreezeResponse, error) {
+	path := "/v1/admin/freezes/" + url.PathEscape(route)
+	var out SetWriteFreezeResponse
+	err := c.do(ctx, "PUT", path, nil, body, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type SetWriteFreezeRequest struct {
+	Message *string `json:"message,omitempty"`
+}
+
+type SetWriteFreezeResponse struct {
+	WriteFreeze WriteFreeze `json:"write_freeze"`
+}
+
+// ShowCurrentUser calls GET /v1/me: show your user profile. It succeeds
+// with 200.
+func (c *Client) ShowCurrentUser(ctx context.Context) (*ShowCurrentUserResponse, error) {
+	path := "/v1/me"
+	var out ShowCurrentUserResponse
+	err := c.do(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type ShowCurrentUserResponse struct {
+	User User `json:"user"`
+}
+
+// ShowMovieParams are the query parameters of ShowMovie.
+type ShowMovieParams struct {
+	Include *string
+	Region  *string
+	Links   *bool
+}
+
+// ShowMovie calls GET /v1/movies/{id}: show a movie. It succeeds with 200.
+func (c *Client) ShowMovie(ctx context.Context, id int64, params ShowMovieParams) (*ShowMovieResponse, error) {
+	path := "/v1/movies/" + strconv.FormatInt(id, 10)
+	query := url.Values{}
+	setQuery(query, "include", params.Include)
+	setQuery(query, "region", params.Region)
+	setQuery(query, "links", params.Links)
+	var out ShowMovieResponse
+	err := c.do(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type ShowMovieResponse struct {
+	Availability []Availability `json:"availability,omitempty"`
+	Movie        Movie          `json:"movie"`
+	Series       *Series        `json:"series,omitempty"`
+}
+
+// ShowOperation calls GET /v1/operations/{id}: show the status of a
+// long-running operation you started. It succeeds with 200.
+func (c *Client) ShowOperation(ctx context.Context, id int64) (*ShowOperationResponse, error) {
+	path := "/v1/operations/" + strconv.FormatInt(id, 10)
+	var out ShowOperationResponse
+	err := c.do(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type ShowOperationResponse struct {
+	Operation Operation `json:"operation"`
+}
+
+// ShowSeries calls GET /v1/series/{id}: show a series and its movies in
+// order. It succeeds with 200.
+func (c *Client) ShowSeries(ctx context.Context, id int64) (*ShowSeriesResponse, error) {
+	path := "/v1/series/" + strconv.FormatInt(id, 10)
+	var out ShowSeriesResponse
+	err := c.do(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type ShowSeriesResponse struct {
+	Movies []Movie `json:"movies"`
+	Series Series  `json:"series"`
+}
+
+// ShowSyncCursor calls GET /v1/sync/movies: show the calling partner's
+// catalogue sync cursor. It succeeds with 200.
+func (c *Client) ShowSyncCursor(ctx context.Context) (*ShowSyncCursorResponse, error) {
+	path := "/v1/sync/movies"
+	var out ShowSyncCursorResponse
+	err := c.do(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type ShowSyncCursorResponse struct {
+	Sync SyncCursor `json:"sync"`
+}
+
+// ShowUsageParams are the query parameters of ShowUsage.
+type ShowUsageParams struct {
+	Month *string
+}
+
+// ShowUsage calls GET /v1/me/usage: show your request counts per client and
+// day for a month. Counts are written in batches and can lag by a few
+// seconds. When the server has a monthly quota, every authenticated
+// response carries X-Quota-Limit, X-Quota-Remaining and X-Quota-Reset (Unix
+// time) headers, and requests over the quota get 429 with Retry-After; this
+// endpoint stays available. It succeeds with 200.
+func (c *Client) ShowUsage(ctx context.Context, params ShowUsageParams) (*ShowUsageResponse, error) {
+	path := "/v1/me/usage"
+	query := url.Values{}
+	setQuery(query, "month", params.Month)
+	var out ShowUsageResponse
+	err := c.do(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type ShowUsageResponse struct {
+	Usage Usage `json:"usage"`
+}
+
+// Sitemap calls GET /sitemap.xml: sitemap listing the detail URL of up to
+// 50,000 movies, most recently updated first. Regenerated on a schedule
+// (-feed-refresh). Public with -public-read. It succeeds with 200.
+// The caller must close the returned body.
+func (c *Client) Sitemap(ctx context.Context) (io.ReadCloser, error) {
+	path := "/sitemap.xml"
+	resp, err := c.send(ctx, "GET", path, nil, "application/xml", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// SyncMovies calls POST /v1/sync/movies: apply a batch of movie changes
+// from the partner's catalogue. It succeeds with 200.
+func (c *Client) SyncMovies(ctx context.Context, body SyncMoviesRequest) (*SyncMoviesResponse, error) {
+	path := "/v1/sync/movies"
+	var out SyncMoviesResponse
+	err := c.do(ctx, "POST", path, nil, body, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type SyncMoviesRequest struct {
+	Changes []SyncMoviesRequestChanges `json:"changes"`
+	// The upstream's position after this batch
+	Cursor int64 `json:"cursor"`
+}
+
+type SyncMoviesResponse struct {
+	Sync SyncResult `json:"sync"`
+}
+
+type SyncMoviesRequestChanges struct {
+	ExternalID string     `json:"external_id"`
+	Movie      *SyncMovie `json:"movie,omitempty"`
+	Op         string     `json:"op"`
+}
+
+// UpdateCurrentUser calls PATCH /v1/me: update your user profile. It
+// succeeds with 200.
+func (c *Client) UpdateCurrentUser(ctx context.Context, body UpdateCurrentUserRequest) (*UpdateCurrentUserResponse, error) {
+	path := "/v1/me"
+	var out UpdateCurrentUserResponse
+	err := c.do(ctx, "PATCH", path, nil, body, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type UpdateCurrentUserRequest struct {
+	// Opt in to (true) or out of (false) anonymised analytics
+	AnalyticsConsent *bool   `json:"analytics_consent,omitempty"`
+	Name             *string `json:"name,omitempty"`
+	// The version you last read. If your profile has changed since, the update
+	// is refused with 409
+	Version *int64 `json:"version,omitempty"`
+}
+
+type UpdateCurrentUserResponse struct {
+	User User `json:"user"`
+}
+
+// UpdateMovieParams are the query parameters of UpdateMovie.
+type UpdateMovieParams struct {
+	Links *bool
+}
+
+// UpdateMovie calls PATCH /v1/movies/{id}: partially update a movie. It
+// succeeds with 200.
+func (c *Client) UpdateMovie(ctx context.Context, id int64, params UpdateMovieParams, body MovieInput) (*UpdateMovieResponse, error) {
+	path := "/v1/movies/" + strconv.FormatInt(id, 10)
+	query := url.Values{}
+	setQuery(query, "links", params.Links)
+	var out UpdateMovieResponse
+	err := c.do(ctx, "PATCH", path, query, body, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type UpdateMovieResponse struct {
+	Movie Movie `json:"movie"`
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestListMoviesAll(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("got Authorization %q", got)
+		}
+		if got := r.URL.Query().Get("genres"); got != "drama" {
+			t.Errorf("got genres %q", got)
+		}
+
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			page = "1"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"movies": [{"id": %s, "title": "Movie %s", "version": 1}], "metadata": {"current_page": %s, "page_size": 1, "first_page": 1, "last_page": 3, "total_records": 3}}`, page, page, page)
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, WithToken("secret"))
+
+	movies, err := c.ListMoviesAll(context.Background(), ListMoviesParams{Genres: String("drama")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(movies) != 3 || movies[2].ID != 3 || movies[2].Title != "Movie 3" {
+		t.Errorf("got movies %+v", movies)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/movies/7":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": "the requested resource could not be found", "code": "MOVIE_NOT_FOUND"}`)
+		default:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"error": {"title": "must be provided"}, "code": "VALIDATION_FAILED"}`)
+		}
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL)
+
+	_, err := c.ShowMovie(context.Background(), 7, ShowMovieParams{})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "MOVIE_NOT_FOUND" {
+		t.Errorf("got error %v", err)
+	}
+
+	_, err = c.CreateSeries(context.Background(), CreateSeriesRequest{})
+	if !errors.As(err, &apiErr) || apiErr.Code != "VALIDATION_FAILED" || apiErr.Fields["title"] != "must be provided" {
+		t.Errorf("got error %v", err)
+	}
+}
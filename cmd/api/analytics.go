@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/analytics"
+	"github.com/levisthors/greenlight/internal/data"
+)
+
+const (
+	analyticsConsentOptIn  = "opt-in"
+	analyticsConsentOptOut = "opt-out"
+
+	analyticsBuffer = 10000
+	analyticsBatch  = 500
+
+	// maxSearchTerm keeps pasted text out of the events.
+	maxSearchTerm = 100
+)
+
+// analyticsRecorder queues events for the runAnalytics job. Recording never
+// blocks a request: when the queue is full, events are dropped and counted.
+type analyticsRecorder struct {
+	sink    analytics.Sink
+	key     []byte
+	optOut  bool
+	events  chan analytics.Event
+	dropped atomic.Int64
+}
+
+// newAnalyticsRecorder returns a recorder that hashes user IDs with key, or
+// with a random key if it is empty, in which case the hashes do not link a
+// user's events across restarts.
+func newAnalyticsRecorder(sink analytics.Sink, key []byte, consent string) (*analyticsRecorder, error) {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		_, err := rand.Read(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &analyticsRecorder{
+		sink:   sink,
+		key:    key,
+		optOut: consent == analyticsConsentOptOut,
+		events: make(chan analytics.Event, analyticsBuffer),
+	}, nil
+}
+
+// consents reports whether the user's activity may be recorded. Anonymous
+// clients and users who have not chosen follow the server's consent mode.
+func (a *analyticsRecorder) consents(user *data.User) bool {
+	if !user.IsAnonymous() && user.AnalyticsConsent != nil {
+		return *user.AnalyticsConsent
+	}
+	return a.optOut
+}
+
+func (a *analyticsRecorder) userHash(user *data.User) string {
+	if user.IsAnonymous() {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(strconv.FormatInt(user.ID, 10)))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// recordEvent queues an event for the request's user, if analytics are enabled
+// and the user consents. Times are truncated to the minute.
+func (app *application) recordEvent(r *http.Request, eventType string, eventData map[string]interface{}) {
+	a := app.analytics
+	if a == nil {
+		return
+	}
+
+	user := app.contextGetUser(r)
+	if !a.consents(user) {
+		return
+	}
+
+	event := analytics.Event{
+		Type: eventType,
+		Time: time.Now().UTC().Truncate(time.Minute),
+		User: a.userHash(user),
+		Data: eventData,
+	}
+
+	select {
+	case a.events <- event:
+	default:
+		a.dropped.Add(1)
+	}
+}
+
+// searchEventData describes a movie search by its normalised title term, the
+// names of the other filters used and the genres asked for. Other filter
+// values are left out.
+func searchEventData(qs url.Values, results int) map[string]interface{} {
+	eventData := map[string]interface{}{"results": results}
+
+	term := strings.ToLower(strings.Join(strings.Fields(qs.Get("title")), " "))
+	if len(term) > maxSearchTerm {
+		term = strings.ToValidUTF8(term[:maxSearchTerm], "")
+	}
+	if term != "" {
+		eventData["term"] = term
+	}
+
+	var filters []string
+	for _, name := range []string{"genres", "released_after", "released_before", "original_language", "country", "certification"} {
+		if qs.Get(name) != "" {
+			filters = append(filters, name)
+		}
+	}
+	sort.Strings(filters)
+	if len(filters) > 0 {
+		eventData["filters"] = filters
+	}
+
+	if genres := qs.Get("genres"); genres != "" {
+		eventData["genres"] = strings.Split(strings.ToLower(genres), ",")
+	}
+
+	if s := qs.Get("sort"); s != "" {
+		eventData["sort"] = s
+	}
+
+	return eventData
+}
+
+// runAnalytics sends queued events to the sink in batches, every interval or
+// whenever a batch fills. A batch the sink rejects is logged and dropped.
+func (app *application) runAnalytics(ctx context.Context, interval time.Duration) {
+	a := app.analytics
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	batch := make([]analytics.Event, 0, analyticsBatch)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		writeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := a.sink.Write(writeCtx, batch)
+		cancel()
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"job": "analytics", "events": strconv.Itoa(len(batch))})
+		}
+
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Send what is already queued before the server exits.
+		drain:
+			for {
+				select {
+				case event := <-a.events:
+					batch = append(batch, event)
+					if len(batch) == analyticsBatch {
+						flush()
+					}
+				default:
+					break drain
+				}
+			}
+			flush()
+
+			err := a.sink.Close()
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"job": "analytics"})
+			}
+			return
+		case event := <-a.events:
+			batch = append(batch, event)
+			if len(batch) == analyticsBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/analytics"
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/jsonlog"
+)
+
+func TestAnalyticsConsent(t *testing.T) {
+	yes, no := true, false
+
+	users := map[string]*data.User{
+		"anonymous": data.AnonymousUser,
+		"undecided": {ID: 1},
+		"opted in":  {ID: 2, AnalyticsConsent: &yes},
+		"opted out": {ID: 3, AnalyticsConsent: &no},
+	}
+
+	want := map[string]map[string]bool{
+		analyticsConsentOptIn:  {"anonymous": false, "undecided": false, "opted in": true, "opted out": false},
+		analyticsConsentOptOut: {"anonymous": true, "undecided": true, "opted in": true, "opted out": false},
+	}
+
+	for mode, cases := range want {
+		a, err := newAnalyticsRecorder(nil, []byte("key"), mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, consents := range cases {
+			if got := a.consents(users[name]); got != consents {
+				t.Errorf("%s, %s user: consents = %t; want %t", mode, name, got, consents)
+			}
+		}
+	}
+}
+
+func TestAnalyticsPipeline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	sink, err := analytics.Open("file:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder, err := newAnalyticsRecorder(sink, []byte("key"), analyticsConsentOptIn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{logger: jsonlog.New(io.Discard, jsonlog.LevelOff), analytics: recorder}
+
+	yes := true
+	user := &data.User{ID: 42, AnalyticsConsent: &yes}
+
+	for _, u := range []*data.User{user, {ID: 7}} {
+		r := app.contextSetUser(httptest.NewRequest("GET", "/v1/movies/1", nil), u)
+		app.recordEvent(r, "movies.view", map[string]interface{}{"movie_id": 1})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		app.runAnalytics(ctx, time.Hour)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var events []analytics.Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e analytics.Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("got %d events; want only the consenting user's", len(events))
+	}
+
+	e := events[0]
+	if e.Type != "movies.view" || e.User != recorder.userHash(user) || e.Time.Second() != 0 {
+		t.Errorf("got event %+v", e)
+	}
+	if len(e.User) != 32 {
+		t.Errorf("user hash is %q; want 32 hex digits", e.User)
+	}
+
+	other, _ := newAnalyticsRecorder(nil, []byte("other key"), analyticsConsentOptIn)
+	if other.userHash(user) == e.User {
+		t.Error("user hash does not depend on the key")
+	}
+}
+
+func TestSearchEventData(t *testing.T) {
+	qs, _ := url.ParseQuery("title=  The   GODFATHER &genres=Crime,Drama&country=US&sort=-year&page=1")
+
+	got := searchEventData(qs, 3)
+	want := map[string]interface{}{
+		"results": 3,
+		"term":    "the godfather",
+		"filters": []string{"country", "genres"},
+		"genres":  []string{"crime", "drama"},
+		"sort":    "-year",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/jsonlog"
+	"github.com/levisthors/greenlight/internal/migrate"
+	"github.com/levisthors/greenlight/migrations"
+)
+
+const (
+	backupFormat  = "greenlight-backup"
+	backupVersion = 1
+)
+
+// backupTables are the tables a backup holds, parents before children so
+// that a restore satisfies foreign keys as it goes. Tokens, exports and
+// operations are short-lived and left out; a restore empties them.
+var backupTables = []struct {
+	name   string
+	serial bool
+}{
+	{"series", true},
+	{"movies", true},
+	{"providers", true},
+	{"movie_availability", false},
+	{"permissions", true},
+	{"users", true},
+	{"users_permissions", false},
+	{"partners", true},
+	{"user_erasures", true},
+	{"ip_rules", true},
+	{"usage", false},
+	{"watch_history", true},
+	{"sync_cursors", false},
+	{"saved_searches", true},
+	{"notifications", true},
+	{"write_freezes", false},
+}
+
+// A backup archive is a gzipped stream of JSON values: a backupHeader, then
+// for each table a backupSection followed by its rows as JSON objects.
+type backupHeader struct {
+	Format        string    `json:"format"`
+	Version       int       `json:"version"`
+	SchemaVersion int64     `json:"schema_version"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type backupSection struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+}
+
+func (app *application) schemaVersion(ctx context.Context) (int64, error) {
+	m, err := migrate.New(app.db.DB, migrations.FS)
+	if err != nil {
+		return 0, err
+	}
+
+	version, dirty, err := m.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("schema version %d is dirty; fix it with migrate force first", version)
+	}
+
+	return version, nil
+}
+
+// backup writes an archive of the backup tables to w. All tables are read in
+// one repeatable read transaction, so the archive is a consistent snapshot
+// even while the API keeps serving writes.
+func (app *application) backup(ctx context.Context, w io.Writer) ([]backupSection, error) {
+	version, err := app.schemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := app.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	zw := gzip.NewWriter(w)
+	bw := bufio.NewWriter(zw)
+	enc := json.NewEncoder(bw)
+
+	err = enc.Encode(backupHeader{Format: backupFormat, Version: backupVersion, SchemaVersion: version, CreatedAt: time.Now().UTC()})
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []backupSection
+
+	for _, table := range backupTables {
+		section := backupSection{Table: table.name}
+
+		err := tx.QueryRowContext(ctx, `SELECT count(*) FROM `+table.name).Scan(&section.Rows)
+		if err != nil {
+			return nil, err
+		}
+
+		err = enc.Encode(section)
+		if err != nil {
+			return nil, err
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT row_to_json(t) FROM `+table.name+` t`)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var row []byte
+
+			err := rows.Scan(&row)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			bw.Write(row)
+			bw.WriteByte('\n')
+		}
+
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		rows.Close()
+
+		sections = append(sections, section)
+	}
+
+	err = bw.Flush()
+	if err != nil {
+		return nil, err
+	}
+
+	return sections, zw.Close()
+}
+
+// restore replaces the contents of the backup tables with the archive read
+// from r, in a single transaction. It refuses to overwrite a database that
+// has users or movies unless replace is set, and an archive made at a
+// different schema version.
+func (app *application) restore(ctx context.Context, r io.Reader, replace bool) ([]backupSection, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+
+	dec := json.NewDecoder(zr)
+
+	var header backupHeader
+
+	err = dec.Decode(&header)
+	if err != nil || header.Format != backupFormat {
+		return nil, errors.New("not a greenlight backup archive")
+	}
+	if header.Version != backupVersion {
+		return nil, fmt.Errorf("unsupported archive version %d", header.Version)
+	}
+
+	version, err := app.schemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version != header.SchemaVersion {
+		return nil, fmt.Errorf("archive is from schema version %d but the database is at %d; migrate to %d first", header.SchemaVersion, version, header.SchemaVersion)
+	}
+
+	tx, err := app.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if !replace {
+		var exists bool
+
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users) OR EXISTS (SELECT 1 FROM movies)`).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, errors.New("the database already has users or movies; use -replace to overwrite them")
+		}
+	}
+
+	names := make([]string, len(backupTables))
+	for i, table := range backupTables {
+		names[i] = table.name
+	}
+
+	// CASCADE also empties the tables that are not backed up but refer to
+	// these, such as tokens.
+	_, err = tx.ExecContext(ctx, `TRUNCATE `+strings.Join(names, ", ")+` RESTART IDENTITY CASCADE`)
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []backupSection
+
+	for _, table := range backupTables {
+		var section backupSection
+
+		err := dec.Decode(&section)
+		if err != nil {
+			return nil, fmt.Errorf("reading archive: %w", err)
+		}
+		if section.Table != table.name {
+			return nil, fmt.Errorf("reading archive: found table %q where %q was expected", section.Table, table.name)
+		}
+
+		stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`INSERT INTO %[1]s SELECT * FROM json_populate_record(NULL::%[1]s, $1)`, table.name))
+		if err != nil {
+			return nil, err
+		}
+
+		for i := int64(0); i < section.Rows; i++ {
+			var row json.RawMessage
+
+			err := dec.Decode(&row)
+			if err != nil {
+				stmt.Close()
+				return nil, fmt.Errorf("reading %s row %d: %w", table.name, i+1, err)
+			}
+
+			// A []byte argument would be sent as bytea, so pass the JSON as
+			// a string.
+			_, err = stmt.ExecContext(ctx, string(row))
+			if err != nil {
+				stmt.Close()
+				return nil, fmt.Errorf("restoring %s row %d: %w", table.name, i+1, err)
+			}
+		}
+		stmt.Close()
+
+		if table.serial {
+			_, err = tx.ExecContext(ctx, fmt.Sprintf(`SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE(max(id), 0) + 1, false) FROM %[1]s`, table.name))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		sections = append(sections, section)
+	}
+
+	return sections, tx.Commit()
+}
+
+func printBackupSections(sections []backupSection) {
+	tw := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TABLE\tROWS")
+	for _, section := range sections {
+		fmt.Fprintf(tw, "%s\t%d\n", section.Table, section.Rows)
+	}
+	tw.Flush()
+}
+
+func backupCommand(args []string, logger *jsonlog.Logger) error {
+	var cfg config
+	var out string
+
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	registerDBFlags(fs, &cfg)
+	fs.StringVar(&out, "out", "", `File to write the archive to ("-" for standard output)`)
+	fs.Parse(args)
+
+	if out == "" {
+		return errors.New("backup: -out is required")
+	}
+	if strings.Contains(out, "://") {
+		return errors.New("backup: only local files are supported; use -out=- and pipe the archive to your storage tool")
+	}
+
+	app, cleanup, err := newCLIApplication(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	w := os.Stdout
+	if out != "-" {
+		f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	sections, err := app.backup(ctx, w)
+	if err != nil {
+		if out != "-" {
+			os.Remove(out)
+		}
+		return err
+	}
+
+	if out != "-" {
+		err = w.Sync()
+		if err != nil {
+			return err
+		}
+	}
+
+	printBackupSections(sections)
+	return nil
+}
+
+func restoreCommand(args []string, logger *jsonlog.Logger) error {
+	var cfg config
+	var in string
+	var replace bool
+
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	registerDBFlags(fs, &cfg)
+	fs.StringVar(&in, "in", "", `Archive to restore ("-" for standard input)`)
+	fs.BoolVar(&replace, "replace", false, "Overwrite a database that already has users or movies")
+	fs.Parse(args)
+
+	if in == "" {
+		return errors.New("restore: -in is required")
+	}
+
+	r := os.Stdin
+	if in != "-" {
+		f, err := os.Open(in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	app, cleanup, err := newCLIApplication(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	sections, err := app.restore(ctx, r, replace)
+	if err != nil {
+		return err
+	}
+
+	printBackupSections(sections)
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/testutil"
+)
+
+func TestBackupRestore(t *testing.T) {
+	app := newTestApplication(t)
+	ctx := context.Background()
+
+	user, token := testutil.CreateUser(t, app.models, true, data.PermissionMoviesRead)
+
+	series := &data.Series{Name: "Moana"}
+	if err := app.models.Series.Insert(series); err != nil {
+		t.Fatal(err)
+	}
+
+	movie := &data.Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, SeriesID: &series.ID}
+	if err := app.models.Movies.Insert(movie); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	if _, err := app.backup(ctx, &archive); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes after the backup are lost on restore.
+	if _, err := app.models.Movies.Delete(movie.ID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := app.restore(ctx, bytes.NewReader(archive.Bytes()), false); err == nil {
+		t.Fatal("restored over existing users without -replace")
+	}
+
+	sections, err := app.restore(ctx, bytes.NewReader(archive.Bytes()), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != len(backupTables) {
+		t.Errorf("restored %d tables; want %d", len(sections), len(backupTables))
+	}
+
+	restored, err := app.models.Movies.Get(movie.ID)
+	if err != nil {
+		t.Fatalf("movie was not restored: %s", err)
+	}
+	if restored.Title != movie.Title || restored.SeriesID == nil || *restored.SeriesID != series.ID {
+		t.Errorf("restored movie is %+v", restored)
+	}
+
+	got, err := app.models.Users.GetByEmail(user.Email)
+	if err != nil {
+		t.Fatalf("user was not restored: %s", err)
+	}
+	if permissions, _ := app.models.Permissions.GetAllForUser(got.ID); !permissions.Include(data.PermissionMoviesRead) {
+		t.Errorf("restored permissions are %v", permissions)
+	}
+
+	// Tokens are not backed up, so restoring signs everyone out.
+	if _, err := app.models.Users.GetForToken(data.ScopeAuthentication, token); err == nil {
+		t.Error("token survived the restore")
+	}
+
+	// New rows continue after the restored IDs.
+	another := &data.Movie{Title: "Up", Year: 2009, Runtime: 96, Genres: []string{"animation"}}
+	if err := app.models.Movies.Insert(another); err != nil {
+		t.Fatal(err)
+	}
+	if another.ID <= movie.ID {
+		t.Errorf("new movie got ID %d; want more than %d", another.ID, movie.ID)
+	}
+}
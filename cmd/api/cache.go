@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// writeCachedJSON sends a cacheable read response, or 304 Not Modified if the
+// client's copy is still fresh. The ETag is a hash of the encoded body and its
+// profile, so each representation of a resource, whether it has included
+// resources or links, which region it was filtered for or how it is
+// formatted, has its own. lastModified may be zero where no single time
+// covers the response, as for lists that movies can leave.
+func (app *application) writeCachedJSON(w http.ResponseWriter, r *http.Request, lastModified time.Time, env envelope) error {
+	format := app.responseFormat(r)
+
+	js, err := encodeResponse(format, http.StatusOK, env)
+	if err != nil {
+		return err
+	}
+
+	js = append(js, '\n')
+
+	h := fnv.New64a()
+	io.WriteString(h, format.profile())
+	h.Write(js)
+
+	// Vary goes on before the 304 short-circuit, so shared caches key the
+	// revalidated copy correctly.
+	w.Header().Add("Vary", "Accept")
+
+	if app.setCacheHeaders(w, r, lastModified, fmt.Sprintf(`"%x"`, h.Sum64())) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	sendJSON(w, format, http.StatusOK, js)
+	return nil
+}
+
+// setCacheHeaders adds the caching and validator headers for a read response
+// and reports whether the client's conditional request headers show that its
+// cached copy is still fresh, in which case a 304 should be sent instead.
+//
+// With region restrictions on, the response depends on the client's region.
+// A region header set by the proxy is listed in Vary so shared caches keep a
+// copy per region; a GeoIP region has no header to vary on, so those
+// responses are not shared at all.
+func (app *application) setCacheHeaders(w http.ResponseWriter, r *http.Request, lastModified time.Time, etag string) bool {
+	scope := "private"
+	if app.contextGetUser(r).IsAnonymous() {
+		scope = "public"
+	}
+
+	if app.regions != nil {
+		if app.regions.header != "" {
+			w.Header().Add("Vary", app.regions.header)
+		} else {
+			scope = "private"
+		}
+	}
+
+	if app.config.cache.maxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d, stale-while-revalidate=%d",
+			scope, int(app.config.cache.maxAge.Seconds()), int(app.config.cache.staleWhileRevalidate.Seconds())))
+	} else {
+		w.Header().Set("Cache-Control", scope+", no-cache")
+	}
+
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		return etagMatches(match, etag)
+	}
+
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lastModified.IsZero() {
+		return !lastModified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag.
+func etagMatches(match, etag string) bool {
+	for _, candidate := range strings.Split(match, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+)
+
+func TestWriteCachedJSON(t *testing.T) {
+	app := &application{}
+
+	do := func(accept, ifNoneMatch string, env envelope) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/v1/movies/1", nil)
+		r = app.contextSetUser(r, data.AnonymousUser)
+		r.Header.Set("Accept", accept)
+		r.Header.Set("If-None-Match", ifNoneMatch)
+
+		w := httptest.NewRecorder()
+		if err := app.writeCachedJSON(w, r, time.Time{}, env); err != nil {
+			t.Fatal(err)
+		}
+		return w
+	}
+
+	movie := envelope{"movie": map[string]interface{}{"id": 1, "release_date": "2016-11-23"}}
+
+	snake := do("application/json", "", movie)
+	etag := snake.Header().Get("ETag")
+
+	if w := do("application/json", etag, movie); w.Code != http.StatusNotModified || w.Header().Get("Vary") != "Accept" {
+		t.Errorf("same representation: got status %d and Vary %q; want 304 varying on Accept", w.Code, w.Header().Get("Vary"))
+	}
+
+	if w := do(`application/json; profile="camelCase"`, etag, movie); w.Code != http.StatusOK {
+		t.Errorf("other profile: got status %d; want 200", w.Code)
+	}
+
+	withSeries := envelope{"movie": movie["movie"], "series": map[string]interface{}{"id": 2}}
+	if w := do("application/json", etag, withSeries); w.Code != http.StatusOK {
+		t.Errorf("included resources: got status %d; want 200", w.Code)
+	}
+
+	if snake.Header().Get("Last-Modified") != "" {
+		t.Error("sent Last-Modified without a modification time")
+	}
+}
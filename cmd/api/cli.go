@@ -0,0 +1,584 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/jsonlog"
+	"github.com/levisthors/greenlight/internal/migrate"
+	"github.com/levisthors/greenlight/internal/validator"
+	"github.com/levisthors/greenlight/migrations"
+)
+
+const usage = `Usage: greenlight <command> [flags]
+
+Commands:
+  serve                          run the API server (default)
+  user create [flags]            create a user account
+  token revoke-all [flags]       delete all tokens, optionally for one scope
+  partner create [flags]         register a partner that authenticates with signed requests
+  keys rotate [flags]            re-encrypt user data and partner secrets with the current encryption key
+  movie import [flags] <file>    import movies from a CSV file
+  retention run [flags]          apply data retention policies, or report with -dry-run
+  backup -out=<file> [flags]     write a consistent snapshot of the database to an archive
+  restore -in=<file> [flags]     load an archive made by backup into the database
+  seed [flags]                   generate fake movies and users for development
+  migrate up [n]                 apply all migrations, or the next n
+  migrate down [n] | -all        roll back the last migration, the last n, or all of them
+  migrate version                print the current migration version
+  migrate force <version>        set the migration version without running it
+
+Run "greenlight <command> -h" for the flags a command accepts.
+`
+
+func runCommand(command string, args []string, logger *jsonlog.Logger) error {
+	switch command {
+	case "serve":
+		return serveCommand(args, logger)
+	case "user":
+		return userCommand(args, logger)
+	case "token":
+		return tokenCommand(args, logger)
+	case "partner":
+		return partnerCommand(args, logger)
+	case "keys":
+		return keysCommand(args, logger)
+	case "movie":
+		return movieCommand(args, logger)
+	case "retention":
+		return retentionCommand(args, logger)
+	case "backup":
+		return backupCommand(args, logger)
+	case "restore":
+		return restoreCommand(args, logger)
+	case "seed":
+		return seedCommand(args, logger)
+	case "migrate":
+		return migrateCommand(args)
+	case "help":
+		fmt.Print(usage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func subcommand(args []string, name string) (string, []string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return "", nil, fmt.Errorf("%s: missing subcommand", name)
+	}
+	return args[0], args[1:], nil
+}
+
+// newCLIApplication opens a database connection for an admin command and
+// returns an application with the models wired up, so that commands go
+// through the same data layer as the HTTP handlers.
+func newCLIApplication(cfg config, logger *jsonlog.Logger) (*application, func(), error) {
+	err := resolveSecrets(&cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyring, err := loadKeyring(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	instrumentedDB := data.NewDB(db, logger, cfg.db.slowQuery)
+
+	app := &application{
+		config: cfg,
+		logger: logger,
+		db:     instrumentedDB,
+		models: data.NewModels(instrumentedDB, keyring, cfg.movies),
+	}
+
+	return app, func() { db.Close() }, nil
+}
+
+func userCommand(args []string, logger *jsonlog.Logger) error {
+	sub, args, err := subcommand(args, "user")
+	if err != nil {
+		return err
+	}
+	if sub != "create" {
+		return fmt.Errorf("user: unknown subcommand %q", sub)
+	}
+
+	var cfg config
+	var input struct {
+		name      string
+		email     string
+		password  string
+		admin     bool
+		activated bool
+	}
+
+	fs := flag.NewFlagSet("user create", flag.ExitOnError)
+	registerDBFlags(fs, &cfg)
+	fs.StringVar(&input.name, "name", "", "User name")
+	fs.StringVar(&input.email, "email", "", "User email address")
+	fs.StringVar(&input.password, "password", "", "User password (read from GREENLIGHT_PASSWORD if empty)")
+	fs.BoolVar(&input.admin, "admin", false, "Grant the admin permission")
+	fs.BoolVar(&input.activated, "activated", true, "Create the account already activated")
+	fs.Parse(args)
+
+	if input.password == "" {
+		input.password = os.Getenv("GREENLIGHT_PASSWORD")
+	}
+
+	user := &data.User{
+		Name:      input.name,
+		Email:     input.email,
+		Activated: input.activated,
+	}
+
+	err = user.Password.Set(input.password)
+	if err != nil {
+		return err
+	}
+
+	v := validator.New()
+	if data.ValidateUser(v, user); !v.Valid() {
+		return validationError(v)
+	}
+
+	app, cleanup, err := newCLIApplication(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// Check before creating the service user, which would otherwise be left
+	// behind when the partner cannot be stored.
+	if app.models.Partners.Keys == nil {
+		return fmt.Errorf("partner create: %w; partner secrets are always stored encrypted", data.ErrNoEncryptionKeys)
+	}
+
+	err = app.models.Users.Insert(user)
+	if err != nil {
+		return err
+	}
+
+	permissions := []string{data.PermissionMoviesRead, data.PermissionMoviesWrite}
+	if input.admin {
+		permissions = append(permissions, data.PermissionAdmin)
+	}
+
+	err = app.models.Permissions.AddForUser(user.ID, permissions...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created user %d <%s> with permissions %s\n", user.ID, user.Email, strings.Join(permissions, ", "))
+	return nil
+}
+
+func tokenCommand(args []string, logger *jsonlog.Logger) error {
+	sub, args, err := subcommand(args, "token")
+	if err != nil {
+		return err
+	}
+	if sub != "revoke-all" {
+		return fmt.Errorf("token: unknown subcommand %q", sub)
+	}
+
+	var cfg config
+	var scope string
+
+	fs := flag.NewFlagSet("token revoke-all", flag.ExitOnError)
+	registerDBFlags(fs, &cfg)
+	fs.StringVar(&scope, "scope", "", "Only revoke tokens with this scope (activation|authentication)")
+	fs.Parse(args)
+
+	if scope != "" && !validator.In(scope, data.ScopeActivation, data.ScopeAuthentication) {
+		return fmt.Errorf("token revoke-all: invalid scope %q", scope)
+	}
+
+	app, cleanup, err := newCLIApplication(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	n, err := app.models.Tokens.DeleteAll(scope)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("revoked %d tokens\n", n)
+	return nil
+}
+
+func partnerCommand(args []string, logger *jsonlog.Logger) error {
+	sub, args, err := subcommand(args, "partner")
+	if err != nil {
+		return err
+	}
+	if sub != "create" {
+		return fmt.Errorf("partner: unknown subcommand %q", sub)
+	}
+
+	var cfg config
+	var input struct {
+		name  string
+		email string
+	}
+
+	fs := flag.NewFlagSet("partner create", flag.ExitOnError)
+	registerDBFlags(fs, &cfg)
+	fs.StringVar(&input.name, "name", "", "Partner name")
+	fs.StringVar(&input.email, "email", "", "Email address for the partner's service user")
+	fs.Parse(args)
+
+	// Partners never log in with a password, so their service user gets a
+	// random one.
+	password := make([]byte, 24)
+	_, err = rand.Read(password)
+	if err != nil {
+		return err
+	}
+
+	user := &data.User{
+		Name:      input.name,
+		Email:     input.email,
+		Activated: true,
+	}
+
+	err = user.Password.Set(base64.RawURLEncoding.EncodeToString(password))
+	if err != nil {
+		return err
+	}
+
+	v := validator.New()
+	if data.ValidateUser(v, user); !v.Valid() {
+		return validationError(v)
+	}
+
+	app, cleanup, err := newCLIApplication(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// Check before creating the service user, which would otherwise be left
+	// behind when the partner cannot be stored.
+	if app.models.Partners.Keys == nil {
+		return fmt.Errorf("partner create: %w; partner secrets are always stored encrypted", data.ErrNoEncryptionKeys)
+	}
+
+	err = app.models.Users.Insert(user)
+	if err != nil {
+		return err
+	}
+
+	err = app.models.Permissions.AddForUser(user.ID, data.PermissionMoviesRead, data.PermissionMoviesWrite)
+	if err != nil {
+		return err
+	}
+
+	partner := &data.Partner{Name: input.name, UserID: user.ID}
+
+	err = app.models.Partners.Insert(partner)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created partner %q acting as user %d\nkey id: %s\nsecret: %s\n", partner.Name, user.ID, partner.KeyID, partner.Secret)
+	return nil
+}
+
+func keysCommand(args []string, logger *jsonlog.Logger) error {
+	sub, args, err := subcommand(args, "keys")
+	if err != nil {
+		return err
+	}
+	if sub != "rotate" {
+		return fmt.Errorf("keys: unknown subcommand %q", sub)
+	}
+
+	var cfg config
+
+	fs := flag.NewFlagSet("keys rotate", flag.ExitOnError)
+	registerDBFlags(fs, &cfg)
+	fs.Parse(args)
+
+	app, cleanup, err := newCLIApplication(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	emails, err := app.models.Users.RotateEmails()
+	if err != nil {
+		return err
+	}
+
+	secrets, err := app.models.Partners.RotateSecrets()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("re-encrypted %d email addresses and %d partner secrets\n", emails, secrets)
+	return nil
+}
+
+func movieCommand(args []string, logger *jsonlog.Logger) error {
+	sub, args, err := subcommand(args, "movie")
+	if err != nil {
+		return err
+	}
+	if sub != "import" {
+		return fmt.Errorf("movie: unknown subcommand %q", sub)
+	}
+
+	var cfg config
+
+	fs := flag.NewFlagSet("movie import", flag.ExitOnError)
+	registerDBFlags(fs, &cfg)
+	registerMovieFlags(fs, &cfg)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: greenlight movie import [flags] <file.csv>")
+		fmt.Fprintln(fs.Output(), "The CSV file must have a title,year,runtime,genres header; genres are separated by |.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("movie import: expected exactly one file")
+	}
+
+	err = checkMovieRules(cfg.movies)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	app, cleanup, err := newCLIApplication(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	imported, failed, err := app.importMovies(f, func(line int, err error) {
+		fmt.Fprintf(os.Stderr, "line %d: %s\n", line, err)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("imported %d movies, %d rows failed\n", imported, failed)
+	return nil
+}
+
+// importMovies reads movies from CSV and inserts the valid rows. Rows that fail
+// to parse or validate are reported through onError and skipped; an error is
+// only returned if the file itself cannot be read or the database fails.
+func (app *application) importMovies(r io.Reader, onError func(line int, err error)) (int, int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 4
+
+	header, err := reader.Read()
+	if err != nil {
+		return 0, 0, err
+	}
+	if strings.Join(header, ",") != "title,year,runtime,genres" {
+		return 0, 0, errors.New("CSV header must be title,year,runtime,genres")
+	}
+
+	imported, failed := 0, 0
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			failed++
+			onError(line, err)
+			continue
+		}
+
+		movie, err := parseMovieRecord(record, app.models.Movies.Rules)
+		if err != nil {
+			failed++
+			onError(line, err)
+			continue
+		}
+
+		err = app.models.Movies.Insert(movie)
+		if err != nil {
+			return imported, failed, err
+		}
+
+		imported++
+	}
+
+	return imported, failed, nil
+}
+
+func parseMovieRecord(record []string, rules data.MovieRules) (*data.Movie, error) {
+	year, err := strconv.ParseInt(strings.TrimSpace(record[1]), 10, 32)
+	if err != nil {
+		return nil, errors.New("year must be an integer")
+	}
+
+	runtime, err := data.ParseRuntime(record[2])
+	if err != nil {
+		return nil, errors.New("runtime must be a number of minutes or a duration such as 1h47m")
+	}
+
+	var genres []string
+	for _, genre := range strings.Split(record[3], "|") {
+		if genre = strings.TrimSpace(genre); genre != "" {
+			genres = append(genres, genre)
+		}
+	}
+
+	movie := &data.Movie{
+		Title:   strings.TrimSpace(record[0]),
+		Year:    int32(year),
+		Runtime: runtime,
+		Genres:  genres,
+	}
+
+	v := validator.New()
+	if data.ValidateMovie(v, movie, rules); !v.Valid() {
+		return nil, validationError(v)
+	}
+
+	return movie, nil
+}
+
+func migrateCommand(args []string) error {
+	sub, args, err := subcommand(args, "migrate")
+	if err != nil {
+		return err
+	}
+
+	var cfg config
+
+	fs := flag.NewFlagSet("migrate "+sub, flag.ExitOnError)
+	registerDBFlags(fs, &cfg)
+	var all bool
+	if sub == "down" {
+		fs.BoolVar(&all, "all", false, "Roll back every migration")
+	}
+	fs.Parse(args)
+
+	err = resolveSecrets(&cfg)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	steps := 0
+	if sub == "up" || sub == "down" || sub == "force" {
+		if fs.NArg() > 0 {
+			steps, err = strconv.Atoi(fs.Arg(0))
+			if err != nil || steps < 0 {
+				return fmt.Errorf("migrate %s: invalid number %q", sub, fs.Arg(0))
+			}
+		} else if sub == "force" {
+			return errors.New("migrate force: missing version")
+		}
+	}
+
+	// Rolling back everything drops every table, so it has to be asked for
+	// with -all rather than happen by leaving the count out.
+	if sub == "down" {
+		switch {
+		case all && fs.NArg() > 0:
+			return errors.New("migrate down: give either a number of steps or -all")
+		case all:
+			steps = 0
+		case fs.NArg() == 0:
+			steps = 1
+		case steps == 0:
+			return errors.New("migrate down: use -all to roll back every migration")
+		}
+	}
+
+	switch sub {
+	case "up":
+		applied, err := m.Up(ctx, steps)
+		for _, migration := range applied {
+			fmt.Printf("applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("no change")
+		}
+		return err
+	case "down":
+		reverted, err := m.Down(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Println("no change")
+		}
+		return err
+	case "version":
+		version, dirty, err := m.Version(ctx)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			fmt.Printf("%d (dirty)\n", version)
+		} else {
+			fmt.Println(version)
+		}
+		return nil
+	case "force":
+		return m.Force(ctx, int64(steps))
+	default:
+		return fmt.Errorf("migrate: unknown subcommand %q", sub)
+	}
+}
+
+func validationError(v *validator.Validator) error {
+	messages := make([]string, 0, len(v.Errors))
+	for key, message := range v.Errors {
+		messages = append(messages, key+": "+message)
+	}
+	sort.Strings(messages)
+
+	return errors.New(strings.Join(messages, "; "))
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+// parsePrefixes parses a comma-separated list of addresses and CIDR blocks.
+func parsePrefixes(val string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+
+	for _, s := range strings.Split(val, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+
+		prefix, err := data.ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHop parses an address from X-Forwarded-For or a Forwarded for=
+// parameter, which may be quoted, bracketed and carry a port.
+func parseHop(s string) (netip.Addr, bool) {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return addr.Unmap(), true
+}
+
+// forwardedHops returns the client addresses recorded by proxies, nearest
+// client first. The standard Forwarded header takes precedence over
+// X-Forwarded-For.
+func forwardedHops(r *http.Request) []string {
+	var hops []string
+
+	if values := r.Header.Values("Forwarded"); len(values) > 0 {
+		for _, element := range strings.Split(strings.Join(values, ","), ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					hops = append(hops, value)
+				}
+			}
+		}
+		return hops
+	}
+
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+
+	return hops
+}
+
+// peerHost returns the host the connection carrying r came from.
+func peerHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// Connections over a Unix domain socket have no host:port remote
+		// address, so fall back to whatever the server set.
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// fromTrustedProxy reports whether r was sent by one of the trusted proxies,
+// whose headers about the client can be believed.
+func (app *application) fromTrustedProxy(r *http.Request) bool {
+	addr, ok := parseHop(peerHost(r))
+	return ok && containsAddr(app.config.network.trustedProxies, addr)
+}
+
+// clientIP works out the address of the client that made r. Forwarding
+// headers are only believed when the request came through a trusted proxy,
+// and are read from the right, skipping trusted proxies, so that a client
+// cannot spoof its address by sending its own X-Forwarded-For.
+func (app *application) clientIP(r *http.Request) string {
+	host := peerHost(r)
+
+	addr, ok := parseHop(host)
+	if !ok || !containsAddr(app.config.network.trustedProxies, addr) {
+		return host
+	}
+
+	hops := forwardedHops(r)
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHop(hops[i])
+		if !ok {
+			break
+		}
+
+		addr = hop
+		if !containsAddr(app.config.network.trustedProxies, addr) {
+			break
+		}
+	}
+
+	return addr.String()
+}
+
+// ipLists holds the admin allowlist and the denylist in memory so that they
+// can be checked on every request. They are loaded from the ip_rules table.
+type ipLists struct {
+	mu         sync.RWMutex
+	adminAllow []netip.Prefix
+	deny       []netip.Prefix
+}
+
+func (l *ipLists) set(rules []*data.IPRule) {
+	var adminAllow, deny []netip.Prefix
+
+	for _, rule := range rules {
+		prefix, err := data.ParsePrefix(rule.CIDR)
+		if err != nil {
+			continue
+		}
+
+		switch rule.List {
+		case data.IPListAdminAllow:
+			adminAllow = append(adminAllow, prefix)
+		case data.IPListDeny:
+			deny = append(deny, prefix)
+		}
+	}
+
+	l.mu.Lock()
+	l.adminAllow, l.deny = adminAllow, deny
+	l.mu.Unlock()
+}
+
+func (l *ipLists) denied(ip string) bool {
+	if l == nil {
+		return false
+	}
+
+	addr, ok := parseHop(ip)
+	if !ok {
+		return false
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return containsAddr(l.deny, addr)
+}
+
+// adminAllowed reports whether ip may use admin routes. An empty allowlist
+// allows every address.
+func (l *ipLists) adminAllowed(ip string) bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if len(l.adminAllow) == 0 {
+		return true
+	}
+
+	addr, ok := parseHop(ip)
+	return ok && containsAddr(l.adminAllow, addr)
+}
+
+func (app *application) loadIPRules() error {
+	rules, err := app.models.IPRules.GetAll()
+	if err != nil {
+		return err
+	}
+
+	app.ipLists.set(rules)
+	return nil
+}
+
+// refreshIPRules reloads the IP lists every interval until ctx is done, so
+// changes made through another instance are picked up.
+func (app *application) refreshIPRules(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := app.loadIPRules()
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"job": "ip_rules"})
+			}
+		}
+	}
+}
+
+func (app *application) listIPRulesHandler(w http.ResponseWriter, r *http.Request) {
+	rules, err := app.models.IPRules.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"ip_rules": rules}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createIPRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		CIDR string `json:"cidr"`
+		List string `json:"list"`
+		Note string `json:"note"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rule := &data.IPRule{
+		CIDR: input.CIDR,
+		List: input.List,
+		Note: input.Note,
+	}
+
+	v := validator.New()
+
+	if data.ValidateIPRule(v, rule); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.IPRules.Insert(rule)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateIPRule):
+			v.AddError("cidr", "this address is already on the list")
+			app.rejectedDataResponse(w, r, err, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.loadIPRules()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, envelope{"ip_rule": rule}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteIPRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r, codeIPRuleNotFound)
+		return
+	}
+
+	err = app.models.IPRules.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r, codeIPRuleNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.loadIPRules()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"message": "ip rule successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
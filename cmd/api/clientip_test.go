@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/levisthors/greenlight/internal/data"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := parsePrefixes("10.0.0.0/8, 192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{}
+	app.config.network.trustedProxies = proxies
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		value      string
+		want       string
+	}{
+		{"direct", "203.0.113.5:1234", "", "", "203.0.113.5"},
+		{"untrusted peer ignores header", "203.0.113.5:1234", "X-Forwarded-For", "198.51.100.7", "203.0.113.5"},
+		{"trusted proxy", "10.1.2.3:1234", "X-Forwarded-For", "198.51.100.7", "198.51.100.7"},
+		{"spoofed leftmost entry", "10.1.2.3:1234", "X-Forwarded-For", "1.1.1.1, 198.51.100.7", "198.51.100.7"},
+		{"chain of proxies", "192.0.2.1:1234", "X-Forwarded-For", "198.51.100.7, 10.0.0.9", "198.51.100.7"},
+		{"forwarded header", "10.1.2.3:1234", "Forwarded", `for=198.51.100.7;proto=https, for="[2001:db8::1]:4711"`, "2001:db8::1"},
+		{"garbage entry", "10.1.2.3:1234", "X-Forwarded-For", "not-an-ip", "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/v1/movies", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+
+			if got := app.clientIP(r); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPLists(t *testing.T) {
+	lists := &ipLists{}
+
+	if !lists.adminAllowed("203.0.113.5") {
+		t.Error("an empty admin allowlist should allow every address")
+	}
+
+	lists.set([]*data.IPRule{
+		{CIDR: "10.0.0.0/8", List: data.IPListAdminAllow},
+		{CIDR: "198.51.100.0/24", List: data.IPListDeny},
+	})
+
+	if lists.adminAllowed("203.0.113.5") || !lists.adminAllowed("10.4.4.4") {
+		t.Error("admin allowlist not applied")
+	}
+	if !lists.denied("198.51.100.7") || lists.denied("203.0.113.5") {
+		t.Error("denylist not applied")
+	}
+}
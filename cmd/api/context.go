@@ -9,7 +9,11 @@ import (
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey     = contextKey("user")
+	clientIPContextKey = contextKey("clientIP")
+	clientContextKey   = contextKey("client")
+)
 
 func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
 	ctx := context.WithValue(r.Context(), userContextKey, user)
@@ -23,3 +27,32 @@ func (app *application) contextGetUser(r *http.Request) *data.User {
 	}
 	return user
 }
+
+func (app *application) contextSetClientIP(r *http.Request, ip string) *http.Request {
+	ctx := context.WithValue(r.Context(), clientIPContextKey, ip)
+	return r.WithContext(ctx)
+}
+
+// contextGetClientIP returns the address stored by the resolveClientIP
+// middleware, working it out afresh for requests that did not pass through it.
+func (app *application) contextGetClientIP(r *http.Request) string {
+	ip, ok := r.Context().Value(clientIPContextKey).(string)
+	if !ok {
+		return app.clientIP(r)
+	}
+	return ip
+}
+
+// contextSetClient records which credential authenticated the request, for
+// usage tracking.
+func (app *application) contextSetClient(r *http.Request, client string) *http.Request {
+	ctx := context.WithValue(r.Context(), clientContextKey, client)
+	return r.WithContext(ctx)
+}
+
+// contextGetClient returns the credential name set by authenticate, or "" for
+// anonymous requests.
+func (app *application) contextGetClient(r *http.Request) string {
+	client, _ := r.Context().Value(clientContextKey).(string)
+	return client
+}
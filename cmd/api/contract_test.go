@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/jsonlog"
+	"github.com/levisthors/greenlight/internal/openapi"
+	"github.com/levisthors/greenlight/internal/testutil"
+)
+
+func loadSpec(t *testing.T) *openapi.Document {
+	t.Helper()
+
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("loading OpenAPI document: %s", err)
+	}
+
+	return doc
+}
+
+// TestSpecRoutesAreRouted checks that every documented operation is handled
+// by the router. It sends anonymous, bodiless requests, which every handler
+// rejects before touching the database, so it runs without Postgres.
+func TestSpecRoutesAreRouted(t *testing.T) {
+	doc := loadSpec(t)
+
+	app := &application{logger: jsonlog.New(io.Discard, jsonlog.LevelOff)}
+	routes := app.routes()
+
+	for _, route := range doc.Routes() {
+		path := openapi.Expand(route.Path, map[string]string{"id": "1"})
+
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, httptest.NewRequest(route.Method, path, nil))
+
+		if rr.Code == http.StatusNotFound || rr.Code == http.StatusMethodNotAllowed {
+			t.Errorf("%s %s is documented but not routed (got status %d)", route.Method, route.Path, rr.Code)
+		}
+	}
+}
+
+// TestContract replays the x-contract-cases of every operation against a
+// test server and checks the status code and response body against the
+// document, failing on undocumented statuses or fields.
+func TestContract(t *testing.T) {
+	doc := loadSpec(t)
+	app, ts := newTestServer(t)
+
+	user, userToken := testutil.CreateUser(t, app.models, true, data.PermissionMoviesRead, data.PermissionMoviesWrite)
+	_, readerToken := testutil.CreateUser(t, app.models, true, data.PermissionMoviesRead)
+	_, inactiveToken := testutil.CreateUser(t, app.models, false)
+	_, adminToken := testutil.CreateUser(t, app.models, true, data.PermissionMoviesRead, data.PermissionMoviesWrite, data.PermissionAdmin)
+
+	tokens := map[string]string{
+		"none":     "",
+		"user":     userToken,
+		"reader":   readerToken,
+		"inactive": inactiveToken,
+		"admin":    adminToken,
+	}
+
+	// fixtures create the records that placeholders in the cases refer to
+	// and return their IDs.
+	fixtures := map[string]func(t *testing.T) int64{
+		"$movie": func(t *testing.T) int64 {
+			movie := &data.Movie{Title: "Contract", Year: 2001, Runtime: 90, Genres: []string{"drama", "comedy"}}
+
+			err := app.models.Movies.Insert(movie)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return movie.ID
+		},
+		"$series": func(t *testing.T) int64 {
+			series := &data.Series{Name: "Contract"}
+
+			err := app.models.Series.Insert(series)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return series.ID
+		},
+		"$provider": func(t *testing.T) int64 {
+			provider := &data.Provider{Name: fmt.Sprintf("Contract %s", t.Name())}
+
+			err := app.models.Providers.Insert(provider)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return provider.ID
+		},
+		"$operation": func(t *testing.T) int64 {
+			op := &data.Operation{UserID: user.ID, Kind: data.OperationExport}
+
+			err := app.models.Operations.Insert(op)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return op.ID
+		},
+	}
+
+	for _, route := range doc.Routes() {
+		route := route
+
+		if len(route.Operation.Cases) == 0 {
+			t.Errorf("%s %s has no contract cases", route.Method, route.Path)
+			continue
+		}
+
+		for _, c := range route.Operation.Cases {
+			c := c
+
+			t.Run(fmt.Sprintf("%s/%s", route.Operation.OperationID, c.Name), func(t *testing.T) {
+				params := make(map[string]string)
+				for name, value := range c.Params {
+					if fixture, ok := fixtures[value]; ok {
+						value = strconv.FormatInt(fixture(t), 10)
+					}
+					params[name] = value
+				}
+
+				query := c.Query
+				body := bytes.ReplaceAll(c.Body, []byte(`"$email"`), []byte(strconv.Quote(user.Email)))
+
+				for placeholder, fixture := range fixtures {
+					if strings.Contains(query, placeholder) {
+						query = strings.ReplaceAll(query, placeholder, strconv.FormatInt(fixture(t), 10))
+					}
+					if quoted := []byte(strconv.Quote(placeholder)); bytes.Contains(body, quoted) {
+						body = bytes.ReplaceAll(body, quoted, []byte(strconv.FormatInt(fixture(t), 10)))
+					}
+				}
+
+				path := openapi.Expand(route.Path, params)
+				if query != "" {
+					path += "?" + query
+				}
+
+				var reqBody interface{}
+				if len(body) > 0 {
+					reqBody = json.RawMessage(body)
+				}
+
+				token, ok := tokens[c.Auth]
+				if !ok && c.Auth != "" {
+					t.Fatalf("unknown auth %q", c.Auth)
+				}
+
+				rs := ts.Do(t, route.Method, path, token, reqBody)
+
+				if rs.Status != c.Status {
+					t.Errorf("got status %d; want %d: %s", rs.Status, c.Status, rs.Body)
+				}
+
+				documented, ok := route.Operation.Responses[strconv.Itoa(rs.Status)]
+				if !ok {
+					t.Fatalf("status %d is not documented", rs.Status)
+				}
+
+				schema := openapi.JSONSchema(documented.Content)
+				if schema == nil {
+					if len(rs.Body) > 0 && len(documented.Content) == 0 {
+						t.Errorf("response has an undocumented body: %s", rs.Body)
+					}
+					return
+				}
+
+				var value interface{}
+				rs.Decode(t, &value)
+
+				for _, err := range doc.Validate(schema, value) {
+					t.Errorf("response does not match schema: %s", err)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"net/netip"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+func (app *application) debugRoutes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/goroutines", app.goroutineDumpHandler)
+	mux.HandleFunc("/debug/gcstats", app.gcStatsHandler)
+
+	return app.recoverPanic(app.requireDebugCredentials(mux))
+}
+
+func (app *application) goroutineDumpHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	pprof.Handler("goroutine").ServeHTTP(w, withQuery(r, "debug", "2"))
+}
+
+func (app *application) gcStatsHandler(w http.ResponseWriter, r *http.Request) {
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	env := envelope{
+		"gc": map[string]interface{}{
+			"num_gc":         gc.NumGC,
+			"last_gc":        gc.LastGC.UTC().Format(time.RFC3339Nano),
+			"pause_total_ms": float64(gc.PauseTotal) / float64(time.Millisecond),
+		},
+		"memory": map[string]interface{}{
+			"heap_alloc":      mem.HeapAlloc,
+			"heap_inuse":      mem.HeapInuse,
+			"heap_objects":    mem.HeapObjects,
+			"sys":             mem.Sys,
+			"next_gc":         mem.NextGC,
+			"gc_cpu_fraction": mem.GCCPUFraction,
+		},
+		"goroutines": runtime.NumGoroutine(),
+	}
+
+	err := app.writeJSON(w, r, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// checkDebugConfig refuses a diagnostics server that anyone who can reach it
+// could use: without both a username and a password it must be bound to a
+// loopback address.
+func checkDebugConfig(addr, username, password string) error {
+	if addr == "" || (username != "" && password != "") {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("-debug-addr: %w", err)
+	}
+
+	if host == "localhost" {
+		return nil
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && ip.IsLoopback() {
+		return nil
+	}
+
+	return errors.New("-debug-username and -debug-password are required unless -debug-addr is a loopback address")
+}
+
+// requireDebugCredentials checks the basic auth credentials for the
+// diagnostics server. Without any configured, checkDebugConfig has made sure
+// the server only listens on loopback.
+func (app *application) requireDebugCredentials(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.config.debug.username == "" && app.config.debug.password == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		username, password, ok := r.BasicAuth()
+		if !ok || !secureCompare(username, app.config.debug.username) || !secureCompare(password, app.config.debug.password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="debug", charset="UTF-8"`)
+			app.invalidCredentialsResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func secureCompare(given, expected string) bool {
+	a := sha256.Sum256([]byte(given))
+	b := sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(a[:], b[:]) == 1
+}
+
+func withQuery(r *http.Request, key, value string) *http.Request {
+	r2 := r.Clone(r.Context())
+	qs := r2.URL.Query()
+	qs.Set(key, value)
+	r2.URL.RawQuery = qs.Encode()
+	return r2
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestCheckDebugConfig(t *testing.T) {
+	tests := []struct {
+		addr, username, password string
+		wantErr                  bool
+	}{
+		{"", "", "", false},
+		{"localhost:6060", "", "", false},
+		{"127.0.0.1:6060", "", "", false},
+		{"[::1]:6060", "", "", false},
+		{":6060", "", "", true},
+		{"0.0.0.0:6060", "", "", true},
+		{"10.0.0.5:6060", "", "", true},
+		{":6060", "admin", "secret", false},
+		{":6060", "admin", "", true},
+		{":6060", "", "secret", true},
+		{"127.0.0.1:6060", "admin", "", false},
+		{"6060", "", "", true},
+	}
+
+	for _, tt := range tests {
+		err := checkDebugConfig(tt.addr, tt.username, tt.password)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkDebugConfig(%q, %q, %q) = %v; want error %v", tt.addr, tt.username, tt.password, err, tt.wantErr)
+		}
+	}
+}
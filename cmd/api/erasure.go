@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+)
+
+func (app *application) requestUserErasureHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	erasure, err := app.models.Erasures.Request(user.ID, app.config.erasure.gracePeriod)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusAccepted, envelope{"erasure": erasure}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) cancelUserErasureHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	err := app.models.Erasures.Cancel(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r, codeErasureNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"message": "account deletion cancelled"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// runErasures carries out due erasures every interval until ctx is done.
+func (app *application) runErasures(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		app.completeDueErasures()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *application) completeDueErasures() {
+	erasures, err := app.models.Erasures.GetDue()
+	if err != nil {
+		app.logger.PrintError(err, nil)
+		return
+	}
+
+	for _, erasure := range erasures {
+		err := app.models.Erasures.Complete(erasure)
+		if err != nil {
+			// An edit conflict means the erasure was cancelled meanwhile.
+			if !errors.Is(err, data.ErrEditConflict) {
+				app.logger.PrintError(err, map[string]string{
+					"erasure_id": strconv.FormatInt(erasure.ID, 10),
+				})
+			}
+			continue
+		}
+
+		app.logger.PrintInfo("user data erased", map[string]string{
+			"erasure_id": strconv.FormatInt(erasure.ID, 10),
+			"user_id":    strconv.FormatInt(erasure.UserID, 10),
+		})
+	}
+}
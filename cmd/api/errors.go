@@ -1,21 +1,102 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+
+	"github.com/levisthors/greenlight/internal/data"
+)
+
+// errorCode is the stable, machine-readable reason sent in the code field of
+// every error response. Clients should branch on codes, not on messages,
+// which may change.
+type errorCode string
+
+const (
+	codeInternalError          errorCode = "INTERNAL_ERROR"
+	codeBadRequest             errorCode = "BAD_REQUEST"
+	codeValidationFailed       errorCode = "VALIDATION_FAILED"
+	codeRouteNotFound          errorCode = "ROUTE_NOT_FOUND"
+	codeMethodNotAllowed       errorCode = "METHOD_NOT_ALLOWED"
+	codeTimeout                errorCode = "TIMEOUT"
+	codeRateLimited            errorCode = "RATE_LIMITED"
+	codeQuotaExceeded          errorCode = "QUOTA_EXCEEDED"
+	codeServiceOverloaded      errorCode = "SERVICE_OVERLOADED"
+	codeWriteFrozen            errorCode = "WRITE_FROZEN"
+	codeInvalidCredentials     errorCode = "INVALID_CREDENTIALS"
+	codeInvalidToken           errorCode = "INVALID_TOKEN"
+	codeAuthenticationRequired errorCode = "AUTHENTICATION_REQUIRED"
+	codeAccountInactive        errorCode = "ACCOUNT_INACTIVE"
+	codeInvalidSignature       errorCode = "INVALID_SIGNATURE"
+	codeIPBlocked              errorCode = "IP_BLOCKED"
+	codePermissionDenied       errorCode = "PERMISSION_DENIED"
+	codeWebSocketHandshake     errorCode = "WEBSOCKET_HANDSHAKE_FAILED"
+	codeRegionRestricted       errorCode = "REGION_RESTRICTED"
+
+	codeMovieNotFound        errorCode = "MOVIE_NOT_FOUND"
+	codeSeriesNotFound       errorCode = "SERIES_NOT_FOUND"
+	codeAvailabilityNotFound errorCode = "AVAILABILITY_NOT_FOUND"
+	codeOperationNotFound    errorCode = "OPERATION_NOT_FOUND"
+	codeIPRuleNotFound       errorCode = "IP_RULE_NOT_FOUND"
+	codeExportNotFound       errorCode = "EXPORT_NOT_FOUND"
+	codeErasureNotFound      errorCode = "ERASURE_NOT_FOUND"
+	codeSavedSearchNotFound  errorCode = "SAVED_SEARCH_NOT_FOUND"
+	codeNotificationNotFound errorCode = "NOTIFICATION_NOT_FOUND"
+	codeWriteFreezeNotFound  errorCode = "WRITE_FREEZE_NOT_FOUND"
+
+	codeEditConflict      errorCode = "EDIT_CONFLICT"
+	codeDuplicateEmail    errorCode = "DUPLICATE_EMAIL"
+	codeDuplicateProvider errorCode = "DUPLICATE_PROVIDER"
+	codeDuplicateIPRule   errorCode = "DUPLICATE_IP_RULE"
+	codeInvalidBulkUpdate errorCode = "INVALID_BULK_UPDATE"
 )
 
+// dataErrorCodes maps the data layer's sentinel errors for rejected writes to
+// their codes. Lookups all fail with data.ErrRecordNotFound, so handlers
+// pass notFoundResponse the code for the missing resource themselves.
+var dataErrorCodes = []struct {
+	err  error
+	code errorCode
+}{
+	{data.ErrDuplicateEmail, codeDuplicateEmail},
+	{data.ErrDuplicateProvider, codeDuplicateProvider},
+	{data.ErrDuplicateIPRule, codeDuplicateIPRule},
+	{data.ErrInvalidBulkUpdate, codeInvalidBulkUpdate},
+}
+
+func dataErrorCode(err error) (errorCode, bool) {
+	for _, c := range dataErrorCodes {
+		if errors.Is(err, c.err) {
+			return c.code, true
+		}
+	}
+	return "", false
+}
+
 func (app *application) logError(r *http.Request, err error) {
 	app.logger.PrintError(err, map[string]string{
 		"request_method": r.Method,
 		"request_url":    r.URL.String(),
+		"client_ip":      app.contextGetClientIP(r),
+	})
+
+	app.hub.publish(topicErrors, map[string]string{
+		"request_method": r.Method,
+		"request_url":    r.URL.String(),
+		"error":          err.Error(),
 	})
 
 }
 
-func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
-	env := envelope{"error": message}
-	err := app.writeJSON(w, status, env, nil)
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, code errorCode, message interface{}) {
+	app.writeError(w, r, status, envelope{"error": message, "code": code})
+}
+
+// writeError sends an error envelope that carries more than the message.
+func (app *application) writeError(w http.ResponseWriter, r *http.Request, status int, env envelope) {
+	err := app.writeJSON(w, r, status, env, nil)
 	if err != nil {
 		app.logError(r, err)
 		w.WriteHeader(500)
@@ -25,55 +106,146 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err)
 	message := "the server encountered a problem and could not process your request"
-	app.errorResponse(w, r, http.StatusInternalServerError, message)
+	app.errorResponse(w, r, http.StatusInternalServerError, codeInternalError, message)
 }
 
-func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
+// notFoundResponse reports that the record a request names does not exist;
+// code says which kind of record it is.
+func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request, code errorCode) {
 	message := "the requested resource could not be found"
-	app.errorResponse(w, r, http.StatusNotFound, message)
+	app.errorResponse(w, r, http.StatusNotFound, code, message)
+}
+
+// routeNotFoundResponse answers requests for paths that match no route,
+// suggesting registered paths that are a near miss.
+func (app *application) routeNotFoundResponse(w http.ResponseWriter, r *http.Request) {
+	env := envelope{"error": "the requested resource could not be found", "code": codeRouteNotFound}
+
+	if suggestions := app.suggestRoutes(r.URL.Path); len(suggestions) > 0 {
+		env["suggestions"] = suggestions
+	}
+
+	app.writeError(w, r, http.StatusNotFound, env)
 }
 
 func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
 	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
-	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
+	env := envelope{"error": message, "code": codeMethodNotAllowed}
+
+	if methods := app.allowedMethods(r.URL.Path); len(methods) > 0 {
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		env["allowed_methods"] = methods
+	}
+
+	app.writeError(w, r, http.StatusMethodNotAllowed, env)
 }
 
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+	app.errorResponse(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
 }
 
 func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, codeValidationFailed, errors)
+}
+
+// rejectedDataResponse is failedValidationResponse for a write the data layer
+// refused, such as a duplicate email address. The code comes from the
+// sentinel error err.
+func (app *application) rejectedDataResponse(w http.ResponseWriter, r *http.Request, err error, errors map[string]string) {
+	code, ok := dataErrorCode(err)
+	if !ok {
+		code = codeValidationFailed
+	}
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, code, errors)
 }
 
-func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
+// editConflictResponse reports a failed versioned update. When err carries the
+// version now stored it is included, so the client can re-read and retry.
+func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request, err error) {
 	message := "unable to update the record due to an edit conflict, please try again"
-	app.errorResponse(w, r, http.StatusConflict, message)
+
+	env := envelope{"error": message, "code": codeEditConflict}
+
+	var conflict *data.EditConflictError
+	if errors.As(err, &conflict) {
+		env["current_version"] = conflict.CurrentVersion
+	}
+
+	app.writeError(w, r, http.StatusConflict, env)
 }
 
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
 	message := "rate limit exceeded"
-	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+	app.errorResponse(w, r, http.StatusTooManyRequests, codeRateLimited, message)
+}
+
+func (app *application) quotaExceededResponse(w http.ResponseWriter, r *http.Request) {
+	message := "monthly request quota exceeded"
+	app.errorResponse(w, r, http.StatusTooManyRequests, codeQuotaExceeded, message)
+}
+
+func (app *application) serviceOverloadedResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", "2")
+
+	message := "the server is temporarily overloaded, please try again later"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, codeServiceOverloaded, message)
+}
+
+func (app *application) writeFrozenResponse(w http.ResponseWriter, r *http.Request, freeze *data.WriteFreeze) {
+	message := freeze.Message
+	if message == "" {
+		message = defaultFreezeMessage
+	}
+	app.errorResponse(w, r, http.StatusServiceUnavailable, codeWriteFrozen, message)
+}
+
+func (app *application) regionRestrictedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this movie is not available in your region"
+	app.errorResponse(w, r, http.StatusUnavailableForLegalReasons, codeRegionRestricted, message)
 }
 
 func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
 	message := "invalid authentication credentials"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
+	app.errorResponse(w, r, http.StatusUnauthorized, codeInvalidCredentials, message)
 }
 
 func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("WWW-Authenticate", "Bearer")
 
 	message := "invalid or missing authentication token"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
+	app.errorResponse(w, r, http.StatusUnauthorized, codeInvalidToken, message)
 }
 
 func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
 	message := "you must be authenticated to access this resource"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
+	app.errorResponse(w, r, http.StatusUnauthorized, codeAuthenticationRequired, message)
 }
 
 func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.Request) {
 	message := "your user account must be activated to access this resource"
-	app.errorResponse(w, r, http.StatusForbidden, message)
+	app.errorResponse(w, r, http.StatusForbidden, codeAccountInactive, message)
+}
+
+func (app *application) invalidSignatureResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", signatureScheme)
+
+	message := "invalid, expired or replayed request signature"
+	app.errorResponse(w, r, http.StatusUnauthorized, codeInvalidSignature, message)
+}
+
+func (app *application) partnerRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", signatureScheme)
+
+	message := "this resource requires a request signed with a partner key"
+	app.errorResponse(w, r, http.StatusUnauthorized, codeInvalidSignature, message)
+}
+
+func (app *application) ipBlockedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "requests from your IP address are not allowed"
+	app.errorResponse(w, r, http.StatusForbidden, codeIPBlocked, message)
+}
+
+func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, codePermissionDenied, message)
 }
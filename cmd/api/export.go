@@ -0,0 +1,206 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+// exportTTL is how long the emailed download token for a data export is valid.
+const exportTTL = 24 * time.Hour
+
+func (app *application) requestUserExportHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	op, err := app.startOperation(user.ID, data.OperationExport, func(run *operationRun) (map[string]interface{}, error) {
+		token, err := app.exportUserData(user)
+		if err != nil {
+			return nil, err
+		}
+
+		// The download token is only ever sent by email: the operation
+		// result is stored and readable by anyone holding the user's
+		// authentication token, so it just records when the link expires.
+		err = app.mailer.Send(user.Email, "user_export.tmpl", map[string]interface{}{
+			"exportToken": token.Plaintext,
+		})
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"user_id": fmt.Sprint(user.ID)})
+			return nil, errors.New("the download link could not be emailed")
+		}
+
+		return map[string]interface{}{
+			"expiry": token.Expiry,
+		}, nil
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	message := "your data export is being prepared and a download link will be emailed to you"
+
+	app.writeOperationAccepted(w, r, op, envelope{"message": message})
+}
+
+// exportUserData builds a user's export archive, stores it and returns a token
+// for downloading it. Any earlier export and its tokens are replaced.
+func (app *application) exportUserData(user *data.User) (*data.Token, error) {
+	archive, err := app.buildUserExport(user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.models.Exports.Upsert(&data.Export{UserID: user.ID, Archive: archive})
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeExport, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.models.Tokens.New(user.ID, exportTTL, data.ScopeExport)
+}
+
+// buildUserExport returns a ZIP archive of JSON files holding everything
+// stored about the user: every table an erasure clears, apart from earlier
+// exports.
+func (app *application) buildUserExport(user *data.User) ([]byte, error) {
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := app.models.Tokens.GetAllForUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := app.models.History.GetAllForUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	searches, err := app.models.SavedSearches.GetAllForUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications, err := app.models.Notifications.GetAllForUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	operations, err := app.models.Operations.GetAllForUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := app.models.Usage.GetAllForUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	type exportedToken struct {
+		Scope  string    `json:"scope"`
+		Expiry time.Time `json:"expiry"`
+	}
+
+	exportedTokens := make([]exportedToken, len(tokens))
+	for i, token := range tokens {
+		exportedTokens[i] = exportedToken{Scope: token.Scope, Expiry: token.Expiry}
+	}
+
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", user},
+		{"permissions.json", permissions},
+		{"tokens.json", exportedTokens},
+		{"history.json", history},
+		{"searches.json", searches},
+		{"notifications.json", notifications},
+		{"operations.json", operations},
+		{"usage.json", usage},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for _, file := range files {
+		js, err := json.MarshalIndent(file.content, "", "\t")
+		if err != nil {
+			return nil, err
+		}
+
+		f, err := zw.Create("greenlight-export/" + file.name)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = f.Write(js)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// downloadUserExportHandler serves the export archive. It is authenticated by
+// the emailed export token in the query string rather than a bearer token, so
+// the link works from a browser.
+func (app *application) downloadUserExportHandler(w http.ResponseWriter, r *http.Request) {
+	tokenPlaintext := r.URL.Query().Get("token")
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, tokenPlaintext); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(data.ScopeExport, tokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired export token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	export, err := app.models.Exports.GetForUser(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r, codeExportNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	filename := fmt.Sprintf("greenlight-export-%s.zip", export.CreatedAt.Format("20060102"))
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(export.Archive)
+}
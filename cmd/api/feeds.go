@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+)
+
+const (
+	feedEntries = 50
+	// sitemapURLs is the most URLs a single sitemap file may hold.
+	sitemapURLs = 50000
+)
+
+type renderedFeed struct {
+	body []byte
+	etag string
+}
+
+// feedCache holds the movies behind the sitemap and the Atom feed for each
+// client region they have been served in. It is refilled on a schedule; the
+// XML is rendered once per base URL in between.
+type feedCache struct {
+	mu      sync.Mutex
+	regions map[string]*regionFeeds
+}
+
+// regionFeeds are the movies in the feeds for one client region.
+type regionFeeds struct {
+	generated time.Time
+	recent    []*data.Movie
+	stamps    []data.MovieStamp
+	rendered  map[string]renderedFeed
+}
+
+func (app *application) loadFeeds(region string) (*regionFeeds, error) {
+	recent, err := app.models.Movies.GetRecent(region, feedEntries)
+	if err != nil {
+		return nil, err
+	}
+
+	stamps, err := app.models.Movies.GetStamps(region, sitemapURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &regionFeeds{generated: time.Now(), recent: recent, stamps: stamps}, nil
+}
+
+func (app *application) storeFeeds(region string, feeds *regionFeeds) {
+	app.feeds.mu.Lock()
+	defer app.feeds.mu.Unlock()
+
+	if app.feeds.regions == nil {
+		app.feeds.regions = make(map[string]*regionFeeds)
+	}
+	app.feeds.regions[region] = feeds
+}
+
+// refreshFeeds reloads the feeds of every region they have been served in.
+// With region restrictions off there is only one set, which is loaded even
+// before it is first asked for.
+func (app *application) refreshFeeds() error {
+	app.feeds.mu.Lock()
+	regions := make([]string, 0, len(app.feeds.regions))
+	for region := range app.feeds.regions {
+		regions = append(regions, region)
+	}
+	app.feeds.mu.Unlock()
+
+	if len(regions) == 0 && app.regions == nil {
+		regions = append(regions, data.AnyRegion)
+	}
+
+	for _, region := range regions {
+		feeds, err := app.loadFeeds(region)
+		if err != nil {
+			return err
+		}
+		app.storeFeeds(region, feeds)
+	}
+
+	return nil
+}
+
+func (app *application) runFeeds(ctx context.Context, interval time.Duration) {
+	err := app.refreshFeeds()
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"job": "feeds"})
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := app.refreshFeeds()
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"job": "feeds"})
+			}
+		}
+	}
+}
+
+// renderFeed returns the named feed of the movies available in region for the
+// given base URL, loading them first if they have not been yet.
+func (app *application) renderFeed(name, base, region string) (renderedFeed, time.Time, error) {
+	app.feeds.mu.Lock()
+	feeds := app.feeds.regions[region]
+	app.feeds.mu.Unlock()
+
+	if feeds == nil {
+		var err error
+
+		feeds, err = app.loadFeeds(region)
+		if err != nil {
+			return renderedFeed{}, time.Time{}, err
+		}
+		app.storeFeeds(region, feeds)
+	}
+
+	app.feeds.mu.Lock()
+	defer app.feeds.mu.Unlock()
+
+	key := name + " " + base
+	if feed, ok := feeds.rendered[key]; ok {
+		return feed, feeds.generated, nil
+	}
+
+	var doc interface{}
+	switch name {
+	case "sitemap":
+		doc = app.sitemap(base, feeds.stamps)
+	case "atom":
+		doc = app.atomFeed(base, feeds.recent, feeds.generated)
+	default:
+		panic("unknown feed " + name)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	err := xml.NewEncoder(&buf).Encode(doc)
+	if err != nil {
+		return renderedFeed{}, time.Time{}, err
+	}
+
+	h := fnv.New64a()
+	h.Write(buf.Bytes())
+
+	feed := renderedFeed{body: buf.Bytes(), etag: fmt.Sprintf(`"%x"`, h.Sum64())}
+
+	if feeds.rendered == nil {
+		feeds.rendered = make(map[string]renderedFeed)
+	}
+	feeds.rendered[key] = feed
+
+	return feed, feeds.generated, nil
+}
+
+// baseURL returns the scheme and host that absolute links should use: the
+// configured public URL if there is one, otherwise the request's own.
+func (app *application) baseURL(r *http.Request) string {
+	if app.config.public.url != "" {
+		return strings.TrimSuffix(app.config.public.url, "/")
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func (app *application) movieURL(base string, id int64) string {
+	l, _ := app.link("movies.show", "id", strconv.FormatInt(id, 10))
+	return base + l.Href
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+func (app *application) sitemap(base string, stamps []data.MovieStamp) sitemapURLSet {
+	set := sitemapURLSet{URLs: make([]sitemapURL, len(stamps))}
+	for i, s := range stamps {
+		set.URLs[i] = sitemapURL{Loc: app.movieURL(base, s.ID), LastMod: s.UpdatedAt.UTC().Format(time.RFC3339)}
+	}
+	return set
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Link       atomLink       `xml:"link"`
+	Summary    string         `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+func (app *application) atomFeed(base string, movies []*data.Movie, generated time.Time) atomFeed {
+	self, _ := app.link("movies.feed")
+
+	updated := generated
+	if len(movies) > 0 {
+		updated = movies[0].UpdatedAt
+		for _, movie := range movies {
+			if movie.UpdatedAt.After(updated) {
+				updated = movie.UpdatedAt
+			}
+		}
+	}
+
+	feed := atomFeed{
+		ID:      base + self.Href,
+		Title:   "Greenlight: recently added movies",
+		Updated: updated.UTC().Format(time.RFC3339),
+		Link:    atomLink{Rel: "self", Href: base + self.Href},
+		Author:  atomAuthor{Name: "Greenlight"},
+		Entries: make([]atomEntry, len(movies)),
+	}
+
+	for i, movie := range movies {
+		title := movie.Title
+		if movie.Year > 0 {
+			title = fmt.Sprintf("%s (%d)", movie.Title, movie.Year)
+		}
+
+		entry := atomEntry{
+			ID:        app.movieURL(base, movie.ID),
+			Title:     title,
+			Updated:   movie.UpdatedAt.UTC().Format(time.RFC3339),
+			Published: movie.CreatedAt.UTC().Format(time.RFC3339),
+			Link:      atomLink{Href: app.movieURL(base, movie.ID)},
+			Summary:   movie.Synopsis,
+		}
+		for _, genre := range movie.Genres {
+			entry.Categories = append(entry.Categories, atomCategory{Term: genre})
+		}
+
+		feed.Entries[i] = entry
+	}
+
+	return feed
+}
+
+func (app *application) serveFeed(w http.ResponseWriter, r *http.Request, name, contentType string) {
+	feed, generated, err := app.renderFeed(name, app.baseURL(r), app.clientRegion(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if app.setCacheHeaders(w, r, generated, feed.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(feed.body)
+}
+
+func (app *application) sitemapHandler(w http.ResponseWriter, r *http.Request) {
+	app.serveFeed(w, r, "sitemap", "application/xml; charset=utf-8")
+}
+
+func (app *application) movieFeedHandler(w http.ResponseWriter, r *http.Request) {
+	app.serveFeed(w, r, "atom", "application/atom+xml; charset=utf-8")
+}
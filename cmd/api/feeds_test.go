@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/jsonlog"
+)
+
+func TestFeeds(t *testing.T) {
+	app := &application{logger: jsonlog.New(io.Discard, jsonlog.LevelOff)}
+	app.routes()
+
+	added := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	app.storeFeeds(data.AnyRegion, &regionFeeds{
+		generated: added.Add(time.Hour),
+		recent: []*data.Movie{
+			{ID: 2, Title: "Moana", Year: 2016, Genres: []string{"animation"}, CreatedAt: added, UpdatedAt: added},
+			{ID: 1, Title: "Black Panther", CreatedAt: added.Add(-time.Hour), UpdatedAt: added.Add(time.Minute)},
+		},
+		stamps: []data.MovieStamp{{ID: 1, UpdatedAt: added.Add(time.Minute)}, {ID: 2, UpdatedAt: added}},
+	})
+
+	r := httptest.NewRequest("GET", "/sitemap.xml", nil)
+	r.Host = "api.example.com"
+
+	sitemap, _, err := app.renderFeed("sitemap", app.baseURL(r), data.AnyRegion)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var set sitemapURLSet
+	if err := xml.Unmarshal(sitemap.body, &set); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.URLs) != 2 || set.URLs[0].Loc != "http://api.example.com/v1/movies/1" || set.URLs[0].LastMod != "2024-03-01T12:01:00Z" {
+		t.Errorf("got sitemap %+v", set.URLs)
+	}
+
+	app.config.public.url = "https://movies.example.com/"
+
+	atom, _, err := app.renderFeed("atom", app.baseURL(r), data.AnyRegion)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var feed atomFeed
+	if err := xml.Unmarshal(atom.body, &feed); err != nil {
+		t.Fatal(err)
+	}
+	if feed.Link.Href != "https://movies.example.com/v1/movies/feed.atom" || feed.Updated != "2024-03-01T12:01:00Z" {
+		t.Errorf("got feed link %q updated %q", feed.Link.Href, feed.Updated)
+	}
+	if len(feed.Entries) != 2 || feed.Entries[0].Title != "Moana (2016)" || feed.Entries[0].Link.Href != "https://movies.example.com/v1/movies/2" {
+		t.Errorf("got entries %+v", feed.Entries)
+	}
+	if !strings.HasPrefix(string(atom.body), xml.Header) {
+		t.Error("feed has no XML declaration")
+	}
+}
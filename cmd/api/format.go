@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/levisthors/greenlight/internal/data"
+)
+
+// Response format profiles. Clients pick them with a profile parameter on the
+// Accept header, e.g. Accept: application/json; profile="bare camelCase",
+// overriding the server defaults set by -response-style and -response-naming.
+const (
+	profileEnveloped = "enveloped"
+	profileBare      = "bare"
+	profileSnakeCase = "snake_case"
+	profileCamelCase = "camelCase"
+)
+
+type responseFormat struct {
+	bare    bool
+	camel   bool
+	runtime data.RuntimeFormat
+}
+
+func (f responseFormat) profile() string {
+	profiles := []string{profileEnveloped, profileSnakeCase}
+	if f.bare {
+		profiles[0] = profileBare
+	}
+	if f.camel {
+		profiles[1] = profileCamelCase
+	}
+	return strings.Join(profiles, " ")
+}
+
+func (app *application) responseFormat(r *http.Request) responseFormat {
+	format := responseFormat{
+		bare:    app.config.format.bare,
+		camel:   app.config.format.camelCase,
+		runtime: app.config.format.runtime,
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil || (mediaType != "application/json" && mediaType != "*/*") {
+			continue
+		}
+
+		for _, profile := range strings.Fields(params["profile"]) {
+			switch profile {
+			case profileEnveloped:
+				format.bare = false
+			case profileBare:
+				format.bare = true
+			case profileSnakeCase:
+				format.camel = false
+			case profileCamelCase:
+				format.camel = true
+			}
+		}
+	}
+
+	return format
+}
+
+// encodeResponse marshals env in the given format. A bare response is the
+// value of a single-key envelope on its own; envelopes with several keys,
+// such as a list and its metadata, and error responses keep their envelope.
+func encodeResponse(format responseFormat, status int, env envelope) ([]byte, error) {
+	var v interface{} = env
+
+	if format.bare && status < 400 && len(env) == 1 {
+		for _, value := range env {
+			v = value
+		}
+	}
+
+	js, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if !format.camel && format.runtime != data.RuntimeFormatString {
+		return js, nil
+	}
+
+	// Re-encode through generic values to rename the keys or rewrite the
+	// runtimes. UseNumber keeps large integers exact.
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+
+	var generic interface{}
+
+	err = dec.Decode(&generic)
+	if err != nil {
+		return nil, err
+	}
+
+	if format.runtime == data.RuntimeFormatString {
+		generic = runtimeStrings(generic)
+	}
+	if format.camel {
+		generic = camelCaseKeys(generic)
+	}
+
+	return json.Marshal(generic)
+}
+
+// runtimeStrings rewrites movie runtimes from minutes to strings such as
+// "107 mins".
+func runtimeStrings(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if n, ok := value.(json.Number); ok && key == "runtime" {
+				if minutes, err := n.Int64(); err == nil {
+					v[key] = data.Runtime(minutes).String()
+					continue
+				}
+			}
+			v[key] = runtimeStrings(value)
+		}
+		return v
+	case []interface{}:
+		for i, value := range v {
+			v[i] = runtimeStrings(value)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func camelCaseKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		renamed := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			renamed[snakeToCamel(key)] = camelCaseKeys(value)
+		}
+		return renamed
+	case []interface{}:
+		for i, value := range v {
+			v[i] = camelCaseKeys(value)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// snakeToCamel converts release_date to releaseDate. Keys that start with an
+// underscore, such as _links, are left alone.
+func snakeToCamel(s string) string {
+	if strings.HasPrefix(s, "_") || !strings.Contains(s, "_") {
+		return s
+	}
+
+	var b strings.Builder
+
+	for i, part := range strings.Split(s, "_") {
+		if i == 0 || part == "" {
+			b.WriteString(part)
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(part)
+		b.WriteRune(unicode.ToUpper(r))
+		b.WriteString(part[size:])
+	}
+
+	return b.String()
+}
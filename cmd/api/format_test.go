@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/levisthors/greenlight/internal/data"
+)
+
+func TestSnakeToCamel(t *testing.T) {
+	tests := map[string]string{
+		"title":               "title",
+		"release_date":        "releaseDate",
+		"total_records":       "totalRecords",
+		"retry_after_seconds": "retryAfterSeconds",
+		"_links":              "_links",
+		"trailing_":           "trailing",
+	}
+
+	for in, want := range tests {
+		if got := snakeToCamel(in); got != want {
+			t.Errorf("snakeToCamel(%q) = %q; want %q", in, got, want)
+		}
+	}
+}
+
+func TestEncodeResponse(t *testing.T) {
+	movie := map[string]interface{}{"id": 1, "release_date": "2016-11-23"}
+
+	tests := []struct {
+		name   string
+		format responseFormat
+		status int
+		data   envelope
+		want   string
+	}{
+		{"default", responseFormat{}, 200, envelope{"movie": movie}, `{"movie":{"id":1,"release_date":"2016-11-23"}}`},
+		{"bare", responseFormat{bare: true}, 200, envelope{"movie": movie}, `{"id":1,"release_date":"2016-11-23"}`},
+		{"camel", responseFormat{camel: true}, 200, envelope{"movie": movie}, `{"movie":{"id":1,"releaseDate":"2016-11-23"}}`},
+		{"bare camel", responseFormat{bare: true, camel: true}, 200, envelope{"movie": movie}, `{"id":1,"releaseDate":"2016-11-23"}`},
+		{"bare keeps multi-key envelopes", responseFormat{bare: true}, 200, envelope{"a": 1, "b": 2}, `{"a":1,"b":2}`},
+		{"bare keeps errors", responseFormat{bare: true}, 404, envelope{"error": "not found"}, `{"error":"not found"}`},
+		{"camel keeps large integers", responseFormat{camel: true}, 200, envelope{"n": int64(9007199254740993)}, `{"n":9007199254740993}`},
+		{"runtime string", responseFormat{runtime: data.RuntimeFormatString}, 200, envelope{"movies": []interface{}{map[string]interface{}{"id": 1, "runtime": data.Runtime(107)}}}, `{"movies":[{"id":1,"runtime":"107 mins"}]}`},
+		{"runtime minutes", responseFormat{}, 200, envelope{"movie": map[string]interface{}{"runtime": data.Runtime(107)}}, `{"movie":{"runtime":107}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			js, err := encodeResponse(tt.format, tt.status, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(js) != tt.want {
+				t.Errorf("got %s; want %s", js, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseFormatNegotiation(t *testing.T) {
+	app := &application{}
+	app.config.format.camelCase = true
+
+	tests := map[string]responseFormat{
+		"":                                       {camel: true},
+		"application/json":                       {camel: true},
+		`application/json; profile="bare"`:       {bare: true, camel: true},
+		`application/json; profile="snake_case"`: {},
+		`text/html, */*; profile="bare snake_case"`: {bare: true},
+		`text/html; profile="bare"`:                 {camel: true},
+	}
+
+	for accept, want := range tests {
+		r := httptest.NewRequest("GET", "/v1/movies", nil)
+		r.Header.Set("Accept", accept)
+
+		if got := app.responseFormat(r); got != want {
+			t.Errorf("Accept %q: got %+v; want %+v", accept, got, want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+// defaultFreezeMessage is sent when a freeze was set without a message.
+const defaultFreezeMessage = "this endpoint is temporarily read-only, please try again later"
+
+// freezeCache holds the write freezes in force. They are reloaded on the first
+// lookup after ttl has passed, so a freeze set through another instance takes
+// effect within ttl even without change notifications.
+type freezeCache struct {
+	ttl  time.Duration
+	load func() ([]*data.WriteFreeze, error)
+
+	mu      sync.Mutex
+	loaded  time.Time
+	freezes map[string]*data.WriteFreeze
+}
+
+func newFreezeCache(ttl time.Duration, load func() ([]*data.WriteFreeze, error)) *freezeCache {
+	return &freezeCache{ttl: ttl, load: load}
+}
+
+// get returns the freeze on route, or nil. If reloading fails, the freezes
+// last loaded stay in force until the next attempt, one ttl later, and the
+// error is returned alongside them.
+func (c *freezeCache) get(route string) (*data.WriteFreeze, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+
+	if time.Since(c.loaded) >= c.ttl {
+		var freezes []*data.WriteFreeze
+
+		freezes, err = c.load()
+		if err == nil {
+			c.freezes = make(map[string]*data.WriteFreeze, len(freezes))
+			for _, f := range freezes {
+				c.freezes[f.Route] = f
+			}
+		}
+		c.loaded = time.Now()
+	}
+
+	return c.freezes[route], err
+}
+
+// invalidate makes the next lookup reload the freezes.
+func (c *freezeCache) invalidate() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	c.loaded = time.Time{}
+	c.mu.Unlock()
+}
+
+// isWrite reports whether requests with method change data.
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
+
+// enforceFreeze refuses requests to route while it is frozen.
+func (app *application) enforceFreeze(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		freeze, err := app.freezes.get(route)
+		if err != nil {
+			app.logError(r, err)
+		}
+
+		if freeze != nil {
+			app.writeFrozenResponse(w, r, freeze)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// freezable reports whether route names a write endpoint that can be frozen.
+// The freeze endpoints themselves cannot be, so that a freeze can always be
+// lifted.
+func (app *application) freezable(route string) bool {
+	for _, rt := range app.routeTable {
+		if rt.name == route {
+			return isWrite(rt.method) && rt.name != "admin.freezes.set" && rt.name != "admin.freezes.delete"
+		}
+	}
+	return false
+}
+
+func (app *application) listWriteFreezesHandler(w http.ResponseWriter, r *http.Request) {
+	freezes, err := app.models.WriteFreezes.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"write_freezes": freezes}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) setWriteFreezeHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Message string `json:"message"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	freeze := &data.WriteFreeze{
+		Route:   httprouter.ParamsFromContext(r.Context()).ByName("route"),
+		Message: input.Message,
+	}
+
+	v := validator.New()
+
+	v.Check(app.freezable(freeze.Route), "route", "must be the name of a write endpoint, such as movies.create")
+
+	if data.ValidateWriteFreeze(v, freeze); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.WriteFreezes.Upsert(freeze)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.freezes.invalidate()
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"write_freeze": freeze}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteWriteFreezeHandler(w http.ResponseWriter, r *http.Request) {
+	route := httprouter.ParamsFromContext(r.Context()).ByName("route")
+
+	err := app.models.WriteFreezes.Delete(route)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r, codeWriteFreezeNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.freezes.invalidate()
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"message": "write freeze successfully lifted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
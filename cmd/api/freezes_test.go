@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/testutil"
+)
+
+func TestFreezeCache(t *testing.T) {
+	loads := 0
+	freezes := []*data.WriteFreeze{{Route: "movies.create", Message: "cleanup"}}
+	var loadErr error
+
+	c := newFreezeCache(time.Hour, func() ([]*data.WriteFreeze, error) {
+		loads++
+		return freezes, loadErr
+	})
+
+	if f, _ := c.get("movies.create"); f == nil || f.Message != "cleanup" {
+		t.Fatalf("got freeze %+v; want the movies.create freeze", f)
+	}
+	if f, _ := c.get("movies.delete"); f != nil {
+		t.Errorf("got freeze %+v for an unfrozen route; want nil", f)
+	}
+	if loads != 1 {
+		t.Errorf("got %d loads within the ttl; want 1", loads)
+	}
+
+	freezes = nil
+	c.invalidate()
+
+	if f, _ := c.get("movies.create"); f != nil {
+		t.Errorf("got freeze %+v after it was lifted; want nil", f)
+	}
+
+	// A failed reload keeps the freezes last loaded.
+	freezes = []*data.WriteFreeze{{Route: "movies.create"}}
+	c.invalidate()
+	c.get("movies.create")
+
+	loadErr = errors.New("database unavailable")
+	c.invalidate()
+
+	f, err := c.get("movies.create")
+	if err == nil || f == nil {
+		t.Errorf("got freeze %+v and error %v after a failed reload; want the old freeze and the error", f, err)
+	}
+
+	var nilCache *freezeCache
+	if f, err := nilCache.get("movies.create"); f != nil || err != nil {
+		t.Errorf("nil cache: got %+v, %v; want nil, nil", f, err)
+	}
+}
+
+func TestWriteFreeze(t *testing.T) {
+	app, ts := newTestServer(t)
+	_, adminToken := testutil.CreateUser(t, app.models, true, data.PermissionMoviesRead, data.PermissionMoviesWrite, data.PermissionAdmin)
+
+	movie := map[string]interface{}{"title": "Moana", "year": 2016, "runtime": 107, "genres": []string{"animation"}}
+
+	rs := ts.Do(t, http.MethodPut, "/v1/admin/freezes/movies.create", adminToken, map[string]string{"message": "catalogue cleanup in progress"})
+	if rs.Status != http.StatusOK {
+		t.Fatalf("freeze: got status %d; want %d: %s", rs.Status, http.StatusOK, rs.Body)
+	}
+
+	rs = ts.Do(t, http.MethodPost, "/v1/movies", adminToken, movie)
+	if rs.Status != http.StatusServiceUnavailable {
+		t.Fatalf("create while frozen: got status %d; want %d", rs.Status, http.StatusServiceUnavailable)
+	}
+
+	var frozen struct {
+		Error string    `json:"error"`
+		Code  errorCode `json:"code"`
+	}
+	rs.Decode(t, &frozen)
+	if frozen.Code != codeWriteFrozen || frozen.Error != "catalogue cleanup in progress" {
+		t.Errorf("create while frozen: got %+v; want the freeze message with code %s", frozen, codeWriteFrozen)
+	}
+
+	// Reads of the same path are not affected.
+	rs = ts.Get(t, "/v1/movies", adminToken)
+	if rs.Status != http.StatusOK {
+		t.Errorf("list while frozen: got status %d; want %d", rs.Status, http.StatusOK)
+	}
+
+	rs = ts.Do(t, http.MethodPut, "/v1/admin/freezes/admin.freezes.delete", adminToken, map[string]string{})
+	if rs.Status != http.StatusUnprocessableEntity {
+		t.Errorf("freeze the freeze endpoint: got status %d; want %d", rs.Status, http.StatusUnprocessableEntity)
+	}
+
+	rs = ts.Do(t, http.MethodDelete, "/v1/admin/freezes/movies.create", adminToken, nil)
+	if rs.Status != http.StatusOK {
+		t.Fatalf("lift: got status %d; want %d", rs.Status, http.StatusOK)
+	}
+
+	rs = ts.Do(t, http.MethodPost, "/v1/movies", adminToken, movie)
+	if rs.Status != http.StatusOK {
+		t.Errorf("create after lifting: got status %d; want %d", rs.Status, http.StatusOK)
+	}
+}
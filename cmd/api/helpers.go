@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/levisthors/greenlight/internal/data"
 	"github.com/levisthors/greenlight/internal/validator"
 )
 
@@ -27,8 +28,10 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
-func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
-	js, err := json.Marshal(data)
+func (app *application) writeJSON(w http.ResponseWriter, r *http.Request, status int, data envelope, headers http.Header) error {
+	format := app.responseFormat(r)
+
+	js, err := encodeResponse(format, status, data)
 	if err != nil {
 		return err
 	}
@@ -39,16 +42,21 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data envelo
 		w.Header()[key] = value
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Add("Vary", "Accept")
+	sendJSON(w, format, status, js)
+	return nil
+}
+
+// sendJSON writes an encoded response body labelled with its profile.
+func sendJSON(w http.ResponseWriter, format responseFormat, status int, js []byte) {
+	w.Header().Set("Content-Type", fmt.Sprintf("application/json; profile=%q", format.profile()))
 	w.WriteHeader(status)
 	w.Write(js)
-	return nil
 }
 
+// readJSON decodes a single JSON value from the request body. The body size
+// is limited by the route group's limitBody middleware.
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
-	maxBytes := 1_048_576
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
-
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
 
@@ -57,6 +65,7 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst int
 		var syntaxError *json.SyntaxError
 		var unmarshalTypeError *json.UnmarshalTypeError
 		var invalidUnmarshalError *json.InvalidUnmarshalError
+		var maxBytesError *http.MaxBytesError
 		switch {
 		case errors.As(err, &syntaxError):
 			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
@@ -72,8 +81,8 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst int
 		case strings.HasPrefix(err.Error(), "json: unknown field "):
 			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
 			return fmt.Errorf("body contains unknown key %s", fieldName)
-		case err.Error() == "http: request body too large":
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytes)
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
 		case errors.As(err, &invalidUnmarshalError):
 			panic(err)
 		default:
@@ -114,6 +123,38 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v v
 	return i
 }
 
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be true or false")
+		return defaultValue
+	}
+
+	return b
+}
+
+func (app *application) readDate(qs url.Values, key string, v *validator.Validator) *data.Date {
+	s := qs.Get(key)
+
+	if s == "" {
+		return nil
+	}
+
+	date, err := data.ParseDate(s)
+	if err != nil {
+		v.AddError(key, "must be a date in YYYY-MM-DD format")
+		return nil
+	}
+
+	return &date
+}
+
 func (app *application) readCSV(qs url.Values, key string, defaultValue []string) []string {
 	csv := qs.Get(key)
 	if csv == "" {
@@ -123,6 +164,22 @@ func (app *application) readCSV(qs url.Values, key string, defaultValue []string
 	return strings.Split(csv, ",")
 }
 
+// readIncludes parses the comma-separated include parameter, which asks for
+// related resources to be added to a response, into a set.
+func (app *application) readIncludes(qs url.Values, v *validator.Validator, allowed ...string) map[string]bool {
+	includes := make(map[string]bool)
+
+	for _, include := range app.readCSV(qs, "include", []string{}) {
+		if !validator.In(include, allowed...) {
+			v.AddError("include", fmt.Sprintf("must only contain %s", strings.Join(allowed, ", ")))
+			continue
+		}
+		includes[include] = true
+	}
+
+	return includes
+}
+
 func (app *application) background(fn func()) {
 	app.wg.Add(1)
 
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/jsonlog"
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+func FuzzReadJSON(f *testing.F) {
+	f.Add(`{"title":"Moana","year":2016,"runtime":107,"genres":["animation","adventure"]}`)
+	f.Add(`{"title": 1}`)
+	f.Add(`{"title":"a"}{"title":"b"}`)
+	f.Add(`{"unknown":true}`)
+	f.Add(`[`)
+	f.Add(``)
+	f.Add(`{"genres":[null,"x"]}`)
+	f.Add(`{"runtime":"1h47m"}`)
+	f.Add(`{"runtime":"107 mins"}`)
+
+	app := &application{logger: jsonlog.New(io.Discard, jsonlog.LevelOff)}
+
+	f.Fuzz(func(t *testing.T, body string) {
+		var input struct {
+			Title   *string       `json:"title"`
+			Year    *int32        `json:"year"`
+			Runtime *data.Runtime `json:"runtime"`
+			Genres  []string      `json:"genres"`
+		}
+
+		r := httptest.NewRequest("POST", "/v1/movies", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		err := app.readJSON(w, r, &input)
+		if err != nil && err.Error() == "" {
+			t.Errorf("readJSON returned an error with an empty message for %q", body)
+		}
+	})
+}
+
+func FuzzListMoviesQuery(f *testing.F) {
+	f.Add("title=moana&genres=animation,adventure&page=1&page_size=20&sort=-year")
+	f.Add("page=-1&page_size=0&sort=foo")
+	f.Add("page=99999999999999999999")
+	f.Add("sort=-&genres=,,")
+	f.Add("%zz")
+	f.Add("released_after=2016-11-23&released_before=2016-02-30&sort=-release_date")
+	f.Add("original_language=en&country=US&certification=PG&count=estimated&links=true")
+	f.Add("count=maybe&links=yes&country=usa")
+
+	app := &application{logger: jsonlog.New(io.Discard, jsonlog.LevelOff)}
+
+	f.Fuzz(func(t *testing.T, rawQuery string) {
+		qs, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			return
+		}
+
+		v := validator.New()
+
+		input := app.readListMoviesQuery(qs, v)
+		if !v.Valid() {
+			return
+		}
+
+		if input.Page < 1 || input.PageSize < 1 || input.PageSize > 100 {
+			t.Errorf("accepted paging out of range for %q: page %d, page_size %d", rawQuery, input.Page, input.PageSize)
+		}
+	})
+}
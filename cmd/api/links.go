@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+type link struct {
+	Href   string `json:"href"`
+	Method string `json:"method,omitempty"`
+}
+
+type links map[string]link
+
+// movieResource is a movie with its _links.
+type movieResource struct {
+	*data.Movie
+	Links links `json:"_links"`
+}
+
+// link builds a link to the named route, filling in its :param segments from
+// the given name/value pairs. The method is left out for GET links.
+func (app *application) link(name string, params ...string) (link, bool) {
+	for _, rt := range app.routeTable {
+		if rt.name != name {
+			continue
+		}
+
+		segments := strings.Split(rt.path, "/")
+		for i, segment := range segments {
+			if !strings.HasPrefix(segment, ":") {
+				continue
+			}
+			for j := 0; j+1 < len(params); j += 2 {
+				if params[j] == segment[1:] {
+					segments[i] = url.PathEscape(params[j+1])
+				}
+			}
+		}
+
+		l := link{Href: strings.Join(segments, "/")}
+		if rt.method != http.MethodGet {
+			l.Method = rt.method
+		}
+		return l, true
+	}
+
+	return link{}, false
+}
+
+// addLink adds the named route to ls, if it is registered.
+func (app *application) addLink(ls links, rel, name string, params ...string) {
+	if l, ok := app.link(name, params...); ok {
+		ls[rel] = l
+	}
+}
+
+// readLinks reports whether the client asked for _links with ?links=true.
+func (app *application) readLinks(qs url.Values, v *validator.Validator) bool {
+	return app.readBool(qs, "links", false, v)
+}
+
+func (app *application) movieLinks(movie *data.Movie) links {
+	id := strconv.FormatInt(movie.ID, 10)
+
+	ls := make(links)
+	app.addLink(ls, "self", "movies.show", "id", id)
+	app.addLink(ls, "update", "movies.update", "id", id)
+	app.addLink(ls, "delete", "movies.delete", "id", id)
+	app.addLink(ls, "related", "movies.related", "id", id)
+	return ls
+}
+
+func (app *application) movieResource(movie *data.Movie) movieResource {
+	return movieResource{Movie: movie, Links: app.movieLinks(movie)}
+}
+
+func (app *application) movieResources(movies []*data.Movie) []movieResource {
+	resources := make([]movieResource, len(movies))
+	for i, movie := range movies {
+		resources[i] = app.movieResource(movie)
+	}
+	return resources
+}
+
+// pageLinks builds self, first, prev, next and last links for a page of a
+// list route, keeping the rest of the query string. When the total is not
+// known the next link is given whenever the page was full.
+func (app *application) pageLinks(qs url.Values, filters data.Filters, metadata data.Metadata, count int, name string, params ...string) links {
+	base, ok := app.link(name, params...)
+	if !ok {
+		return nil
+	}
+
+	page := func(n int) link {
+		q := url.Values{}
+		for key, values := range qs {
+			q[key] = values
+		}
+		q.Set("page", strconv.Itoa(n))
+		return link{Href: base.Href + "?" + q.Encode()}
+	}
+
+	ls := links{
+		"self":  page(filters.Page),
+		"first": page(1),
+	}
+
+	if filters.Page > 1 {
+		ls["prev"] = page(filters.Page - 1)
+	}
+
+	switch {
+	case metadata.LastPage > 0:
+		if filters.Page < metadata.LastPage {
+			ls["next"] = page(filters.Page + 1)
+		}
+		ls["last"] = page(metadata.LastPage)
+	case count > 0 && count == filters.PageSize:
+		ls["next"] = page(filters.Page + 1)
+	}
+
+	return ls
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/levisthors/greenlight/internal/data"
+)
+
+func TestMovieLinks(t *testing.T) {
+	app := &application{}
+	app.routes()
+
+	ls := app.movieLinks(&data.Movie{ID: 42})
+
+	want := links{
+		"self":    {Href: "/v1/movies/42"},
+		"update":  {Href: "/v1/movies/42", Method: "PATCH"},
+		"delete":  {Href: "/v1/movies/42", Method: "DELETE"},
+		"related": {Href: "/v1/movies/42/related"},
+	}
+
+	if len(ls) != len(want) {
+		t.Fatalf("got %v; want %v", ls, want)
+	}
+	for rel, l := range want {
+		if ls[rel] != l {
+			t.Errorf("%s: got %+v; want %+v", rel, ls[rel], l)
+		}
+	}
+}
+
+func TestPageLinks(t *testing.T) {
+	app := &application{}
+	app.routes()
+
+	qs := url.Values{"genres": {"drama"}, "page": {"2"}}
+
+	tests := []struct {
+		name     string
+		filters  data.Filters
+		metadata data.Metadata
+		count    int
+		want     map[string]string
+	}{
+		{
+			name:     "middle page",
+			filters:  data.Filters{Page: 2, PageSize: 10},
+			metadata: data.Metadata{CurrentPage: 2, PageSize: 10, LastPage: 3},
+			count:    10,
+			want: map[string]string{
+				"self":  "/v1/movies?genres=drama&page=2",
+				"first": "/v1/movies?genres=drama&page=1",
+				"prev":  "/v1/movies?genres=drama&page=1",
+				"next":  "/v1/movies?genres=drama&page=3",
+				"last":  "/v1/movies?genres=drama&page=3",
+			},
+		},
+		{
+			name:     "last page",
+			filters:  data.Filters{Page: 3, PageSize: 10},
+			metadata: data.Metadata{CurrentPage: 3, PageSize: 10, LastPage: 3},
+			count:    4,
+			want: map[string]string{
+				"self":  "/v1/movies?genres=drama&page=3",
+				"first": "/v1/movies?genres=drama&page=1",
+				"prev":  "/v1/movies?genres=drama&page=2",
+				"last":  "/v1/movies?genres=drama&page=3",
+			},
+		},
+		{
+			name:     "full page without a count",
+			filters:  data.Filters{Page: 1, PageSize: 10},
+			metadata: data.Metadata{CurrentPage: 1, PageSize: 10, FirstPage: 1},
+			count:    10,
+			want: map[string]string{
+				"self":  "/v1/movies?genres=drama&page=1",
+				"first": "/v1/movies?genres=drama&page=1",
+				"next":  "/v1/movies?genres=drama&page=2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ls := app.pageLinks(qs, tt.filters, tt.metadata, tt.count, "movies.list")
+
+			if len(ls) != len(tt.want) {
+				t.Fatalf("got %v; want %v", ls, tt.want)
+			}
+			for rel, href := range tt.want {
+				if ls[rel].Href != href {
+					t.Errorf("%s: got %q; want %q", rel, ls[rel].Href, href)
+				}
+			}
+		})
+	}
+
+	if qs.Get("page") != "2" {
+		t.Error("pageLinks modified the request query")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const systemdFirstFD = 3
+
+// listen opens the listener for the API server. The -addr flag accepts a TCP
+// address ("host:port"), a Unix domain socket ("unix:/run/greenlight.sock") or
+// a socket inherited through systemd socket activation ("systemd", or
+// "systemd:<name>" to select one by its FileDescriptorName). When -addr is
+// empty the server listens on all interfaces at -port. A socket handed over by
+// a previous process during a graceful upgrade always takes precedence.
+func (app *application) listen() (net.Listener, error) {
+	if ln, ok, err := inheritedListener(); ok {
+		return ln, err
+	}
+
+	addr := app.config.addr
+
+	switch {
+	case addr == "":
+		return net.Listen("tcp", fmt.Sprintf(":%d", app.config.port))
+	case strings.HasPrefix(addr, "unix:"):
+		return listenUnix(strings.TrimPrefix(addr, "unix:"))
+	case addr == "systemd":
+		return systemdListener("")
+	case strings.HasPrefix(addr, "systemd:"):
+		return systemdListener(strings.TrimPrefix(addr, "systemd:"))
+	default:
+		return net.Listen("tcp", addr)
+	}
+}
+
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Stat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+
+		err = os.Remove(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Chmod(path, 0660)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	return ln, nil
+}
+
+func systemdListener(name string) (net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, errors.New("no sockets passed by systemd (LISTEN_PID not set for this process)")
+	}
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, errors.New("no sockets passed by systemd (LISTEN_FDS not set)")
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	for i := 0; i < n; i++ {
+		fdName := ""
+		if i < len(names) {
+			fdName = names[i]
+		}
+
+		if name != "" && fdName != name {
+			continue
+		}
+
+		fd := systemdFirstFD + i
+		syscall.CloseOnExec(fd)
+
+		f := os.NewFile(uintptr(fd), fdName)
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		return ln, nil
+	}
+
+	return nil, fmt.Errorf("no socket named %q passed by systemd", name)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const latencySamples = 1000
+
+// routeClass tracks in-flight requests and recent latencies for a group of
+// routes. Its concurrency limit adapts between 1 and max: it is halved while
+// the class is overloaded and grows back by one each interval once healthy.
+type routeClass struct {
+	name     string
+	max      int64
+	limit    atomic.Int64
+	inFlight atomic.Int64
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+}
+
+func newRouteClass(name string, max int) *routeClass {
+	c := &routeClass{
+		name:      name,
+		max:       int64(max),
+		latencies: make([]time.Duration, 0, latencySamples),
+	}
+	c.limit.Store(int64(max))
+	return c
+}
+
+func (c *routeClass) acquire() bool {
+	if c.inFlight.Add(1) > c.limit.Load() {
+		c.inFlight.Add(-1)
+		return false
+	}
+	return true
+}
+
+func (c *routeClass) release(duration time.Duration) {
+	c.inFlight.Add(-1)
+
+	c.mu.Lock()
+	if len(c.latencies) < latencySamples {
+		c.latencies = append(c.latencies, duration)
+	} else {
+		c.latencies[c.next] = duration
+		c.next = (c.next + 1) % latencySamples
+	}
+	c.mu.Unlock()
+}
+
+func (c *routeClass) p99() time.Duration {
+	c.mu.Lock()
+	samples := make([]time.Duration, len(c.latencies))
+	copy(samples, c.latencies)
+	c.latencies = c.latencies[:0]
+	c.next = 0
+	c.mu.Unlock()
+
+	if len(samples) == 0 {
+		return 0
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return samples[(len(samples)*99)/100]
+}
+
+func (c *routeClass) adjust(overloaded bool) {
+	limit := c.limit.Load()
+
+	switch {
+	case overloaded && limit > 1:
+		c.limit.Store(limit / 2)
+	case !overloaded && limit < c.max:
+		c.limit.Store(limit + 1)
+	}
+}
+
+func routeClassFor(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return "read"
+	default:
+		return "write"
+	}
+}
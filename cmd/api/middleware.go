@@ -1,14 +1,15 @@
 package main
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
-	"net"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/levisthors/greenlight/internal/data"
 	"github.com/levisthors/greenlight/internal/validator"
 	"golang.org/x/time/rate"
@@ -27,7 +28,37 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
+func (app *application) resolveClientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = app.contextSetClientIP(r, app.clientIP(r))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) denyIPs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.ipLists.denied(app.contextGetClientIP(r)) {
+			app.ipBlockedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimit limits each client IP to the configured rate. It is applied to
+// every request; route groups can add stricter limits with newRateLimiter.
 func (app *application) rateLimit(next http.Handler) http.Handler {
+	if !app.config.limiter.enabled {
+		return next
+	}
+
+	return app.newRateLimiter(app.config.limiter.rps, app.config.limiter.burst)(next)
+}
+
+// newRateLimiter returns middleware that limits each client IP to rps
+// requests per second with the given burst.
+func (app *application) newRateLimiter(rps float64, burst int) middleware {
 	type client struct {
 		limiter  *rate.Limiter
 		lastSeen time.Time
@@ -52,18 +83,14 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 		}
 	}()
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if app.config.limiter.enabled {
-			ip, _, err := net.SplitHostPort(r.RemoteAddr)
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
-				return
-			}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := app.contextGetClientIP(r)
 
 			mu.Lock()
 
 			if _, found := clients[ip]; !found {
-				clients[ip] = &client{limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst)}
+				clients[ip] = &client{limiter: rate.NewLimiter(rate.Limit(rps), burst)}
 			}
 
 			clients[ip].lastSeen = time.Now()
@@ -75,7 +102,122 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 			}
 
 			mu.Unlock()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitBody caps the size of request bodies at n bytes, or not at all if n is
+// zero. Handlers reading past it get an *http.MaxBytesError.
+func (app *application) limitBody(n int64) middleware {
+	if n <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// timeout answers with 503 if the handler has not finished within d. The
+// response is buffered until then, so it must not be used for streaming or
+// WebSocket routes.
+func (app *application) timeout(d time.Duration) middleware {
+	if d <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	body := `{"error": "the server took too long to process your request", "code": "` + string(codeTimeout) + `"}`
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// TimeoutHandler gives the handler an empty header map and
+			// copies it over the real one key by key when it finishes, so
+			// start it from the headers set so far (such as Vary) to keep
+			// them from being replaced.
+			outer := w.Header().Clone()
+
+			inner := http.HandlerFunc(func(tw http.ResponseWriter, r *http.Request) {
+				for key, values := range outer {
+					tw.Header()[key] = values
+				}
+				next.ServeHTTP(tw, r)
+			})
+
+			http.TimeoutHandler(inner, d, body).ServeHTTP(timeoutResponseWriter{w}, r)
+		})
+	}
+}
+
+// timeoutResponseWriter labels TimeoutHandler's own 503 response as JSON.
+type timeoutResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w timeoutResponseWriter) WriteHeader(status int) {
+	if status == http.StatusServiceUnavailable && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (app *application) shedLoad(next http.Handler) http.Handler {
+	if !app.config.shed.enabled {
+		return next
+	}
+
+	classes := map[string]*routeClass{
+		"read":  newRouteClass("read", app.config.shed.readConcurrency),
+		"write": newRouteClass("write", app.config.shed.writeConcurrency),
+	}
+
+	go func() {
+		var last sql.DBStats
+
+		for {
+			time.Sleep(time.Second)
+
+			stats := app.db.Stats()
+
+			var poolWait time.Duration
+			if n := stats.WaitCount - last.WaitCount; n > 0 {
+				poolWait = (stats.WaitDuration - last.WaitDuration) / time.Duration(n)
+			}
+			last = stats
+
+			for _, class := range classes {
+				p99 := class.p99()
+
+				overloaded := (app.config.shed.maxLatency > 0 && p99 > app.config.shed.maxLatency) ||
+					(app.config.shed.maxPoolWait > 0 && poolWait > app.config.shed.maxPoolWait)
+
+				class.adjust(overloaded)
+			}
 		}
+	}()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A WebSocket would hold its slot for as long as it stays open.
+		if websocket.IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		class := classes[routeClassFor(r)]
+
+		if !class.acquire() {
+			app.serviceOverloadedResponse(w, r)
+			return
+		}
+
+		start := time.Now()
+		defer func() {
+			class.release(time.Since(start))
+		}()
 
 		next.ServeHTTP(w, r)
 	})
@@ -84,7 +226,19 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Authorization")
-		authorizationHeader := w.Header().Get("Authorization")
+		authorizationHeader := r.Header.Get("Authorization")
+
+		// Browsers cannot set headers on WebSocket requests, so upgrades may
+		// pass the token in the query string instead. It is removed from the
+		// URL so that it does not end up in logs.
+		if authorizationHeader == "" && websocket.IsWebSocketUpgrade(r) {
+			qs := r.URL.Query()
+			if token := qs.Get("access_token"); token != "" {
+				authorizationHeader = "Bearer " + token
+				qs.Del("access_token")
+				r.URL.RawQuery = qs.Encode()
+			}
+		}
 
 		if authorizationHeader == "" {
 			r = app.contextSetUser(r, data.AnonymousUser)
@@ -92,6 +246,24 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		if scheme, params, _ := strings.Cut(authorizationHeader, " "); scheme == signatureScheme {
+			user, err := app.authenticateSignature(w, r, params)
+			if err != nil {
+				switch {
+				case errors.Is(err, errInvalidSignature):
+					app.invalidSignatureResponse(w, r)
+				default:
+					app.serverErrorResponse(w, r, err)
+				}
+				return
+			}
+
+			r = app.contextSetUser(r, user)
+			r = app.contextSetClient(r, "partner:"+parseSignatureParams(params)["keyId"])
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		headerParts := strings.Split(authorizationHeader, " ")
 		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
 			app.invalidAuthenticationTokenResponse(w, r)
@@ -119,6 +291,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		}
 
 		r = app.contextSetUser(r, user)
+		r = app.contextSetClient(r, tokenClient(token))
 
 		next.ServeHTTP(w, r)
 	})
@@ -149,3 +322,59 @@ func (app *application) requireActivatedUser(next http.HandlerFunc) http.Handler
 
 	return app.requireAuthenticatedUser(fn)
 }
+
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !permissions.Include(code) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return app.requireActivatedUser(fn)
+}
+
+// requireMoviesWrite restricts a route to users who may change the catalogue.
+func (app *application) requireMoviesWrite(next http.HandlerFunc) http.HandlerFunc {
+	return app.requirePermission(data.PermissionMoviesWrite, next)
+}
+
+// requireAdmin restricts a route to admins connecting from an address on the
+// admin allowlist.
+func (app *application) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	fn := app.requirePermission(data.PermissionAdmin, next)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !app.ipLists.adminAllowed(app.contextGetClientIP(r)) {
+			app.ipBlockedResponse(w, r)
+			return
+		}
+
+		fn.ServeHTTP(w, r)
+	}
+}
+
+// requirePartner restricts a route to requests signed with a partner key whose
+// service user may write movies.
+func (app *application) requirePartner(next http.HandlerFunc) http.HandlerFunc {
+	fn := app.requirePermission(data.PermissionMoviesWrite, next)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(app.contextGetClient(r), "partner:") {
+			app.partnerRequiredResponse(w, r)
+			return
+		}
+
+		fn.ServeHTTP(w, r)
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/levisthors/greenlight/internal/data"
 	"github.com/levisthors/greenlight/internal/validator"
@@ -11,10 +16,19 @@ import (
 
 func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title   string   `json:"title"`
-		Year    int32    `json:"year"`
-		Runtime int32    `json:"runtime"`
-		Genres  []string `json:"genres"`
+		Title            string       `json:"title"`
+		Year             int32        `json:"year"`
+		ReleaseDate      *data.Date   `json:"release_date"`
+		Runtime          data.Runtime `json:"runtime"`
+		Genres           []string     `json:"genres"`
+		Synopsis         string       `json:"synopsis"`
+		OriginalLanguage string       `json:"original_language"`
+		Country          string       `json:"country"`
+		Budget           int64        `json:"budget"`
+		BoxOffice        int64        `json:"box_office"`
+		Certification    string       `json:"certification"`
+		SeriesID         int64        `json:"series_id"`
+		SeriesOrder      int32        `json:"series_order"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -24,16 +38,40 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	movie := &data.Movie{
-		Title:   input.Title,
-		Year:    input.Year,
-		Runtime: input.Runtime,
-		Genres:  input.Genres,
+		Title:       input.Title,
+		Year:        input.Year,
+		ReleaseDate: input.ReleaseDate,
+		Runtime:     input.Runtime,
+		Genres:      input.Genres,
+
+		Synopsis:         input.Synopsis,
+		OriginalLanguage: input.OriginalLanguage,
+		Country:          input.Country,
+		Budget:           input.Budget,
+		BoxOffice:        input.BoxOffice,
+		Certification:    input.Certification,
+		SeriesOrder:      input.SeriesOrder,
+	}
+
+	if input.SeriesID != 0 {
+		movie.SeriesID = &input.SeriesID
+	}
+
+	if movie.Year == 0 && movie.ReleaseDate != nil {
+		movie.Year = int32(movie.ReleaseDate.Year())
 	}
 
 	v := validator.New()
 
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	err = app.checkMovieSeries(v, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if data.ValidateMovie(v, movie, app.models.Movies.Rules); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
+		return
 	}
 
 	err = app.models.Movies.Insert(movie)
@@ -45,7 +83,12 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
+	env := envelope{"movie": movie}
+	if app.readLinks(r.URL.Query(), validator.New()) {
+		env["movie"] = app.movieResource(movie)
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, env, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -54,7 +97,23 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
-		app.notFoundResponse(w, r)
+		app.notFoundResponse(w, r, codeMovieNotFound)
+		return
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	includes := app.readIncludes(qs, v, "series", "availability")
+
+	region := app.readString(qs, "region", "")
+	v.Check(region == "" || validator.Matches(region, data.CountryRX), "region", "must be a two-letter ISO 3166-1 code")
+
+	withLinks := app.readLinks(qs, v)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
@@ -62,14 +121,44 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
+			app.notFoundResponse(w, r, codeMovieNotFound)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	if !movie.AvailableIn(app.clientRegion(r)) {
+		app.regionRestrictedResponse(w, r)
+		return
+	}
+
+	app.recordEvent(r, "movies.view", map[string]interface{}{"movie_id": movie.ID, "genres": movie.Genres})
+
+	env := envelope{"movie": movie}
+	if withLinks {
+		env["movie"] = app.movieResource(movie)
+	}
+
+	if includes["series"] && movie.SeriesID != nil {
+		series, err := app.models.Series.Get(*movie.SeriesID)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		env["series"] = series
+	}
+
+	if includes["availability"] {
+		availability, err := app.models.Providers.GetAvailability(movie.ID, region)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		env["availability"] = availability
+	}
+
+	err = app.writeCachedJSON(w, r, movie.UpdatedAt, env)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -78,7 +167,7 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
-		app.notFoundResponse(w, r)
+		app.notFoundResponse(w, r, codeMovieNotFound)
 		return
 	}
 
@@ -86,7 +175,7 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
+			app.notFoundResponse(w, r, codeMovieNotFound)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -94,10 +183,20 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	var input struct {
-		Title   *string  `json:"title"`
-		Year    *int32   `json:"year"`
-		Runtime *int32   `json:"runtime"`
-		Genres  []string `json:"genres"`
+		Title            *string       `json:"title"`
+		Year             *int32        `json:"year"`
+		ReleaseDate      *data.Date    `json:"release_date"`
+		Runtime          *data.Runtime `json:"runtime"`
+		Genres           []string      `json:"genres"`
+		Synopsis         *string       `json:"synopsis"`
+		OriginalLanguage *string       `json:"original_language"`
+		Country          *string       `json:"country"`
+		Budget           *int64        `json:"budget"`
+		BoxOffice        *int64        `json:"box_office"`
+		Certification    *string       `json:"certification"`
+		SeriesID         *int64        `json:"series_id"`
+		SeriesOrder      *int32        `json:"series_order"`
+		Version          *int32        `json:"version"`
 	}
 
 	err = app.readJSON(w, r, &input)
@@ -106,30 +205,81 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// A client that sends the version it read gets a conflict if the movie
+	// has changed since, rather than overwriting the other edit.
+	if input.Version != nil && *input.Version != movie.Version {
+		app.editConflictResponse(w, r, &data.EditConflictError{CurrentVersion: int64(movie.Version)})
+		return
+	}
+
 	if input.Title != nil {
 		movie.Title = *input.Title
 	}
 	if input.Year != nil {
 		movie.Year = *input.Year
 	}
+	if input.ReleaseDate != nil {
+		movie.ReleaseDate = input.ReleaseDate
+		if input.Year == nil {
+			movie.Year = int32(input.ReleaseDate.Year())
+		}
+	}
 	if input.Runtime != nil {
 		movie.Runtime = *input.Runtime
 	}
 	if input.Genres != nil {
 		movie.Genres = input.Genres
 	}
+	if input.Synopsis != nil {
+		movie.Synopsis = *input.Synopsis
+	}
+	if input.OriginalLanguage != nil {
+		movie.OriginalLanguage = *input.OriginalLanguage
+	}
+	if input.Country != nil {
+		movie.Country = *input.Country
+	}
+	if input.Budget != nil {
+		movie.Budget = *input.Budget
+	}
+	if input.BoxOffice != nil {
+		movie.BoxOffice = *input.BoxOffice
+	}
+	if input.Certification != nil {
+		movie.Certification = *input.Certification
+	}
+	if input.SeriesID != nil {
+		movie.SeriesID = input.SeriesID
+		// A series_id of 0 removes the movie from its series.
+		if *input.SeriesID == 0 {
+			movie.SeriesID, movie.SeriesOrder = nil, 0
+		}
+	}
+	if input.SeriesOrder != nil {
+		movie.SeriesOrder = *input.SeriesOrder
+	}
 
 	v := validator.New()
 
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	err = app.checkMovieSeries(v, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if data.ValidateMovie(v, movie, app.models.Movies.Rules); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
+		return
 	}
 
 	err = app.models.Movies.Update(movie)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
-			app.editConflictResponse(w, r)
+			app.editConflictResponse(w, r, err)
+			return
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r, codeMovieNotFound)
 			return
 		default:
 			app.serverErrorResponse(w, r, err)
@@ -137,7 +287,12 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	env := envelope{"movie": movie}
+	if app.readLinks(r.URL.Query(), validator.New()) {
+		env["movie"] = app.movieResource(movie)
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, env, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -146,58 +301,303 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
-		app.notFoundResponse(w, r)
+		app.notFoundResponse(w, r, codeMovieNotFound)
+		return
+	}
+
+	v := validator.New()
+
+	// A dry run reports what the delete would remove without removing it.
+	dryRun := app.readBool(r.URL.Query(), "dry_run", false, v)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Movies.Delete(id)
+	deletion, err := app.models.Movies.Delete(id, dryRun)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
+			app.notFoundResponse(w, r, codeMovieNotFound)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil)
+	message := "movie successfully deleted"
+	if dryRun {
+		message = "movie would be deleted"
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"message": message, "deleted": deletion, "dry_run": dryRun}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
-func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
-	var input struct {
-		Title  string
-		Genres []string
-		data.Filters
-	}
+// listMoviesQuery is the query string of GET /v1/movies.
+type listMoviesQuery struct {
+	data.MovieQuery
+	data.Filters
+	withLinks bool
+}
 
-	v := validator.New()
-	qs := r.URL.Query()
+// readListMoviesQuery reads the query string of GET /v1/movies, recording any
+// problems with it in v.
+func (app *application) readListMoviesQuery(qs url.Values, v *validator.Validator) listMoviesQuery {
+	var input listMoviesQuery
 
 	input.Title = app.readString(qs, "title", "")
 	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.ReleasedAfter = app.readDate(qs, "released_after", v)
+	input.ReleasedBefore = app.readDate(qs, "released_before", v)
+	input.OriginalLanguage = app.readString(qs, "original_language", "")
+	input.Country = app.readString(qs, "country", "")
+	input.Certification = app.readString(qs, "certification", "")
 	input.Filters.Page = app.readInt(qs, "page", 1, *v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, *v)
 	input.Filters.Sort = app.readString(qs, "sort", "-year")
+	input.Filters.Count = app.readString(qs, "count", data.CountExact)
+
+	input.withLinks = app.readLinks(qs, v)
+
+	input.Filters.SortSafelist = []string{"id", "title", "year", "release_date", "runtime", "budget", "box_office", "-id", "-title", "-year", "-release_date", "-runtime", "-budget", "-box_office"}
+
+	data.ValidateMovieQuery(v, input.MovieQuery)
+	data.ValidateFilters(v, input.Filters)
+
+	return input
+}
+
+func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input := app.readListMoviesQuery(qs, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movies, metadata, err := app.models.Movies.GetAll(input.MovieQuery, app.clientRegion(r), input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Later pages are the same search, so only the first is recorded.
+	if input.Filters.Page == 1 {
+		app.recordEvent(r, "movies.search", searchEventData(qs, metadata.TotalRecords))
+	}
+
+	env := envelope{"movies": movies, "metadata": metadata}
+	if input.withLinks {
+		env["movies"] = app.movieResources(movies)
+		env["_links"] = app.pageLinks(qs, input.Filters, metadata, len(movies), "movies.list")
+	}
+
+	// Lists have no Last-Modified: the newest movie on the page says nothing
+	// about movies deleted or no longer matching since.
+	err = app.writeCachedJSON(w, r, time.Time{}, env)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listRelatedMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r, codeMovieNotFound)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
 
-	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	// Related movies are always ordered by similarity.
+	filters := data.Filters{
+		Page:         app.readInt(qs, "page", 1, *v),
+		PageSize:     app.readInt(qs, "page_size", 20, *v),
+		Sort:         "-score",
+		SortSafelist: []string{"-score"},
+	}
+
+	withLinks := app.readLinks(qs, v)
+
+	// Movies the user has already watched are left out unless they ask for
+	// them.
+	excludeWatchedBy := app.contextGetUser(r).ID
+	if s := qs.Get("include_watched"); s != "" {
+		include, err := strconv.ParseBool(s)
+		if err != nil {
+			v.AddError("include_watched", "must be true or false")
+		}
+		if include {
+			excludeWatchedBy = 0
+		}
+	}
 
-	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+	if data.ValidateFilters(v, filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r, codeMovieNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	region := app.clientRegion(r)
+	if !movie.AvailableIn(region) {
+		app.regionRestrictedResponse(w, r)
+		return
+	}
+
+	movies, metadata, err := app.models.Movies.GetRelated(movie.ID, excludeWatchedBy, region, filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	env := envelope{"movies": movies, "metadata": metadata}
+	if withLinks {
+		env["movies"] = app.movieResources(movies)
+		env["_links"] = app.pageLinks(qs, filters, metadata, len(movies), "movies.related", "id", strconv.FormatInt(movie.ID, 10))
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, env, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+func (app *application) bulkUpdateMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Filter struct {
+			Title            string     `json:"title"`
+			Genres           []string   `json:"genres"`
+			ReleasedAfter    *data.Date `json:"released_after"`
+			ReleasedBefore   *data.Date `json:"released_before"`
+			OriginalLanguage string     `json:"original_language"`
+			Country          string     `json:"country"`
+			Certification    string     `json:"certification"`
+		} `json:"filter"`
+		Changes struct {
+			RenameGenre *struct {
+				From string `json:"from"`
+				To   string `json:"to"`
+			} `json:"rename_genre"`
+			AddGenre         string `json:"add_genre"`
+			RemoveGenre      string `json:"remove_genre"`
+			RuntimeDelta     int32  `json:"runtime_delta"`
+			OriginalLanguage string `json:"original_language"`
+			Country          string `json:"country"`
+			Certification    string `json:"certification"`
+		} `json:"changes"`
+		DryRun bool `json:"dry_run"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	// dry_run may be set in the query, like on DELETE, or in the body.
+	if app.readBool(r.URL.Query(), "dry_run", false, v) {
+		input.DryRun = true
+	}
+
+	q := data.MovieQuery(input.Filter)
+
+	changes := data.MovieChanges{
+		AddGenre:         input.Changes.AddGenre,
+		RemoveGenre:      input.Changes.RemoveGenre,
+		RuntimeDelta:     input.Changes.RuntimeDelta,
+		OriginalLanguage: input.Changes.OriginalLanguage,
+		Country:          input.Changes.Country,
+		Certification:    input.Changes.Certification,
+	}
+	if input.Changes.RenameGenre != nil {
+		changes.RenameGenreFrom = input.Changes.RenameGenre.From
+		changes.RenameGenreTo = input.Changes.RenameGenre.To
+	}
+
+	// Refuse to touch the whole catalogue by accident.
+	v.Check(!q.IsEmpty(), "filter", "must contain at least one criterion")
+	data.ValidateMovieQuery(v, q)
+
+	if data.ValidateMovieChanges(v, changes); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	affected, err := app.models.Movies.UpdateAll(q, changes, input.DryRun)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidBulkUpdate):
+			v.AddError("changes", "would leave some matching movies invalid, for example with no genres or a runtime under a minute")
+			app.rejectedDataResponse(w, r, err, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"affected": affected, "dry_run": input.DryRun}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// importMoviesHandler accepts a CSV file in the format of the "movie import"
+// command and imports it in the background, returning an operation to poll.
+func (app *application) importMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit))
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		app.badRequestResponse(w, r, errors.New("body must not be empty"))
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	op, err := app.startOperation(user.ID, data.OperationImport, func(run *operationRun) (map[string]interface{}, error) {
+		reader := &progressReader{r: bytes.NewReader(body), total: len(body), report: run.progress}
+
+		imported, failed, err := app.importMovies(reader, func(line int, err error) {
+			run.addError(fmt.Sprintf("line %d: %s", line, err))
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{"imported": imported, "failed": failed}, nil
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeOperationAccepted(w, r, op, envelope{})
+}
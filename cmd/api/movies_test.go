@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/testutil"
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+func TestMovieLifecycle(t *testing.T) {
+	app, ts := newTestServer(t)
+	_, token := testutil.CreateUser(t, app.models, true, data.PermissionMoviesRead, data.PermissionMoviesWrite)
+
+	rs := ts.Get(t, "/v1/movies", "")
+	if rs.Status != http.StatusUnauthorized {
+		t.Fatalf("anonymous list: got status %d; want %d", rs.Status, http.StatusUnauthorized)
+	}
+
+	rs = ts.Do(t, http.MethodPost, "/v1/movies", token, map[string]interface{}{
+		"title":   "Moana",
+		"year":    2016,
+		"runtime": 107,
+		"genres":  []string{"animation", "adventure"},
+	})
+	if rs.Status != http.StatusOK {
+		t.Fatalf("create: got status %d; want %d: %s", rs.Status, http.StatusOK, rs.Body)
+	}
+
+	var created struct {
+		Movie data.Movie `json:"movie"`
+	}
+	rs.Decode(t, &created)
+
+	path := fmt.Sprintf("/v1/movies/%d", created.Movie.ID)
+
+	rs = ts.Get(t, path, token)
+	if rs.Status != http.StatusOK {
+		t.Fatalf("show: got status %d; want %d", rs.Status, http.StatusOK)
+	}
+
+	rs = ts.Do(t, http.MethodDelete, path, token, nil)
+	if rs.Status != http.StatusOK {
+		t.Fatalf("delete: got status %d; want %d", rs.Status, http.StatusOK)
+	}
+
+	rs = ts.Get(t, path, token)
+	if rs.Status != http.StatusNotFound {
+		t.Fatalf("show after delete: got status %d; want %d", rs.Status, http.StatusNotFound)
+	}
+
+	var notFound struct {
+		Code errorCode `json:"code"`
+	}
+	rs.Decode(t, &notFound)
+	if notFound.Code != codeMovieNotFound {
+		t.Errorf("show after delete: got code %q; want %q", notFound.Code, codeMovieNotFound)
+	}
+}
+
+func TestBulkUpdateMovies(t *testing.T) {
+	app, ts := newTestServer(t)
+	_, token := testutil.CreateUser(t, app.models, true, data.PermissionMoviesRead, data.PermissionMoviesWrite, data.PermissionAdmin)
+
+	for _, genres := range [][]string{{"sci-fi", "action"}, {"sci-fi", "science-fiction"}, {"drama", "romance"}} {
+		err := app.models.Movies.Insert(&data.Movie{Title: "Movie", Year: 2000, Runtime: 100, Genres: genres})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body := map[string]interface{}{
+		"filter":  map[string]interface{}{"genres": []string{"sci-fi"}},
+		"changes": map[string]interface{}{"rename_genre": map[string]string{"from": "sci-fi", "to": "science-fiction"}},
+		"dry_run": true,
+	}
+
+	var result struct {
+		Affected int64 `json:"affected"`
+	}
+
+	rs := ts.Do(t, http.MethodPatch, "/v1/movies", token, body)
+	if rs.Status != http.StatusOK {
+		t.Fatalf("dry run: got status %d; want %d: %s", rs.Status, http.StatusOK, rs.Body)
+	}
+	if rs.Decode(t, &result); result.Affected != 2 {
+		t.Fatalf("dry run: affected %d; want 2", result.Affected)
+	}
+
+	body["dry_run"] = false
+
+	// The query parameter works as well as the body field.
+	rs = ts.Do(t, http.MethodPatch, "/v1/movies?dry_run=true", token, body)
+	if rs.Decode(t, &result); rs.Status != http.StatusOK || result.Affected != 2 {
+		t.Fatalf("dry run from the query: got status %d, affected %d; want %d, 2", rs.Status, result.Affected, http.StatusOK)
+	}
+
+	rs = ts.Do(t, http.MethodPatch, "/v1/movies", token, body)
+	if rs.Status != http.StatusOK {
+		t.Fatalf("update: got status %d; want %d: %s", rs.Status, http.StatusOK, rs.Body)
+	}
+
+	if rs.Decode(t, &result); result.Affected != 2 {
+		t.Fatalf("update: affected %d; want 2", result.Affected)
+	}
+
+	// Movies that already hold the new values are neither changed nor
+	// counted.
+	rs = ts.Do(t, http.MethodPatch, "/v1/movies", token, map[string]interface{}{
+		"filter":  map[string]interface{}{"genres": []string{"drama"}},
+		"changes": map[string]interface{}{"add_genre": "romance"},
+	})
+	if rs.Decode(t, &result); rs.Status != http.StatusOK || result.Affected != 0 {
+		t.Fatalf("no-op update: got status %d, affected %d; want %d, 0", rs.Status, result.Affected, http.StatusOK)
+	}
+
+	// A runtime change that would take a movie under a minute is refused.
+	rs = ts.Do(t, http.MethodPatch, "/v1/movies", token, map[string]interface{}{
+		"filter":  map[string]interface{}{"genres": []string{"drama"}},
+		"changes": map[string]interface{}{"runtime_delta": -100},
+	})
+	if rs.Status != http.StatusUnprocessableEntity {
+		t.Fatalf("runtime to zero: got status %d; want %d: %s", rs.Status, http.StatusUnprocessableEntity, rs.Body)
+	}
+
+	movies, _, err := app.models.Movies.GetAll(data.MovieQuery{Genres: []string{"science-fiction"}}, data.AnyRegion, data.Filters{Page: 1, PageSize: 10, Sort: "id", SortSafelist: []string{"id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(movies) != 2 {
+		t.Fatalf("got %d science-fiction movies; want 2", len(movies))
+	}
+	for _, movie := range movies {
+		if !validator.Unique(movie.Genres) {
+			t.Errorf("movie %d has duplicate genres %v", movie.ID, movie.Genres)
+		}
+	}
+}
+
+func TestDeleteMovieDryRun(t *testing.T) {
+	app, ts := newTestServer(t)
+	user, token := testutil.CreateUser(t, app.models, true, data.PermissionMoviesRead, data.PermissionMoviesWrite)
+
+	movie := &data.Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}}
+	if err := app.models.Movies.Insert(movie); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := app.models.History.Insert(&data.Watch{UserID: user.ID, MovieID: movie.ID, WatchedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := fmt.Sprintf("/v1/movies/%d", movie.ID)
+
+	var result struct {
+		Deleted data.MovieDeletion `json:"deleted"`
+		DryRun  bool               `json:"dry_run"`
+	}
+
+	rs := ts.Do(t, http.MethodDelete, path+"?dry_run=true", token, nil)
+	if rs.Status != http.StatusOK {
+		t.Fatalf("dry run: got status %d; want %d: %s", rs.Status, http.StatusOK, rs.Body)
+	}
+	rs.Decode(t, &result)
+	if !result.DryRun || result.Deleted.WatchHistory != 2 {
+		t.Errorf("dry run: got %+v; want a dry run deleting 2 watch history entries", result)
+	}
+
+	if rs := ts.Get(t, path, token); rs.Status != http.StatusOK {
+		t.Fatalf("show after dry run: got status %d; want %d", rs.Status, http.StatusOK)
+	}
+
+	rs = ts.Do(t, http.MethodDelete, path, token, nil)
+	if rs.Status != http.StatusOK {
+		t.Fatalf("delete: got status %d; want %d", rs.Status, http.StatusOK)
+	}
+	rs.Decode(t, &result)
+	if result.DryRun || result.Deleted.WatchHistory != 2 {
+		t.Errorf("delete: got %+v; want 2 watch history entries deleted", result)
+	}
+
+	if rs := ts.Do(t, http.MethodDelete, path+"?dry_run=true", token, nil); rs.Status != http.StatusNotFound {
+		t.Errorf("dry run on a deleted movie: got status %d; want %d", rs.Status, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/lib/pq"
+)
+
+// listenForChanges applies the change notifications sent by every instance's
+// models until ctx is done. pq.Listener reconnects by itself; notifications
+// sent while it was disconnected are lost, so state is reloaded in full after
+// a reconnect.
+func (app *application) listenForChanges(ctx context.Context, dsn string) {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"job": "listener"})
+		}
+	})
+	defer listener.Close()
+
+	err := listener.Listen(data.ChangesChannel)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"job": "listener"})
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.Notify:
+			// A nil notification means the connection was re-established.
+			if n == nil {
+				app.reloadChangedState()
+				continue
+			}
+
+			var change data.Change
+
+			err := json.Unmarshal([]byte(n.Extra), &change)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"job": "listener", "payload": n.Extra})
+				continue
+			}
+
+			app.applyChange(change)
+		case <-time.After(90 * time.Second):
+			// Check the connection is still alive when things are quiet.
+			go listener.Ping()
+		}
+	}
+}
+
+func (app *application) applyChange(change data.Change) {
+	app.db.Forget(change)
+
+	switch change.Entity {
+	case data.EntityMovie:
+		app.cache.purge()
+		app.hub.publish(topicCatalog, catalogEvent{Action: change.Action, MovieID: change.ID, Count: change.Count})
+	case data.EntityIPRule:
+		err := app.loadIPRules()
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"job": "listener"})
+		}
+	case data.EntityWriteFreeze:
+		app.freezes.invalidate()
+	case data.EntityUser:
+		if change.Action == data.ChangeDeleted {
+			app.usage.forget(change.ID)
+		}
+	}
+}
+
+func (app *application) reloadChangedState() {
+	app.db.Forget(data.Change{Entity: data.EntityMovie, Action: data.ChangeBulkUpdated})
+	app.freezes.invalidate()
+
+	err := app.loadIPRules()
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"job": "listener"})
+	}
+}
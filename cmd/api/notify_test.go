@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+)
+
+func TestApplyChange(t *testing.T) {
+	app := &application{hub: newEventHub(), usage: newUsageTracker(&fakeUsageStore{})}
+
+	c := &wsClient{
+		send:   make(chan []byte, 1),
+		topics: map[string]bool{topicCatalog: true},
+		done:   make(chan struct{}),
+	}
+	app.hub.register(c)
+
+	app.applyChange(data.Change{Entity: data.EntityMovie, Action: data.ChangeUpdated, ID: 9})
+
+	var e struct {
+		Topic string       `json:"topic"`
+		Data  catalogEvent `json:"data"`
+	}
+	if err := json.Unmarshal(<-c.send, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Topic != topicCatalog || e.Data.Action != data.ChangeUpdated || e.Data.MovieID != 9 {
+		t.Errorf("got %+v", e)
+	}
+
+	now := time.Now()
+	app.usage.used(3, now)
+	app.applyChange(data.Change{Entity: data.EntityUser, Action: data.ChangeDeleted, ID: 3})
+
+	if _, ok := app.usage.totals[3]; ok {
+		t.Error("erased user's usage total was kept")
+	}
+}
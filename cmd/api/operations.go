@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+)
+
+// maxOperationErrors caps the errors kept on an operation, so that a badly
+// broken import does not store one error per row.
+const maxOperationErrors = 100
+
+const (
+	// operationHeartbeat is how often a process refreshes the operations it
+	// is running.
+	operationHeartbeat = 30 * time.Second
+	// operationStaleAfter is how long an unfinished operation may go without
+	// a heartbeat before it is taken to be abandoned and marked failed.
+	operationStaleAfter = 3 * operationHeartbeat
+)
+
+// operationRun is passed to an operation's work function for reporting
+// progress and non-fatal errors.
+type operationRun struct {
+	app       *application
+	op        *data.Operation
+	lastSaved time.Time
+}
+
+// progress records how far through the work is, as a percentage. It is saved
+// at most once a second.
+func (run *operationRun) progress(percent int) {
+	if percent <= run.op.Progress || percent >= 100 {
+		return
+	}
+	run.op.Progress = percent
+
+	if time.Since(run.lastSaved) >= time.Second {
+		run.save()
+	}
+}
+
+func (run *operationRun) addError(err string) {
+	if len(run.op.Errors) < maxOperationErrors {
+		run.op.Errors = append(run.op.Errors, err)
+	}
+}
+
+func (run *operationRun) save() {
+	run.lastSaved = time.Now()
+
+	err := run.app.models.Operations.Update(run.op)
+	if err != nil {
+		run.app.logger.PrintError(err, map[string]string{"operation_id": fmt.Sprint(run.op.ID)})
+	}
+}
+
+// startOperation records a new operation and runs work for it in the
+// background. It returns a snapshot of the operation to send in the 202
+// response; the work's result becomes the operation's result.
+func (app *application) startOperation(userID int64, kind string, work func(run *operationRun) (map[string]interface{}, error)) (*data.Operation, error) {
+	op := &data.Operation{UserID: userID, Kind: kind, Owner: app.instance}
+
+	err := app.models.Operations.Insert(op)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := *op
+
+	app.background(func() {
+		run := &operationRun{app: app, op: op}
+
+		op.Status = data.OperationRunning
+		run.save()
+
+		result, err := work(run)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"operation_id": fmt.Sprint(op.ID), "kind": kind})
+
+			op.Status = data.OperationFailed
+			op.Errors = append(op.Errors, err.Error())
+		} else {
+			op.Status = data.OperationSucceeded
+			op.Progress = 100
+			op.Result = result
+		}
+
+		run.save()
+	})
+
+	return &snapshot, nil
+}
+
+// runOperationHeartbeat keeps the operations this process is running marked
+// as live and fails those abandoned by processes that have gone, until ctx is
+// done. It must outlive the operations themselves, so it is not one of the
+// background jobs stopped at shutdown before they are waited for.
+func (app *application) runOperationHeartbeat(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := app.models.Operations.Heartbeat(app.instance)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"job": "operations"})
+			}
+
+			err = app.failAbandonedOperations()
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"job": "operations"})
+			}
+		}
+	}
+}
+
+func (app *application) failAbandonedOperations() error {
+	abandoned, err := app.models.Operations.FailAbandoned(operationStaleAfter)
+	if err != nil {
+		return err
+	}
+	if abandoned > 0 {
+		app.logger.PrintInfo("marked abandoned operations as failed", map[string]string{"count": fmt.Sprint(abandoned)})
+	}
+	return nil
+}
+
+func operationLocation(op *data.Operation) string {
+	return fmt.Sprintf("/v1/operations/%d", op.ID)
+}
+
+func (app *application) writeOperationAccepted(w http.ResponseWriter, r *http.Request, op *data.Operation, env envelope) {
+	env["operation"] = op
+
+	headers := make(http.Header)
+	headers.Set("Location", operationLocation(op))
+
+	err := app.writeJSON(w, r, http.StatusAccepted, env, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showOperationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r, codeOperationNotFound)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	op, err := app.models.Operations.GetForUser(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r, codeOperationNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	if !op.Finished() {
+		headers.Set("Retry-After", "2")
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"operation": op}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// progressReader reports the percentage of its contents read so far.
+type progressReader struct {
+	r      io.Reader
+	read   int
+	total  int
+	report func(percent int)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += n
+
+	if p.total > 0 {
+		p.report(p.read * 100 / p.total)
+	}
+
+	return n, err
+}
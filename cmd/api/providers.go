@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+func (app *application) createProviderHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string `json:"name"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	provider := &data.Provider{Name: input.Name}
+
+	v := validator.New()
+
+	if data.ValidateProvider(v, provider); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Providers.Insert(provider)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateProvider):
+			v.AddError("name", "a provider with this name already exists")
+			app.rejectedDataResponse(w, r, err, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, envelope{"provider": provider}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	providers, err := app.models.Providers.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"providers": providers}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) setMovieAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r, codeMovieNotFound)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r, codeMovieNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		ProviderID int64  `json:"provider_id"`
+		Region     string `json:"region"`
+		Type       string `json:"type"`
+		URL        string `json:"url"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	availability := &data.Availability{
+		MovieID:    movie.ID,
+		ProviderID: input.ProviderID,
+		Region:     input.Region,
+		Type:       input.Type,
+		URL:        input.URL,
+	}
+
+	v := validator.New()
+
+	if data.ValidateAvailability(v, availability); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	provider, err := app.models.Providers.Get(availability.ProviderID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("provider_id", "must refer to an existing provider")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	availability.ProviderName = provider.Name
+
+	err = app.models.Providers.SetAvailability(availability)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"availability": availability}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r, codeMovieNotFound)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	providerID := int64(app.readInt(qs, "provider_id", 0, *v))
+	region := app.readString(qs, "region", "")
+	availabilityType := app.readString(qs, "type", "")
+
+	v.Check(providerID > 0, "provider_id", "must be provided")
+	v.Check(region != "", "region", "must be provided")
+	v.Check(validator.In(availabilityType, data.AvailabilityTypes...), "type", "must be one of stream, rent or buy")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Providers.DeleteAvailability(id, providerID, region, availabilityType)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r, codeAvailabilityNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"message": "movie availability successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const publicCacheEntries = 1000
+
+// publicRead lets anonymous clients through to the movie read endpoints when
+// the server runs in public read mode. Anonymous requests get their own,
+// stricter rate limit and are answered from a short-lived response cache;
+// authenticated requests skip both and are handled as usual.
+func (app *application) publicRead() middleware {
+	// The limiter is shared by every route in the group.
+	var limit middleware
+	if app.config.limiter.enabled {
+		limit = app.newRateLimiter(app.config.public.rps, app.config.public.burst)
+	}
+
+	return func(next http.Handler) http.Handler {
+		anonymous := next
+		if app.cache != nil {
+			anonymous = app.cache.middleware(anonymous, app.clientRegion)
+		}
+		if limit != nil {
+			anonymous = limit(anonymous)
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if app.contextGetUser(r).IsAnonymous() {
+				anonymous.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type cachedResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// responseCache holds successful anonymous GET responses, keyed by host, URL,
+// Accept header and client region, for a fixed time. It is emptied whenever a movie changes.
+type responseCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{ttl: ttl, entries: make(map[string]*cachedResponse)}
+}
+
+func (c *responseCache) get(key string) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil
+	}
+	return entry
+}
+
+// put stores the response, first dropping expired entries if the cache is
+// full. Responses are not cached while it stays full.
+func (c *responseCache) put(key string, entry *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= publicCacheEntries {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= publicCacheEntries {
+			return
+		}
+	}
+
+	c.entries[key] = entry
+}
+
+func (c *responseCache) purge() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	clear(c.entries)
+	c.mu.Unlock()
+}
+
+// middleware answers requests from the cache. region gives the client's
+// region, as the movies clients may see depend on it.
+func (c *responseCache) middleware(next http.Handler, region func(*http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.Host + r.URL.RequestURI() + "\n" + r.Header.Get("Accept") + "\n" + region(r)
+
+		if entry := c.get(key); entry != nil {
+			for k, v := range entry.header {
+				w.Header()[k] = v
+			}
+			w.Header().Set("X-Cache", "HIT")
+
+			if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, entry.header.Get("ETag")) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			w.WriteHeader(entry.status)
+			w.Write(entry.body)
+			return
+		}
+
+		w.Header().Set("X-Cache", "MISS")
+
+		rec := &recordingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		if rec.status == http.StatusOK {
+			header := w.Header().Clone()
+			header.Del("X-Cache")
+
+			c.put(key, &cachedResponse{
+				status:  rec.status,
+				header:  header,
+				body:    rec.body.Bytes(),
+				expires: time.Now().Add(c.ttl),
+			})
+		}
+	})
+}
+
+// recordingResponseWriter passes a response through while keeping a copy of
+// its status and body.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rw *recordingResponseWriter) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingResponseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
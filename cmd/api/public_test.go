@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/jsonlog"
+)
+
+func TestPublicRead(t *testing.T) {
+	app := &application{logger: jsonlog.New(io.Discard, jsonlog.LevelOff), cache: newResponseCache(time.Minute)}
+	app.config.public.enabled = true
+
+	calls := 0
+	handler := app.publicRead()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("ETag", `"1-1"`)
+		w.Write([]byte(`{"movie":{}}`))
+	}))
+
+	get := func(user *data.User, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/v1/movies/1", nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		r = app.contextSetUser(r, user)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := get(data.AnonymousUser, nil); w.Header().Get("X-Cache") != "MISS" || calls != 1 {
+		t.Fatalf("first request: X-Cache %q, %d calls", w.Header().Get("X-Cache"), calls)
+	}
+
+	w := get(data.AnonymousUser, nil)
+	if w.Header().Get("X-Cache") != "HIT" || calls != 1 {
+		t.Fatalf("second request: X-Cache %q, %d calls", w.Header().Get("X-Cache"), calls)
+	}
+	if w.Body.String() != `{"movie":{}}` || w.Header().Get("ETag") != `"1-1"` {
+		t.Errorf("cached response is %q with ETag %q", w.Body, w.Header().Get("ETag"))
+	}
+
+	if w := get(data.AnonymousUser, http.Header{"If-None-Match": {`"1-1"`}}); w.Code != http.StatusNotModified {
+		t.Errorf("conditional request got status %d; want 304", w.Code)
+	}
+
+	if w := get(data.AnonymousUser, http.Header{"Accept": {`application/json; profile="bare"`}}); w.Header().Get("X-Cache") != "MISS" {
+		t.Error("responses for different Accept headers share a cache entry")
+	}
+
+	app.regions = &regionSource{header: "Cf-Ipcountry"}
+	app.config.network.trustedProxies, _ = parsePrefixes("192.0.2.1")
+	if w := get(data.AnonymousUser, http.Header{"Cf-Ipcountry": {"DE"}}); w.Header().Get("X-Cache") != "MISS" {
+		t.Error("responses for different client regions share a cache entry")
+	}
+	app.regions = nil
+	app.config.network.trustedProxies = nil
+
+	calls = 0
+	if w := get(&data.User{ID: 1, Activated: true}, nil); w.Header().Get("X-Cache") != "" || calls != 1 {
+		t.Errorf("authenticated request: X-Cache %q, %d calls; want no caching", w.Header().Get("X-Cache"), calls)
+	}
+
+	app.applyChange(data.Change{Entity: data.EntityMovie, Action: data.ChangeUpdated, ID: 1})
+
+	if w := get(data.AnonymousUser, nil); w.Header().Get("X-Cache") != "MISS" {
+		t.Error("cache was not emptied when a movie changed")
+	}
+}
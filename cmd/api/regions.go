@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/geoip"
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+// regionSource works out which country clients are in, from a header set by a
+// trusted proxy (see -trusted-proxies) or from a GeoIP lookup of the client
+// address.
+type regionSource struct {
+	header string
+	geoip  *geoip.DB
+}
+
+// newRegionSource parses the -region-source flag. An empty source gives a nil
+// regionSource, which turns region restrictions off.
+func newRegionSource(source string) (*regionSource, error) {
+	if source == "" {
+		return nil, nil
+	}
+
+	kind, arg, _ := strings.Cut(source, ":")
+
+	switch {
+	case kind == "header" && arg != "":
+		return &regionSource{header: http.CanonicalHeaderKey(arg)}, nil
+	case kind == "geoip" && arg != "":
+		db, err := geoip.Open(arg)
+		if err != nil {
+			return nil, err
+		}
+		return &regionSource{geoip: db}, nil
+	default:
+		return nil, errors.New("-region-source must be header:<name> or geoip:<path>")
+	}
+}
+
+// clientRegion returns the two-letter country code of the client, "" if it
+// cannot be worked out, or data.AnyRegion if region restrictions are off.
+func (app *application) clientRegion(r *http.Request) string {
+	if app.regions == nil {
+		return data.AnyRegion
+	}
+
+	var region string
+
+	// The header is only believed from a trusted proxy, as clients could
+	// otherwise set it to get around the restrictions.
+	if app.regions.header != "" {
+		if app.fromTrustedProxy(r) {
+			region = strings.ToUpper(strings.TrimSpace(r.Header.Get(app.regions.header)))
+		}
+	} else if addr, err := netip.ParseAddr(app.contextGetClientIP(r)); err == nil {
+		region = app.regions.geoip.Country(addr)
+	}
+
+	if !validator.Matches(region, data.CountryRX) {
+		return ""
+	}
+	return region
+}
+
+func (app *application) setMovieRegionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r, codeMovieNotFound)
+		return
+	}
+
+	var input struct {
+		AllowedRegions []string `json:"allowed_regions"`
+		BlockedRegions []string `json:"blocked_regions"`
+		Version        *int32   `json:"version"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateRegions(v, input.AllowedRegions, input.BlockedRegions); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r, codeMovieNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if input.Version != nil && *input.Version != movie.Version {
+		app.editConflictResponse(w, r, &data.EditConflictError{CurrentVersion: int64(movie.Version)})
+		return
+	}
+
+	movie.AllowedRegions = input.AllowedRegions
+	movie.BlockedRegions = input.BlockedRegions
+
+	err = app.models.Movies.SetRegions(movie)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r, err)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r, codeMovieNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"movie": movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/geoip"
+	"github.com/levisthors/greenlight/internal/testutil"
+)
+
+func TestClientRegion(t *testing.T) {
+	app := &application{}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies", nil)
+	r.Header.Set("CF-IPCountry", "de")
+
+	if got := app.clientRegion(r); got != data.AnyRegion {
+		t.Errorf("no region source: got %q; want %q", got, data.AnyRegion)
+	}
+
+	var err error
+	app.regions, err = newRegionSource("header:cf-ipcountry")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := app.clientRegion(r); got != "" {
+		t.Errorf("header source, untrusted peer: got %q; want an unknown region", got)
+	}
+
+	// httptest requests come from 192.0.2.1.
+	app.config.network.trustedProxies, err = parsePrefixes("192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := app.clientRegion(r); got != "DE" {
+		t.Errorf("header source, trusted proxy: got %q; want %q", got, "DE")
+	}
+
+	r.Header.Set("CF-IPCountry", "unknown")
+	if got := app.clientRegion(r); got != "" {
+		t.Errorf("header source with a bad value: got %q; want an unknown region", got)
+	}
+
+	r = app.contextSetUser(r, data.AnonymousUser)
+	w := httptest.NewRecorder()
+	app.setCacheHeaders(w, r, time.Time{}, `"1-1"`)
+	if got := w.Header().Get("Vary"); got != "Cf-Ipcountry" {
+		t.Errorf("header source: got Vary %q; want the region header", got)
+	}
+
+	app.regions = &regionSource{geoip: &geoip.DB{}}
+	w = httptest.NewRecorder()
+	app.setCacheHeaders(w, r, time.Time{}, `"1-1"`)
+	if got := w.Header().Get("Cache-Control"); !strings.HasPrefix(got, "private") {
+		t.Errorf("geoip source: got Cache-Control %q; want a private response", got)
+	}
+
+	for _, source := range []string{"header:", "geoip", "ip:CF-IPCountry"} {
+		if _, err := newRegionSource(source); err == nil {
+			t.Errorf("newRegionSource(%q) succeeded; want an error", source)
+		}
+	}
+}
+
+func TestRegionRestrictions(t *testing.T) {
+	app, ts := newTestServer(t)
+	_, token := testutil.CreateUser(t, app.models, true, data.PermissionMoviesRead, data.PermissionMoviesWrite)
+	_, adminToken := testutil.CreateUser(t, app.models, true, data.PermissionMoviesRead, data.PermissionMoviesWrite, data.PermissionAdmin)
+
+	// The test server's clients all connect from the loopback address.
+	db, err := geoip.Load(strings.NewReader("127.0.0.0,127.255.255.255,DE\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.regions = &regionSource{geoip: db}
+
+	open := &data.Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}}
+	blocked := &data.Movie{Title: "Coco", Year: 2017, Runtime: 105, Genres: []string{"animation"}}
+	for _, movie := range []*data.Movie{open, blocked} {
+		if err := app.models.Movies.Insert(movie); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := fmt.Sprintf("/v1/movies/%d/regions", blocked.ID)
+
+	rs := ts.Do(t, http.MethodPut, path, token, map[string]interface{}{"blocked_regions": []string{"DE"}})
+	if rs.Status != http.StatusForbidden {
+		t.Errorf("set regions as a non-admin: got status %d; want %d", rs.Status, http.StatusForbidden)
+	}
+
+	rs = ts.Do(t, http.MethodPut, path, adminToken, map[string]interface{}{"blocked_regions": []string{"DE"}})
+	if rs.Status != http.StatusOK {
+		t.Fatalf("set regions: got status %d; want %d: %s", rs.Status, http.StatusOK, rs.Body)
+	}
+
+	rs = ts.Get(t, fmt.Sprintf("/v1/movies/%d", blocked.ID), token)
+	if rs.Status != http.StatusUnavailableForLegalReasons {
+		t.Errorf("show blocked movie: got status %d; want %d", rs.Status, http.StatusUnavailableForLegalReasons)
+	}
+
+	rs = ts.Get(t, fmt.Sprintf("/v1/movies/%d/related", open.ID), token)
+	var related struct {
+		Movies []data.Movie `json:"movies"`
+	}
+	rs.Decode(t, &related)
+	if len(related.Movies) != 0 {
+		t.Errorf("related movies include %+v; want the blocked movie left out", related.Movies)
+	}
+
+	var list struct {
+		Movies []data.Movie `json:"movies"`
+	}
+
+	ts.Get(t, "/v1/movies", token).Decode(t, &list)
+	if len(list.Movies) != 1 || list.Movies[0].ID != open.ID {
+		t.Errorf("list in DE: got %+v; want only %q", list.Movies, open.Title)
+	}
+
+	// With restrictions off, every movie is shown.
+	app.regions = nil
+
+	ts.Get(t, "/v1/movies", token).Decode(t, &list)
+	if len(list.Movies) != 2 {
+		t.Errorf("list with restrictions off: got %d movies; want 2", len(list.Movies))
+	}
+
+	rs = ts.Get(t, fmt.Sprintf("/v1/movies/%d", blocked.ID), token)
+	if rs.Status != http.StatusOK {
+		t.Errorf("show with restrictions off: got status %d; want %d", rs.Status, http.StatusOK)
+	}
+}
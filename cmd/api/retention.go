@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/jsonlog"
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+const defaultRetention = "tokens=30d,operations=90d,exports=30d,notifications=180d"
+
+// retentionPolicy purges a target's rows once they are older than maxAge.
+type retentionPolicy struct {
+	target string
+	maxAge time.Duration
+}
+
+// parseRetention parses policies such as "tokens=30d,usage=400d". Ages are Go
+// durations, with d for days also allowed.
+func parseRetention(s string) ([]retentionPolicy, error) {
+	var policies []retentionPolicy
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		target, age, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid retention policy %q (want target=age)", item)
+		}
+
+		if !validator.In(target, data.RetentionTargets()...) {
+			return nil, fmt.Errorf("unknown retention target %q (must be one of %s)", target, strings.Join(data.RetentionTargets(), ", "))
+		}
+
+		var maxAge time.Duration
+		if days, found := strings.CutSuffix(age, "d"); found {
+			n, err := strconv.Atoi(days)
+			if err != nil {
+				return nil, fmt.Errorf("invalid age %q for %s", age, target)
+			}
+			maxAge = time.Duration(n) * 24 * time.Hour
+		} else {
+			d, err := time.ParseDuration(age)
+			if err != nil {
+				return nil, fmt.Errorf("invalid age %q for %s", age, target)
+			}
+			maxAge = d
+		}
+
+		if maxAge <= 0 {
+			return nil, fmt.Errorf("age for %s must be positive", target)
+		}
+
+		policies = append(policies, retentionPolicy{target: target, maxAge: maxAge})
+	}
+
+	return policies, nil
+}
+
+type purgeReport struct {
+	Target string    `json:"target"`
+	Cutoff time.Time `json:"cutoff"`
+	Rows   int64     `json:"rows"`
+	DryRun bool      `json:"dry_run"`
+	Err    error     `json:"-"`
+}
+
+// applyRetention runs each policy in turn. In a dry run the rows that would
+// be deleted are counted instead. A failing policy does not stop the others.
+func (app *application) applyRetention(policies []retentionPolicy, dryRun bool, now time.Time) []purgeReport {
+	reports := make([]purgeReport, len(policies))
+
+	for i, policy := range policies {
+		report := purgeReport{Target: policy.target, Cutoff: now.Add(-policy.maxAge), DryRun: dryRun}
+
+		if dryRun {
+			report.Rows, report.Err = app.models.Retention.Count(report.Target, report.Cutoff)
+		} else {
+			report.Rows, report.Err = app.models.Retention.Purge(report.Target, report.Cutoff)
+		}
+
+		reports[i] = report
+	}
+
+	return reports
+}
+
+func (app *application) logPurgeReports(reports []purgeReport) {
+	for _, report := range reports {
+		properties := map[string]string{
+			"job":     "retention",
+			"target":  report.Target,
+			"cutoff":  report.Cutoff.Format(time.RFC3339),
+			"rows":    strconv.FormatInt(report.Rows, 10),
+			"dry_run": strconv.FormatBool(report.DryRun),
+		}
+
+		if report.Err != nil {
+			app.logger.PrintError(report.Err, properties)
+			continue
+		}
+
+		app.logger.PrintInfo("retention policy applied", properties)
+	}
+}
+
+// runRetention applies the configured policies every interval until ctx is
+// done.
+func (app *application) runRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		app.logPurgeReports(app.applyRetention(app.config.retention.policies, app.config.retention.dryRun, time.Now()))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func retentionCommand(args []string, logger *jsonlog.Logger) error {
+	sub, args, err := subcommand(args, "retention")
+	if err != nil {
+		return err
+	}
+	if sub != "run" {
+		return fmt.Errorf("retention: unknown subcommand %q", sub)
+	}
+
+	var cfg config
+	var policies string
+	var dryRun bool
+
+	fs := flag.NewFlagSet("retention run", flag.ExitOnError)
+	registerDBFlags(fs, &cfg)
+	fs.StringVar(&policies, "retention", defaultRetention, "Comma-separated target=age policies (targets: "+strings.Join(data.RetentionTargets(), ", ")+")")
+	fs.BoolVar(&dryRun, "dry-run", false, "Report how many rows would be deleted without deleting them")
+	fs.Parse(args)
+
+	cfg.retention.policies, err = parseRetention(policies)
+	if err != nil {
+		return err
+	}
+
+	app, cleanup, err := newCLIApplication(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	reports := app.applyRetention(cfg.retention.policies, dryRun, time.Now())
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tOLDER THAN\tROWS\tRESULT")
+
+	var failed int
+
+	for _, report := range reports {
+		result := "deleted"
+		switch {
+		case report.Err != nil:
+			result = "error: " + report.Err.Error()
+			failed++
+		case report.DryRun:
+			result = "would delete"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", report.Target, report.Cutoff.Format(time.RFC3339), report.Rows, result)
+	}
+
+	tw.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("retention run: %d policies failed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRetention(t *testing.T) {
+	policies, err := parseRetention("tokens=30d, usage=8760h,operations=90d")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []retentionPolicy{
+		{"tokens", 30 * 24 * time.Hour},
+		{"usage", 8760 * time.Hour},
+		{"operations", 90 * 24 * time.Hour},
+	}
+
+	if len(policies) != len(want) {
+		t.Fatalf("got %v; want %v", policies, want)
+	}
+	for i := range want {
+		if policies[i] != want[i] {
+			t.Errorf("policy %d: got %v; want %v", i, policies[i], want[i])
+		}
+	}
+
+	for _, s := range []string{"movies=30d", "tokens", "tokens=soon", "tokens=-1h", "tokens=0d"} {
+		if _, err := parseRetention(s); err == nil {
+			t.Errorf("parseRetention(%q): expected an error", s)
+		}
+	}
+
+	if policies, err := parseRetention(defaultRetention); err != nil || len(policies) == 0 {
+		t.Errorf("default policies: got %v, %v", policies, err)
+	}
+}
@@ -2,27 +2,291 @@ package main
 
 import (
 	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 )
 
+// route is a registered endpoint. The route table recorded by routes() is
+// used to build the _links in resource payloads and to answer 404s and 405s.
+type route struct {
+	name   string
+	method string
+	path   string
+}
+
+// middleware wraps a handler. Route groups apply a list of them, in order, to
+// every route registered through the group.
+type middleware func(http.Handler) http.Handler
+
+// requirement adapts the require* checks to middleware.
+func requirement(check func(http.HandlerFunc) http.HandlerFunc) middleware {
+	return func(next http.Handler) http.Handler {
+		return check(next.ServeHTTP)
+	}
+}
+
+// routeGroup registers routes that share middleware, such as an access check
+// or a body size limit. Groups are values; with returns a new group and
+// leaves the receiver unchanged.
+type routeGroup struct {
+	app    *application
+	router *httprouter.Router
+	chain  []middleware
+}
+
+func (g routeGroup) with(mw ...middleware) routeGroup {
+	chain := make([]middleware, 0, len(g.chain)+len(mw))
+	chain = append(chain, g.chain...)
+	chain = append(chain, mw...)
+
+	return routeGroup{app: g.app, router: g.router, chain: chain}
+}
+
+func (g routeGroup) handle(name, method, path string, handler http.Handler) {
+	if isWrite(method) {
+		handler = g.app.enforceFreeze(name, handler)
+	}
+
+	for i := len(g.chain) - 1; i >= 0; i-- {
+		handler = g.chain[i](handler)
+	}
+
+	g.router.Handler(method, path, handler)
+	g.record(name, method, path)
+}
+
+// record adds a route to the route table without registering a handler, for
+// routes dispatched by another route's handler.
+func (g routeGroup) record(name, method, path string) {
+	g.app.routeTable = append(g.app.routeTable, route{name: name, method: method, path: path})
+}
+
+// paramRoute sends requests whose named parameter equals value to match and
+// the rest to next. httprouter cannot register a static segment alongside a
+// parameter, so this is how /v1/movies/feed.atom coexists with /v1/movies/:id.
+func paramRoute(name, value string, match, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if httprouter.ParamsFromContext(r.Context()).ByName(name) == value {
+			match.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) routes() http.Handler {
 	router := httprouter.New()
 
-	router.NotFound = http.HandlerFunc(app.notFoundResponse)
+	router.NotFound = http.HandlerFunc(app.routeNotFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
-	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.requireActivatedUser(app.healthCheckHandler))
-	router.HandlerFunc(http.MethodGet, "/v1/movies", app.requireActivatedUser(app.listMoviesHandler))
-	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requireActivatedUser(app.createMovieHandler))
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.requireActivatedUser(app.showMovieHandler))
-	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.requireActivatedUser(app.updateMovieHandler))
-	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.requireActivatedUser(app.deleteMovieHandler))
+	app.routeTable = nil
+
+	base := routeGroup{app: app, router: router}
+
+	// Ordinary JSON endpoints get a small body limit and a timeout. Requests
+	// are checked against the OpenAPI document after the access checks, so
+	// that an unauthenticated client gets 401 rather than a 422.
+	limited := base.with(app.limitBody(app.config.limits.maxBody), app.timeout(app.config.limits.timeout))
+	public := limited.with(app.validateRequests)
+	account := limited.with(requirement(app.requireAuthenticatedUser), app.validateRequests)
+	activated := limited.with(requirement(app.requireActivatedUser), app.validateRequests)
+	admin := limited.with(requirement(app.requireAdmin), app.validateRequests)
+	writer := limited.with(requirement(app.requireMoviesWrite), app.validateRequests)
+
+	// In public read mode anyone may read the catalogue; see publicRead.
+	reader := activated
+	if app.config.public.enabled {
+		reader = limited.with(app.publicRead(), app.validateRequests)
+	}
+
+	// Uploads, downloads and long-lived connections get a larger body limit,
+	// no timeout and their own, stricter rate limit.
+	heavy := base.with(app.limitBody(app.config.limits.heavyMaxBody))
+	if app.config.limiter.enabled {
+		heavy = heavy.with(app.newRateLimiter(app.config.limits.heavyRPS, app.config.limits.heavyBurst))
+	}
+	heavyAdmin := heavy.with(requirement(app.requireAdmin))
+	partner := limited.with(requirement(app.requirePartner), app.validateRequests)
+
+	activated.handle("healthcheck", http.MethodGet, "/v1/healthcheck", http.HandlerFunc(app.healthCheckHandler))
+	reader.handle("movies.list", http.MethodGet, "/v1/movies", http.HandlerFunc(app.listMoviesHandler))
+	activated.handle("movies.create", http.MethodPost, "/v1/movies", http.HandlerFunc(app.createMovieHandler))
+	admin.handle("movies.bulkUpdate", http.MethodPatch, "/v1/movies", http.HandlerFunc(app.bulkUpdateMoviesHandler))
+	heavyAdmin.handle("movies.import", http.MethodPost, "/v1/movies/import", http.HandlerFunc(app.importMoviesHandler))
+	reader.handle("movies.show", http.MethodGet, "/v1/movies/:id", paramRoute("id", "feed.atom", http.HandlerFunc(app.movieFeedHandler), http.HandlerFunc(app.showMovieHandler)))
+	reader.record("movies.feed", http.MethodGet, "/v1/movies/feed.atom")
+	activated.handle("movies.update", http.MethodPatch, "/v1/movies/:id", http.HandlerFunc(app.updateMovieHandler))
+	activated.handle("movies.delete", http.MethodDelete, "/v1/movies/:id", http.HandlerFunc(app.deleteMovieHandler))
+	reader.handle("movies.related", http.MethodGet, "/v1/movies/:id/related", http.HandlerFunc(app.listRelatedMoviesHandler))
+
+	admin.handle("movies.availability.set", http.MethodPut, "/v1/movies/:id/availability", http.HandlerFunc(app.setMovieAvailabilityHandler))
+	admin.handle("movies.availability.delete", http.MethodDelete, "/v1/movies/:id/availability", http.HandlerFunc(app.deleteMovieAvailabilityHandler))
+	admin.handle("movies.regions.set", http.MethodPut, "/v1/movies/:id/regions", http.HandlerFunc(app.setMovieRegionsHandler))
+
+	activated.handle("providers.list", http.MethodGet, "/v1/providers", http.HandlerFunc(app.listProvidersHandler))
+	admin.handle("providers.create", http.MethodPost, "/v1/providers", http.HandlerFunc(app.createProviderHandler))
+
+	writer.handle("series.create", http.MethodPost, "/v1/series", http.HandlerFunc(app.createSeriesHandler))
+	activated.handle("series.show", http.MethodGet, "/v1/series/:id", http.HandlerFunc(app.showSeriesHandler))
+
+	partner.handle("sync.movies.show", http.MethodGet, "/v1/sync/movies", http.HandlerFunc(app.showSyncCursorHandler))
+	partner.handle("sync.movies.apply", http.MethodPost, "/v1/sync/movies", http.HandlerFunc(app.syncMoviesHandler))
+
+	public.handle("users.register", http.MethodPost, "/v1/users", http.HandlerFunc(app.registerUserHandler))
+	public.handle("users.activate", http.MethodPut, "/v1/users/activated", http.HandlerFunc(app.activateUserHandler))
+
+	activated.handle("me.show", http.MethodGet, "/v1/me", http.HandlerFunc(app.showCurrentUserHandler))
+	activated.handle("me.update", http.MethodPatch, "/v1/me", http.HandlerFunc(app.updateCurrentUserHandler))
+
+	activated.handle("me.export.request", http.MethodPost, "/v1/me/export", http.HandlerFunc(app.requestUserExportHandler))
+	heavy.handle("me.export.download", http.MethodGet, "/v1/me/export", http.HandlerFunc(app.downloadUserExportHandler))
+
+	account.handle("me.deletion.request", http.MethodPost, "/v1/me/deletion", http.HandlerFunc(app.requestUserErasureHandler))
+	account.handle("me.deletion.cancel", http.MethodDelete, "/v1/me/deletion", http.HandlerFunc(app.cancelUserErasureHandler))
+
+	activated.handle("me.history.list", http.MethodGet, "/v1/me/history", http.HandlerFunc(app.listHistoryHandler))
+	activated.handle("me.history.record", http.MethodPost, "/v1/me/history", http.HandlerFunc(app.recordWatchHandler))
+
+	activated.handle("me.searches.list", http.MethodGet, "/v1/me/searches", http.HandlerFunc(app.listSavedSearchesHandler))
+	activated.handle("me.searches.create", http.MethodPost, "/v1/me/searches", http.HandlerFunc(app.createSavedSearchHandler))
+	activated.handle("me.searches.delete", http.MethodDelete, "/v1/me/searches/:id", http.HandlerFunc(app.deleteSavedSearchHandler))
+
+	activated.handle("me.notifications.list", http.MethodGet, "/v1/me/notifications", http.HandlerFunc(app.listNotificationsHandler))
+	activated.handle("me.notifications.delete", http.MethodDelete, "/v1/me/notifications/:id", http.HandlerFunc(app.deleteNotificationHandler))
+
+	activated.handle("me.usage", http.MethodGet, "/v1/me/usage", http.HandlerFunc(app.showUsageHandler))
+
+	heavyAdmin.handle("ws", http.MethodGet, "/v1/ws", http.HandlerFunc(app.wsHandler))
+
+	activated.handle("operations.show", http.MethodGet, "/v1/operations/:id", http.HandlerFunc(app.showOperationHandler))
+
+	public.handle("tokens.authentication", http.MethodPut, "/v1/tokens/authentication", http.HandlerFunc(app.createAuthenticationTokenHandler))
+
+	admin.handle("admin.ipRules.list", http.MethodGet, "/v1/admin/ip-rules", http.HandlerFunc(app.listIPRulesHandler))
+	admin.handle("admin.ipRules.create", http.MethodPost, "/v1/admin/ip-rules", http.HandlerFunc(app.createIPRuleHandler))
+	admin.handle("admin.ipRules.delete", http.MethodDelete, "/v1/admin/ip-rules/:id", http.HandlerFunc(app.deleteIPRuleHandler))
+
+	admin.handle("admin.freezes.list", http.MethodGet, "/v1/admin/freezes", http.HandlerFunc(app.listWriteFreezesHandler))
+	admin.handle("admin.freezes.set", http.MethodPut, "/v1/admin/freezes/:route", http.HandlerFunc(app.setWriteFreezeHandler))
+	admin.handle("admin.freezes.delete", http.MethodDelete, "/v1/admin/freezes/:route", http.HandlerFunc(app.deleteWriteFreezeHandler))
+
+	reader.handle("sitemap", http.MethodGet, "/sitemap.xml", http.HandlerFunc(app.sitemapHandler))
+
+	return app.recoverPanic(app.resolveClientIP(app.denyIPs(app.shedLoad(app.rateLimit(app.authenticate(app.trackUsage(router)))))))
+}
+
+// allowedMethods returns the methods registered for path, sorted, with
+// OPTIONS added as the router answers it for every route.
+func (app *application) allowedMethods(path string) []string {
+	seen := map[string]bool{}
+	for _, rt := range app.routeTable {
+		if _, cost := matchRoute(rt.path, path); cost == 0 {
+			seen[rt.method] = true
+		}
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+	seen[http.MethodOptions] = true
+
+	methods := make([]string, 0, len(seen))
+	for method := range seen {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// maxSuggestions is the most "did you mean" paths a 404 response lists.
+const maxSuggestions = 3
+
+// suggestRoutes returns registered paths close to path, nearest first, with
+// the request's own values filled in for route parameters.
+func (app *application) suggestRoutes(path string) []string {
+	type suggestion struct {
+		path string
+		cost int
+	}
+
+	var found []suggestion
+	seen := map[string]bool{}
+
+	for _, rt := range app.routeTable {
+		suggested, cost := matchRoute(rt.path, path)
+		if cost <= 0 || cost > 3 || seen[suggested] {
+			continue
+		}
+		seen[suggested] = true
+		found = append(found, suggestion{suggested, cost})
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].cost != found[j].cost {
+			return found[i].cost < found[j].cost
+		}
+		return found[i].path < found[j].path
+	})
+
+	var paths []string
+	for i := 0; i < len(found) && i < maxSuggestions; i++ {
+		paths = append(paths, found[i].path)
+	}
+	return paths
+}
+
+// matchRoute compares a request path with a route's path, segment by
+// segment. It returns the route's path with the request's parameter values
+// filled in, and the total edit distance of the literal segments: 0 for an
+// exact match, or -1 if the paths are too far apart to be a near miss.
+func matchRoute(routePath, path string) (string, int) {
+	want := strings.Split(routePath, "/")
+	got := strings.Split(path, "/")
+	if len(want) != len(got) {
+		return "", -1
+	}
+
+	cost := 0
+	for i, segment := range want {
+		if strings.HasPrefix(segment, ":") {
+			if got[i] == "" {
+				return "", -1
+			}
+			want[i] = got[i]
+			continue
+		}
+
+		d := editDistance(got[i], segment)
+		if d > max(1, len(segment)/3) {
+			return "", -1
+		}
+		cost += d
+	}
+
+	return strings.Join(want, "/"), cost
+}
 
-	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
-	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
 
-	router.HandlerFunc(http.MethodPut, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
 
-	return app.recoverPanic(app.rateLimit(app.authenticate(router)))
+	return prev[len(b)]
 }
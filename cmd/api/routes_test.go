@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/levisthors/greenlight/internal/jsonlog"
+)
+
+func TestRouteGroupChain(t *testing.T) {
+	app := &application{}
+
+	var order []string
+	mark := func(name string) middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	router := httprouter.New()
+	base := routeGroup{app: app, router: router}
+	outer := base.with(mark("outer"))
+	inner := outer.with(mark("inner"))
+
+	inner.handle("test", http.MethodGet, "/test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}))
+	outer.handle("other", http.MethodGet, "/other", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	if got := strings.Join(order, ","); got != "outer,inner,handler" {
+		t.Errorf("got order %s", got)
+	}
+
+	order = nil
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/other", nil))
+
+	if got := strings.Join(order, ","); got != "outer" {
+		t.Errorf("inner middleware leaked into the outer group: %s", got)
+	}
+
+	if len(app.routeTable) != 2 {
+		t.Errorf("got %d routes in the table; want 2", len(app.routeTable))
+	}
+}
+
+func TestLimitBodyAndTimeout(t *testing.T) {
+	app := &application{}
+
+	handler := app.limitBody(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input struct{}
+		if err := app.readJSON(w, r, &input); err == nil || !strings.Contains(err.Error(), "larger than 4 bytes") {
+			t.Errorf("got error %v", err)
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"title": "Moana"}`)))
+
+	slow := app.timeout(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+
+	w := httptest.NewRecorder()
+	w.Header().Set("Vary", "Authorization")
+	slow.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("got %d with Content-Type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), `"code": "TIMEOUT"`) {
+		t.Errorf("got body %s", w.Body)
+	}
+
+	fast := app.timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	w = httptest.NewRecorder()
+	w.Header().Set("Vary", "Authorization")
+	fast.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := w.Header().Values("Vary"); len(got) != 2 {
+		t.Errorf("got Vary %v; want Authorization and Accept", got)
+	}
+}
+
+func TestRouteNotFoundSuggestions(t *testing.T) {
+	app := &application{logger: jsonlog.New(io.Discard, jsonlog.LevelOff)}
+	routes := app.routes()
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/v1/movie", []string{"/v1/movies"}},
+		{"/v1/movie/7/relatd", []string{"/v1/movies/7/related"}},
+		{"/v1/nothing-like-it", nil},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("%s: got status %d", tt.path, rr.Code)
+		}
+
+		var body struct {
+			Code        string   `json:"code"`
+			Suggestions []string `json:"suggestions"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+
+		if body.Code != string(codeRouteNotFound) {
+			t.Errorf("%s: got code %q", tt.path, body.Code)
+		}
+		if !slices.Equal(body.Suggestions, tt.want) {
+			t.Errorf("%s: got suggestions %v; want %v", tt.path, body.Suggestions, tt.want)
+		}
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	app := &application{logger: jsonlog.New(io.Discard, jsonlog.LevelOff)}
+	routes := app.routes()
+
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/v1/movies", nil))
+
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("got status %d", rr.Code)
+	}
+	if got := rr.Header().Get("Allow"); got != "GET, OPTIONS, PATCH, POST" {
+		t.Errorf("got Allow %q", got)
+	}
+
+	var body struct {
+		Code           string   `json:"code"`
+		AllowedMethods []string `json:"allowed_methods"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != string(codeMethodNotAllowed) || len(body.AllowedMethods) != 4 {
+		t.Errorf("got body %s", rr.Body)
+	}
+}
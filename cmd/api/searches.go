@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+func (app *application) createSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name   string `json:"name"`
+		Filter struct {
+			Title            string     `json:"title"`
+			Genres           []string   `json:"genres"`
+			ReleasedAfter    *data.Date `json:"released_after"`
+			ReleasedBefore   *data.Date `json:"released_before"`
+			OriginalLanguage string     `json:"original_language"`
+			Country          string     `json:"country"`
+			Certification    string     `json:"certification"`
+		} `json:"filter"`
+		Notify []string `json:"notify"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	search := &data.SavedSearch{
+		UserID: user.ID,
+		Name:   input.Name,
+		Filter: data.MovieQuery(input.Filter),
+		Notify: input.Notify,
+		Region: app.clientRegion(r),
+	}
+
+	v := validator.New()
+
+	if data.ValidateSavedSearch(v, search); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	existing, err := app.models.SavedSearches.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if len(existing) >= data.MaxSavedSearches {
+		v.AddError("name", "you already have the maximum of "+strconv.Itoa(data.MaxSavedSearches)+" saved searches")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.SavedSearches.Insert(search)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusCreated, envelope{"saved_search": search}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listSavedSearchesHandler(w http.ResponseWriter, r *http.Request) {
+	searches, err := app.models.SavedSearches.GetAllForUser(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"saved_searches": searches}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r, codeSavedSearchNotFound)
+		return
+	}
+
+	err = app.models.SavedSearches.Delete(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r, codeSavedSearchNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"message": "saved search successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	// Notifications are always newest first.
+	filters := data.Filters{
+		Page:         app.readInt(qs, "page", 1, *v),
+		PageSize:     app.readInt(qs, "page_size", 20, *v),
+		Sort:         "-id",
+		SortSafelist: []string{"-id"},
+	}
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	notifications, metadata, err := app.models.Notifications.GetForUser(app.contextGetUser(r).ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"notifications": notifications, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r, codeNotificationNotFound)
+		return
+	}
+
+	err = app.models.Notifications.Delete(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r, codeNotificationNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"message": "notification successfully dismissed"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// runSavedSearches checks saved searches against newly added movies every
+// interval until ctx is done.
+func (app *application) runSavedSearches(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		app.checkSavedSearches()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkSavedSearches notifies users of new movies matching their saved
+// searches. In-app notifications are recorded as each search is checked;
+// emails are sent afterwards and are not retried if sending fails.
+func (app *application) checkSavedSearches() {
+	ids, err := app.models.SavedSearches.GetDue()
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"job": "saved_searches"})
+		return
+	}
+
+	for _, id := range ids {
+		search, movies, err := app.models.SavedSearches.Check(id, app.regions != nil)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"job": "saved_searches", "saved_search_id": strconv.FormatInt(id, 10)})
+			continue
+		}
+
+		if search == nil || len(movies) == 0 || !search.Notifies(data.NotifyEmail) {
+			continue
+		}
+
+		err = app.emailSearchMatches(search, movies)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"job": "saved_searches", "saved_search_id": strconv.FormatInt(id, 10)})
+		}
+	}
+}
+
+func (app *application) emailSearchMatches(search *data.SavedSearch, movies []*data.Movie) error {
+	user, err := app.models.Users.Get(search.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Deactivated and erased accounts get no email.
+	if !user.Activated {
+		return nil
+	}
+
+	return app.mailer.Send(user.Email, "saved_search_match.tmpl", map[string]interface{}{
+		"searchID":   search.ID,
+		"searchName": search.Name,
+		"movies":     movies,
+	})
+}
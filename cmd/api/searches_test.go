@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/testutil"
+)
+
+func TestSavedSearchNotifications(t *testing.T) {
+	app, ts := newTestServer(t)
+	_, token := testutil.CreateUser(t, app.models, true, data.PermissionMoviesRead, data.PermissionMoviesWrite)
+
+	insert := func(title string, genres ...string) {
+		t.Helper()
+
+		err := app.models.Movies.Insert(&data.Movie{Title: title, Year: 2000, Runtime: 100, Genres: genres})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Movies added before the search is saved are not notified about.
+	insert("Old Drama", "drama")
+
+	rs := ts.Do(t, http.MethodPost, "/v1/me/searches", token, map[string]interface{}{
+		"name":   "Dramas",
+		"filter": map[string]interface{}{"genres": []string{"drama"}},
+		"notify": []string{data.NotifyInApp},
+	})
+	if rs.Status != http.StatusCreated {
+		t.Fatalf("create: got status %d; want %d: %s", rs.Status, http.StatusCreated, rs.Body)
+	}
+
+	var created struct {
+		SavedSearch data.SavedSearch `json:"saved_search"`
+	}
+	rs.Decode(t, &created)
+
+	insert("New Drama", "drama")
+	insert("New Comedy", "comedy")
+
+	app.checkSavedSearches()
+	// A second check finds nothing new and must not notify twice.
+	app.checkSavedSearches()
+
+	var list struct {
+		Notifications []data.Notification `json:"notifications"`
+	}
+
+	rs = ts.Get(t, "/v1/me/notifications", token)
+	if rs.Status != http.StatusOK {
+		t.Fatalf("list: got status %d; want %d", rs.Status, http.StatusOK)
+	}
+	rs.Decode(t, &list)
+
+	if len(list.Notifications) != 1 || list.Notifications[0].Title != "New Drama" {
+		t.Fatalf("got notifications %+v; want one for New Drama", list.Notifications)
+	}
+	if got := list.Notifications[0].SavedSearchID; got != created.SavedSearch.ID {
+		t.Errorf("got saved_search_id %d; want %d", got, created.SavedSearch.ID)
+	}
+
+	rs = ts.Do(t, http.MethodDelete, fmt.Sprintf("/v1/me/notifications/%d", list.Notifications[0].ID), token, nil)
+	if rs.Status != http.StatusOK {
+		t.Fatalf("dismiss: got status %d; want %d", rs.Status, http.StatusOK)
+	}
+
+	rs = ts.Do(t, http.MethodDelete, fmt.Sprintf("/v1/me/searches/%d", created.SavedSearch.ID), token, nil)
+	if rs.Status != http.StatusOK {
+		t.Fatalf("delete search: got status %d; want %d", rs.Status, http.StatusOK)
+	}
+
+	rs = ts.Do(t, http.MethodDelete, fmt.Sprintf("/v1/me/searches/%d", created.SavedSearch.ID), token, nil)
+	if rs.Status != http.StatusNotFound {
+		t.Errorf("delete search again: got status %d; want %d", rs.Status, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/secrets"
+	"github.com/lib/pq"
+)
+
+// resolveSecrets replaces flag values that reference Vault or AWS Secrets
+// Manager (vault://mount/path#key, awssm://secret-id#key) with the secrets
+// they point to. The database DSN and SMTP credentials are kept as refreshable
+// values so that rotated credentials are picked up without a restart; the
+// encryption keys and debug password are only read at startup.
+func resolveSecrets(cfg *config) error {
+	resolver := secrets.FromEnv()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var err error
+
+	cfg.secrets.resolver = resolver
+
+	cfg.secrets.dsn, err = resolver.Value(ctx, cfg.db.dsn)
+	if err != nil {
+		return err
+	}
+	cfg.secrets.smtpUsername, err = resolver.Value(ctx, cfg.smtp.username)
+	if err != nil {
+		return err
+	}
+	cfg.secrets.smtpPassword, err = resolver.Value(ctx, cfg.smtp.password)
+	if err != nil {
+		return err
+	}
+
+	for _, value := range []*string{&cfg.encryption.keys, &cfg.encryption.hmacKey, &cfg.debug.password} {
+		*value, err = resolver.Resolve(ctx, *value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// refreshSecrets re-reads the refreshable secrets every interval until ctx is
+// done.
+func (app *application) refreshSecrets(ctx context.Context, interval time.Duration) {
+	s := app.config.secrets
+
+	s.resolver.Refresh(ctx, interval, func(err error) {
+		app.logger.PrintError(err, map[string]string{"job": "secrets"})
+	}, s.dsn, s.smtpUsername, s.smtpPassword)
+}
+
+// dsnConnector opens each new database connection with the current DSN, so
+// connections made after a credential rotation use the new password while
+// existing connections are left to expire.
+type dsnConnector struct {
+	dsn func() string
+}
+
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	connector, err := pq.NewConnector(c.dsn())
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
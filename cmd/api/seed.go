@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/jsonlog"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	seedGenres = []string{
+		"action", "adventure", "animation", "comedy", "crime", "documentary", "drama", "family",
+		"fantasy", "history", "horror", "music", "mystery", "romance", "sci-fi", "thriller", "war", "western",
+	}
+	seedTitleAdjectives = []string{
+		"Silent", "Last", "Broken", "Golden", "Hidden", "Crimson", "Endless", "Forgotten", "Midnight",
+		"Burning", "Frozen", "Distant", "Wild", "Electric", "Savage", "Quiet", "Lost", "Iron",
+	}
+	seedTitleNouns = []string{
+		"River", "Empire", "Horizon", "Garden", "Kingdom", "Signal", "Harbor", "Frontier", "Station",
+		"Orchard", "Mirror", "Voyage", "Machine", "Summer", "Highway", "Lighthouse", "Storm", "Circus",
+	}
+	seedFirstNames = []string{
+		"Alice", "Bob", "Carol", "Dave", "Erin", "Frank", "Grace", "Heidi", "Ivan", "Judy",
+		"Mallory", "Niaj", "Olivia", "Peggy", "Rupert", "Sybil", "Trent", "Victor", "Walter", "Zoe",
+	}
+	seedLastNames = []string{
+		"Anderson", "Brown", "Clark", "Davis", "Evans", "Garcia", "Harris", "Jones", "King", "Lewis",
+		"Martin", "Nelson", "Moore", "Parker", "Robinson", "Smith", "Taylor", "Walker", "White", "Young",
+	}
+)
+
+func seedCommand(args []string, logger *jsonlog.Logger) error {
+	var cfg config
+	var input struct {
+		movies   int
+		users    int
+		password string
+		seed     int64
+	}
+
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	fs.StringVar(&cfg.env, "env", "development", "environment (development|staging|production)")
+	registerDBFlags(fs, &cfg)
+	fs.IntVar(&input.movies, "movies", 1000, "Number of movies to generate")
+	fs.IntVar(&input.users, "users", 10, "Number of users to generate")
+	fs.StringVar(&input.password, "password", "pa55word", "Password set on every generated user")
+	fs.Int64Var(&input.seed, "seed", time.Now().UnixNano(), "Random seed, for reproducible data sets")
+	fs.Parse(args)
+
+	if cfg.env == "production" {
+		return fmt.Errorf("seed: refusing to run against %s", cfg.env)
+	}
+
+	app, cleanup, err := newCLIApplication(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	rng := rand.New(rand.NewSource(input.seed))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	start := time.Now()
+
+	tx, err := app.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = seedMovies(ctx, tx, rng, input.movies)
+	if err != nil {
+		return err
+	}
+
+	err = seedUsers(ctx, tx, app.models.Users.Keys, rng, input.users, input.password)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("seeded %d movies and %d users in %s (seed %d)\n", input.movies, input.users, time.Since(start).Round(time.Millisecond), input.seed)
+	return nil
+}
+
+func copyRows(ctx context.Context, tx *data.Tx, table string, columns []string, n int, row func(i int) []interface{}) error {
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i := 0; i < n; i++ {
+		_, err = stmt.ExecContext(ctx, row(i)...)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = stmt.ExecContext(ctx)
+	return err
+}
+
+func seedMovies(ctx context.Context, tx *data.Tx, rng *rand.Rand, n int) error {
+	lastYear := time.Now().Year() - 1
+
+	return copyRows(ctx, tx, "movies", []string{"title", "year", "runtime", "genres"}, n, func(i int) []interface{} {
+		title := seedTitleAdjectives[rng.Intn(len(seedTitleAdjectives))] + " " + seedTitleNouns[rng.Intn(len(seedTitleNouns))]
+		if rng.Intn(4) == 0 {
+			title = fmt.Sprintf("%s %d", title, rng.Intn(3)+2)
+		}
+
+		genres := make([]string, 0, 4)
+		for _, j := range rng.Perm(len(seedGenres))[:rng.Intn(3)+2] {
+			genres = append(genres, seedGenres[j])
+		}
+
+		return []interface{}{title, 1920 + rng.Intn(lastYear-1920+1), 70 + rng.Intn(130), pq.Array(genres)}
+	})
+}
+
+func seedUsers(ctx context.Context, tx *data.Tx, keys *data.Keyring, rng *rand.Rand, n int, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	var lastID int64
+
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM users`).Scan(&lastID)
+	if err != nil {
+		return err
+	}
+
+	suffix := rng.Int63()
+
+	err = copyRows(ctx, tx, "users", []string{"name", "email", "email_hash", "password_hash", "activated"}, n, func(i int) []interface{} {
+		first := seedFirstNames[rng.Intn(len(seedFirstNames))]
+		last := seedLastNames[rng.Intn(len(seedLastNames))]
+		email := fmt.Sprintf("%s.%s.%x.%d@example.com", strings.ToLower(first), strings.ToLower(last), suffix, i)
+
+		return []interface{}{first + " " + last, keys.Encrypted(email), keys.EmailIndex(email), hash, rng.Intn(10) != 0}
+	})
+	if err != nil {
+		return err
+	}
+
+	// Email addresses are encrypted, so the new users are found by ID.
+	query := `
+	INSERT INTO users_permissions (user_id, permission_id)
+	SELECT users.id, permissions.id
+	FROM users CROSS JOIN permissions
+	WHERE users.id > $1 AND permissions.code = ANY($2)
+	ON CONFLICT DO NOTHING`
+
+	_, err = tx.ExecContext(ctx, query, lastID,
+		pq.Array([]string{data.PermissionMoviesRead, data.PermissionMoviesWrite}))
+	return err
+}
@@ -2,19 +2,29 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 func (app *application) serve() error {
+	ln, err := app.listen()
+	if err != nil {
+		return err
+	}
+
 	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%d", app.config.port),
+		Addr:         ln.Addr().String(),
 		Handler:      app.routes(),
 		ErrorLog:     log.New(app.logger, "", 0),
 		IdleTimeout:  time.Minute,
@@ -22,6 +32,176 @@ func (app *application) serve() error {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	var auxServers []*http.Server
+
+	if app.config.debug.addr != "" {
+		auxServers = append(auxServers, &http.Server{
+			Addr:         app.config.debug.addr,
+			Handler:      app.debugRoutes(),
+			ErrorLog:     log.New(app.logger, "", 0),
+			IdleTimeout:  time.Minute,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 2 * time.Minute,
+		})
+	}
+
+	if app.tlsEnabled() {
+		srv.TLSConfig = app.tlsConfig()
+
+		redirect := http.HandlerFunc(app.redirectToHTTPS)
+
+		if len(app.config.tls.autocertDomains) > 0 {
+			manager := &autocert.Manager{
+				Prompt:     autocert.AcceptTOS,
+				Cache:      autocert.DirCache(app.config.tls.autocertCache),
+				HostPolicy: autocert.HostWhitelist(app.config.tls.autocertDomains...),
+				Email:      app.config.tls.autocertEmail,
+			}
+
+			srv.TLSConfig.GetCertificate = manager.GetCertificate
+			srv.TLSConfig.NextProtos = []string{"h2", "http/1.1", acme.ALPNProto}
+
+			redirect = manager.HTTPHandler(redirect).ServeHTTP
+		}
+
+		if app.config.tls.redirectAddr != "" {
+			auxServers = append(auxServers, &http.Server{
+				Addr:         app.config.tls.redirectAddr,
+				Handler:      redirect,
+				ErrorLog:     log.New(app.logger, "", 0),
+				IdleTimeout:  time.Minute,
+				ReadTimeout:  5 * time.Second,
+				WriteTimeout: 5 * time.Second,
+			})
+		}
+	}
+
+	// Auxiliary listeners are opened here rather than by ListenAndServe so
+	// that they can be handed over on upgrade along with the main one.
+	inherited, err := inheritedAuxListeners()
+	if err != nil {
+		return err
+	}
+
+	auxListeners := make(map[string]net.Listener)
+
+	for _, aux := range auxServers {
+		auxLn, ok := inherited[aux.Addr]
+		if ok {
+			delete(inherited, aux.Addr)
+		} else {
+			auxLn, err = net.Listen("tcp", aux.Addr)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{
+					"addr": aux.Addr,
+				})
+				continue
+			}
+		}
+		auxListeners[aux.Addr] = auxLn
+
+		go func(aux *http.Server, auxLn net.Listener) {
+			app.logger.PrintInfo("starting auxiliary server", map[string]string{
+				"addr": aux.Addr,
+			})
+
+			err := aux.Serve(auxLn)
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.PrintError(err, map[string]string{
+					"addr": aux.Addr,
+				})
+			}
+		}(aux, auxLn)
+	}
+
+	// Sockets handed over for servers no longer configured are not needed.
+	for _, unused := range inherited {
+		unused.Close()
+	}
+
+	go func() {
+		upgrade := make(chan os.Signal, 1)
+		signal.Notify(upgrade, syscall.SIGUSR2)
+
+		for range upgrade {
+			err := app.handoff(ln, auxListeners)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+			}
+		}
+	}()
+
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(context.Background())
+	defer stopHeartbeat()
+
+	go app.runOperationHeartbeat(heartbeatCtx, operationHeartbeat)
+
+	if app.config.erasure.interval > 0 {
+		app.background(func() {
+			app.runErasures(jobCtx, app.config.erasure.interval)
+		})
+	}
+
+	if app.config.savedSearches.interval > 0 {
+		app.background(func() {
+			app.runSavedSearches(jobCtx, app.config.savedSearches.interval)
+		})
+	}
+
+	if app.config.db.listen {
+		dsn := app.config.db.dsn
+		if app.config.secrets.dsn != nil {
+			dsn = app.config.secrets.dsn.Get()
+		}
+
+		app.background(func() {
+			app.listenForChanges(jobCtx, dsn)
+		})
+	}
+
+	if app.config.retention.interval > 0 && len(app.config.retention.policies) > 0 {
+		app.background(func() {
+			app.runRetention(jobCtx, app.config.retention.interval)
+		})
+	}
+
+	if app.config.network.ipRulesRefresh > 0 {
+		app.background(func() {
+			app.refreshIPRules(jobCtx, app.config.network.ipRulesRefresh)
+		})
+	}
+
+	if app.usage != nil {
+		app.background(func() {
+			app.flushUsage(jobCtx, app.config.usage.flushInterval)
+		})
+	}
+
+	if app.config.public.feedRefresh > 0 {
+		app.background(func() {
+			app.runFeeds(jobCtx, app.config.public.feedRefresh)
+		})
+	}
+
+	if app.analytics != nil {
+		app.background(func() {
+			app.runAnalytics(jobCtx, app.config.analytics.flushInterval)
+		})
+	}
+
+	app.background(func() {
+		app.publishMetrics(jobCtx, wsMetricsInterval)
+	})
+
+	if app.config.secrets.refresh > 0 && app.config.secrets.resolver != nil {
+		app.background(func() {
+			app.refreshSecrets(jobCtx, app.config.secrets.refresh)
+		})
+	}
+
 	shutdownError := make(chan error)
 
 	go func() {
@@ -36,6 +216,10 @@ func (app *application) serve() error {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
+		for _, aux := range auxServers {
+			aux.Shutdown(ctx)
+		}
+
 		err := srv.Shutdown(ctx)
 		if err != nil {
 			shutdownError <- err
@@ -45,16 +229,25 @@ func (app *application) serve() error {
 			"addr": srv.Addr,
 		})
 
+		stopJobs()
 		app.wg.Wait()
+		stopHeartbeat()
 		shutdownError <- nil
 	}()
 
 	app.logger.PrintInfo("starting server", map[string]string{
 		"addr": srv.Addr,
 		"env":  app.config.env,
+		"tls":  fmt.Sprint(app.tlsEnabled()),
 	})
 
-	err := srv.ListenAndServe()
+	app.notifyParent()
+
+	if app.tlsEnabled() {
+		err = srv.ServeTLS(ln, app.config.tls.certFile, app.config.tls.keyFile)
+	} else {
+		err = srv.Serve(ln)
+	}
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
@@ -70,3 +263,35 @@ func (app *application) serve() error {
 
 	return nil
 }
+
+func (app *application) tlsEnabled() bool {
+	return app.config.tls.certFile != "" || len(app.config.tls.autocertDomains) > 0
+}
+
+func (app *application) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		},
+	}
+}
+
+func (app *application) redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if app.config.port != 443 {
+		host = net.JoinHostPort(host, fmt.Sprint(app.config.port))
+	}
+
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
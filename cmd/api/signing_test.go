@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/testutil"
+)
+
+func TestNonceCache(t *testing.T) {
+	c := newNonceCache()
+
+	if !c.add("acme:1", time.Minute) {
+		t.Fatal("first use of a nonce was rejected")
+	}
+	if c.add("acme:1", time.Minute) {
+		t.Fatal("replayed nonce was accepted")
+	}
+	if !c.add("acme:2", time.Minute) {
+		t.Fatal("a different nonce was rejected")
+	}
+
+	c.entries["acme:3"] = time.Now().Add(-time.Second)
+	if !c.add("acme:3", time.Minute) {
+		t.Fatal("an expired nonce was rejected")
+	}
+}
+
+func TestSignedRequests(t *testing.T) {
+	app, ts := newTestServer(t)
+	user, _ := testutil.CreateUser(t, app.models, true, data.PermissionMoviesRead, data.PermissionMoviesWrite)
+
+	partner := &data.Partner{Name: "Acme", UserID: user.ID}
+	err := app.models.Partners.Insert(partner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored string
+	err = app.db.QueryRowContext(context.Background(), `SELECT secret FROM partners WHERE id = $1`, partner.ID).Scan(&stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stored, "enc:") || strings.Contains(stored, partner.Secret) {
+		t.Fatalf("stored secret %q is not encrypted", stored)
+	}
+
+	send := func(nonce string, timestamp time.Time, secret string) int {
+		t.Helper()
+
+		body := []byte(`{"title":"Moana","year":2016,"runtime":107,"genres":["animation","adventure"]}`)
+		stamp := strconv.FormatInt(timestamp.Unix(), 10)
+		signature := signRequest(secret, http.MethodPost, "/v1/movies", stamp, nonce, body)
+
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/v1/movies", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("%s keyId=%s,timestamp=%s,nonce=%s,signature=%s", signatureScheme, partner.KeyID, stamp, nonce, signature))
+
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+
+		return rs.StatusCode
+	}
+
+	if got := send("n1", time.Now(), partner.Secret); got != http.StatusOK {
+		t.Fatalf("signed request: got status %d; want %d", got, http.StatusOK)
+	}
+	if got := send("n1", time.Now(), partner.Secret); got != http.StatusUnauthorized {
+		t.Errorf("replayed request: got status %d; want %d", got, http.StatusUnauthorized)
+	}
+	if got := send("n2", time.Now(), "wrong"); got != http.StatusUnauthorized {
+		t.Errorf("bad signature: got status %d; want %d", got, http.StatusUnauthorized)
+	}
+	if got := send("n3", time.Now().Add(-time.Hour), partner.Secret); got != http.StatusUnauthorized {
+		t.Errorf("stale timestamp: got status %d; want %d", got, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+func (app *application) showSyncCursorHandler(w http.ResponseWriter, r *http.Request) {
+	cursor, err := app.models.Sync.GetCursor(app.contextGetClient(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"sync": cursor}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// syncMoviesHandler applies a batch of movie changes from an upstream
+// catalogue. The batch's cursor is the upstream's position after it; batches
+// must be sent in order, and resending one that was already applied is a
+// no-op reported with applied set to false.
+func (app *application) syncMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Cursor  int64 `json:"cursor"`
+		Changes []struct {
+			Op         string `json:"op"`
+			ExternalID string `json:"external_id"`
+			Movie      *struct {
+				Title            string       `json:"title"`
+				Year             int32        `json:"year"`
+				ReleaseDate      *data.Date   `json:"release_date"`
+				Runtime          data.Runtime `json:"runtime"`
+				Genres           []string     `json:"genres"`
+				Synopsis         string       `json:"synopsis"`
+				OriginalLanguage string       `json:"original_language"`
+				Country          string       `json:"country"`
+				Budget           int64        `json:"budget"`
+				BoxOffice        int64        `json:"box_office"`
+				Certification    string       `json:"certification"`
+			} `json:"movie"`
+		} `json:"changes"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	changes := make([]data.SyncChange, len(input.Changes))
+	for i, c := range input.Changes {
+		changes[i] = data.SyncChange{Op: c.Op, ExternalID: c.ExternalID}
+
+		if m := c.Movie; m != nil {
+			movie := &data.Movie{
+				Title:            m.Title,
+				Year:             m.Year,
+				ReleaseDate:      m.ReleaseDate,
+				Runtime:          m.Runtime,
+				Genres:           m.Genres,
+				Synopsis:         m.Synopsis,
+				OriginalLanguage: m.OriginalLanguage,
+				Country:          m.Country,
+				Budget:           m.Budget,
+				BoxOffice:        m.BoxOffice,
+				Certification:    m.Certification,
+			}
+			if movie.Year == 0 && movie.ReleaseDate != nil {
+				movie.Year = int32(movie.ReleaseDate.Year())
+			}
+			changes[i].Movie = movie
+		}
+	}
+
+	v := validator.New()
+
+	data.ValidateSyncBatch(v, input.Cursor, changes)
+	for i, c := range changes {
+		cv := validator.New()
+		data.ValidateSyncChange(cv, c, app.models.Movies.Rules)
+		for key, message := range cv.Errors {
+			v.AddError(fmt.Sprintf("changes[%d].%s", i, key), message)
+		}
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	result, err := app.models.Sync.Apply(app.contextGetClient(r), input.Cursor, changes)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"sync": result}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/levisthors/greenlight/client"
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/testutil"
+)
+
+func TestSyncMovies(t *testing.T) {
+	app, ts := newTestServer(t)
+	user, token := testutil.CreateUser(t, app.models, true, data.PermissionMoviesRead, data.PermissionMoviesWrite)
+
+	partner := &data.Partner{Name: "Acme", UserID: user.ID}
+	err := app.models.Partners.Insert(partner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	c := client.New(ts.URL, client.WithHTTPClient(ts.Client()), client.WithPartnerKey(client.PartnerKey{ID: partner.KeyID, Secret: partner.Secret}))
+
+	batch := client.SyncMoviesRequest{
+		Cursor: 1,
+		Changes: []client.SyncMoviesRequestChanges{
+			{Op: "upsert", ExternalID: "acme-1", Movie: &client.SyncMovie{Title: "Moana", Year: client.Int64(2016), Runtime: 107, Genres: []string{"animation"}}},
+			{Op: "upsert", ExternalID: "acme-2", Movie: &client.SyncMovie{Title: "Up", Year: client.Int64(2009), Runtime: 96, Genres: []string{"animation"}}},
+		},
+	}
+
+	resp, err := c.SyncMovies(ctx, batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Sync; !got.Applied || got.Created != 2 || got.Cursor != 1 {
+		t.Fatalf("first batch: got %+v; want 2 created at cursor 1", got)
+	}
+
+	// Resending a batch, as a partner does after a lost response, is a no-op.
+	resp, err = c.SyncMovies(ctx, batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Sync; got.Applied || got.Created != 0 || got.Cursor != 1 {
+		t.Fatalf("replayed batch: got %+v; want nothing applied at cursor 1", got)
+	}
+
+	batch.Cursor = 2
+	batch.Changes[0].Movie.Title = "Moana (2016)"
+	batch.Changes = append(batch.Changes, client.SyncMoviesRequestChanges{Op: "delete", ExternalID: "acme-3"})
+
+	resp, err = c.SyncMovies(ctx, batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Sync; !got.Applied || got.Updated != 1 || got.Unchanged != 2 || got.Cursor != 2 {
+		t.Fatalf("second batch: got %+v; want 1 updated and 2 unchanged at cursor 2", got)
+	}
+
+	cursor, err := c.ShowSyncCursor(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor.Sync.Cursor != 2 || cursor.Sync.Source != "partner:"+partner.KeyID {
+		t.Errorf("got cursor %+v; want 2 for partner:%s", cursor.Sync, partner.KeyID)
+	}
+
+	// Another partner using the same external IDs gets its own movies and
+	// cannot delete the first partner's.
+	other := &data.Partner{Name: "Globex", UserID: user.ID}
+	err = app.models.Partners.Insert(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherClient := client.New(ts.URL, client.WithHTTPClient(ts.Client()), client.WithPartnerKey(client.PartnerKey{ID: other.KeyID, Secret: other.Secret}))
+
+	resp, err = otherClient.SyncMovies(ctx, client.SyncMoviesRequest{
+		Cursor: 1,
+		Changes: []client.SyncMoviesRequestChanges{
+			{Op: "upsert", ExternalID: "acme-1", Movie: &client.SyncMovie{Title: "Hijacked", Year: client.Int64(2016), Runtime: 107, Genres: []string{"animation"}}},
+			{Op: "delete", ExternalID: "acme-2"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Sync; got.Created != 1 || got.Updated != 0 || got.Deleted != 0 {
+		t.Fatalf("other partner: got %+v; want its own movie created and nothing else touched", got)
+	}
+
+	// A user's own token is not enough to sync.
+	_, err = client.New(ts.URL, client.WithHTTPClient(ts.Client()), client.WithToken(token)).SyncMovies(ctx, batch)
+
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("sync with a bearer token: got %v; want status %d", err, http.StatusUnauthorized)
+	}
+}
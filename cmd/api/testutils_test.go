@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/jsonlog"
+	"github.com/levisthors/greenlight/internal/mailer"
+	"github.com/levisthors/greenlight/internal/testutil"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.Main(m))
+}
+
+func newTestApplication(t *testing.T) *application {
+	db := testutil.NewDB(t)
+	logger := jsonlog.New(io.Discard, jsonlog.LevelOff)
+	instrumentedDB := data.NewDB(db, logger, 0)
+
+	var cfg config
+	cfg.env = "testing"
+	cfg.limits.maxBody = 1 << 20
+	cfg.limits.heavyMaxBody = 10 << 20
+	cfg.movies = data.DefaultMovieRules()
+
+	// Partner secrets are only stored encrypted, so tests run with a keyring.
+	keyring, err := data.NewKeyring("test", map[string][]byte{"test": bytes.Repeat([]byte("k"), 32)}, bytes.Repeat([]byte("h"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{
+		config:  cfg,
+		logger:  logger,
+		db:      instrumentedDB,
+		models:  data.NewModels(instrumentedDB, keyring, cfg.movies),
+		mailer:  mailer.New("localhost", 0, "", "", "Greenlight <test@example.com>"),
+		ipLists: &ipLists{},
+		nonces:  newNonceCache(),
+		hub:     newEventHub(),
+	}
+
+	app.freezes = newFreezeCache(0, app.models.WriteFreezes.GetAll)
+
+	// Let background tasks finish before the test database is dropped.
+	t.Cleanup(app.wg.Wait)
+
+	return app
+}
+
+func newTestServer(t *testing.T) (*application, *testutil.TestServer) {
+	app := newTestApplication(t)
+	return app, testutil.NewServer(t, app.routes())
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	inheritedFDEnv    = "GREENLIGHT_LISTEN_FD"
+	inheritedAuxFDEnv = "GREENLIGHT_AUX_FDS"
+	parentPIDEnv      = "GREENLIGHT_PARENT_PID"
+)
+
+// listenerFile returns a duplicate of the listener's socket.
+func listenerFile(ln net.Listener) (*os.File, error) {
+	filer, ok := ln.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, fmt.Errorf("listener %T cannot be handed off", ln)
+	}
+
+	if unixLn, ok := ln.(*net.UnixListener); ok {
+		unixLn.SetUnlinkOnClose(false)
+	}
+
+	return filer.File()
+}
+
+// handoff starts a new copy of the binary (typically a freshly deployed one)
+// and passes it duplicates of the listening sockets: the API server's, and
+// those of the auxiliary servers keyed by their address. Once the child is
+// ready to accept connections it sends SIGTERM to this process, which then
+// drains its in-flight requests through the normal graceful shutdown path.
+func (app *application) handoff(ln net.Listener, auxListeners map[string]net.Listener) error {
+	f, err := listenerFile(ln)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	files := []*os.File{f}
+	var auxFDs []string
+
+	for addr, auxLn := range auxListeners {
+		f, err := listenerFile(auxLn)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		auxFDs = append(auxFDs, fmt.Sprintf("%s=%d", addr, systemdFirstFD+len(files)))
+		files = append(files, f)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	env := []string{}
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, inheritedFDEnv+"=") && !strings.HasPrefix(kv, inheritedAuxFDEnv+"=") && !strings.HasPrefix(kv, parentPIDEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env,
+		fmt.Sprintf("%s=%d", inheritedFDEnv, systemdFirstFD),
+		fmt.Sprintf("%s=%s", inheritedAuxFDEnv, strings.Join(auxFDs, ",")),
+		fmt.Sprintf("%s=%d", parentPIDEnv, os.Getpid()),
+	)
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	app.logger.PrintInfo("started replacement process", map[string]string{
+		"pid": strconv.Itoa(cmd.Process.Pid),
+	})
+
+	go cmd.Wait()
+
+	return nil
+}
+
+func inheritedListener() (net.Listener, bool, error) {
+	value := os.Getenv(inheritedFDEnv)
+	if value == "" {
+		return nil, false, nil
+	}
+	os.Unsetenv(inheritedFDEnv)
+
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, true, errors.New("invalid inherited listener file descriptor")
+	}
+	syscall.CloseOnExec(fd)
+
+	f := os.NewFile(uintptr(fd), "inherited")
+	defer f.Close()
+
+	ln, err := net.FileListener(f)
+	return ln, true, err
+}
+
+// inheritedAuxListeners returns the auxiliary server listeners handed over
+// by a previous process, keyed by the address they were configured with.
+func inheritedAuxListeners() (map[string]net.Listener, error) {
+	value := os.Getenv(inheritedAuxFDEnv)
+	os.Unsetenv(inheritedAuxFDEnv)
+
+	listeners := make(map[string]net.Listener)
+	if value == "" {
+		return listeners, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			return nil, errors.New("invalid inherited auxiliary listener")
+		}
+
+		fd, err := strconv.Atoi(entry[i+1:])
+		if err != nil {
+			return nil, errors.New("invalid inherited auxiliary listener file descriptor")
+		}
+		syscall.CloseOnExec(fd)
+
+		f := os.NewFile(uintptr(fd), "inherited")
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		listeners[entry[:i]] = ln
+	}
+
+	return listeners, nil
+}
+
+func (app *application) notifyParent() {
+	value := os.Getenv(parentPIDEnv)
+	if value == "" {
+		return
+	}
+	os.Unsetenv(parentPIDEnv)
+
+	pid, err := strconv.Atoi(value)
+	if err != nil || pid != os.Getppid() {
+		return
+	}
+
+	parent, err := os.FindProcess(pid)
+	if err != nil {
+		app.logger.PrintError(err, nil)
+		return
+	}
+
+	err = parent.Signal(syscall.SIGTERM)
+	if err != nil {
+		app.logger.PrintError(err, nil)
+	}
+}
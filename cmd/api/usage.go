@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+// usageStore is the part of data.UsageModel the usage tracker needs.
+type usageStore interface {
+	Add(counts []data.UsageCount) error
+	TotalForUser(userID int64, from, to time.Time) (int64, error)
+}
+
+type usageKey struct {
+	userID int64
+	client string
+	day    string
+}
+
+type userMonth struct {
+	userID int64
+	month  string
+}
+
+// quotaState is a user's request count for a month: stored is the total in
+// the database when it was last read and local the requests counted here
+// since then that have not been written yet.
+type quotaState struct {
+	month  string
+	stored int64
+	local  int64
+	seen   bool
+}
+
+// usageTracker counts requests in memory and writes them to the usage table
+// in batches, so that counting adds no database write to each request. It
+// also keeps each active user's monthly total for quota checks. Totals are
+// refreshed from the database on every flush, so with several instances a
+// quota can be overshot by up to one flush interval of traffic.
+type usageTracker struct {
+	store usageStore
+
+	mu            sync.Mutex
+	pending       map[usageKey]int64
+	pendingByUser map[userMonth]int64
+	totals        map[int64]*quotaState
+}
+
+func newUsageTracker(store usageStore) *usageTracker {
+	return &usageTracker{
+		store:         store,
+		pending:       make(map[usageKey]int64),
+		pendingByUser: make(map[userMonth]int64),
+		totals:        make(map[int64]*quotaState),
+	}
+}
+
+// monthBounds returns the start of t's month and of the month after, in UTC.
+func monthBounds(t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
+
+func (t *usageTracker) count(userID int64, client string, now time.Time) {
+	now = now.UTC()
+	month := now.Format("2006-01")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pending[usageKey{userID, client, now.Format("2006-01-02")}]++
+	t.pendingByUser[userMonth{userID, month}]++
+
+	if state, ok := t.totals[userID]; ok && state.month == month {
+		state.local++
+	}
+}
+
+// forget drops the user's monthly total, e.g. once their account is erased.
+func (t *usageTracker) forget(userID int64) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	delete(t.totals, userID)
+	t.mu.Unlock()
+}
+
+// used returns how many requests the user has made this month, reading the
+// stored total the first time the user is seen.
+func (t *usageTracker) used(userID int64, now time.Time) (int64, error) {
+	month := now.UTC().Format("2006-01")
+
+	t.mu.Lock()
+	state, ok := t.totals[userID]
+	if ok && state.month == month {
+		state.seen = true
+		used := state.stored + state.local
+		t.mu.Unlock()
+		return used, nil
+	}
+	t.mu.Unlock()
+
+	from, to := monthBounds(now)
+
+	stored, err := t.store.TotalForUser(userID, from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state = &quotaState{month: month, stored: stored, local: t.pendingByUser[userMonth{userID, month}], seen: true}
+	t.totals[userID] = state
+
+	return state.stored + state.local, nil
+}
+
+// flush writes the pending counts and re-reads the totals of users active
+// since the last flush. Users who have gone quiet are dropped from memory: the
+// refresh does not count as activity, so a user with no requests between two
+// flushes is gone after the second.
+func (t *usageTracker) flush(now time.Time) error {
+	t.mu.Lock()
+	batch := make([]data.UsageCount, 0, len(t.pending))
+	for key, n := range t.pending {
+		day, _ := data.ParseDate(key.day)
+		batch = append(batch, data.UsageCount{UserID: key.userID, Client: key.client, Day: day, Requests: n})
+	}
+	pending := t.pending
+	t.pending = make(map[usageKey]int64)
+	t.pendingByUser = make(map[userMonth]int64)
+
+	var active []int64
+	for userID, state := range t.totals {
+		if !state.seen {
+			delete(t.totals, userID)
+			continue
+		}
+		state.seen = false
+		active = append(active, userID)
+	}
+	t.mu.Unlock()
+
+	err := t.store.Add(batch)
+	if err != nil {
+		// Put the counts back to be retried on the next flush.
+		t.mu.Lock()
+		for key, n := range pending {
+			t.pending[key] += n
+			t.pendingByUser[userMonth{key.userID, key.day[:7]}] += n
+		}
+		t.mu.Unlock()
+		return err
+	}
+
+	from, to := monthBounds(now)
+	month := now.UTC().Format("2006-01")
+
+	for _, userID := range active {
+		stored, err := t.store.TotalForUser(userID, from, to)
+		if err != nil {
+			return err
+		}
+
+		t.mu.Lock()
+		if state, ok := t.totals[userID]; ok {
+			state.month = month
+			state.stored = stored
+			state.local = t.pendingByUser[userMonth{userID, month}]
+		}
+		t.mu.Unlock()
+	}
+
+	return nil
+}
+
+func (app *application) flushUsage(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// The server has stopped taking requests; write what is left.
+			err := app.usage.flush(time.Now())
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"job": "usage"})
+			}
+			return
+		case <-ticker.C:
+			err := app.usage.flush(time.Now())
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"job": "usage"})
+			}
+		}
+	}
+}
+
+// tokenClient names a bearer token in usage reports without revealing it.
+func tokenClient(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(hash[:4])
+}
+
+// trackUsage counts requests made with a token or partner key and enforces
+// the monthly quota. GET /v1/me/usage is always allowed so that clients over
+// their quota can still see when it resets.
+func (app *application) trackUsage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		client := app.contextGetClient(r)
+
+		if app.usage == nil || user.IsAnonymous() || client == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+
+		if quota := app.config.usage.monthlyQuota; quota > 0 {
+			used, err := app.usage.used(user.ID, now)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			_, reset := monthBounds(now)
+			exceeded := used >= quota && !(r.Method == http.MethodGet && r.URL.Path == "/v1/me/usage")
+
+			remaining := quota - used
+			if !exceeded {
+				remaining--
+			}
+
+			w.Header().Set("X-Quota-Limit", strconv.FormatInt(quota, 10))
+			w.Header().Set("X-Quota-Remaining", strconv.FormatInt(max(remaining, 0), 10))
+			w.Header().Set("X-Quota-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+			if exceeded {
+				w.Header().Set("Retry-After", strconv.Itoa(int(reset.Sub(now).Seconds())+1))
+				app.quotaExceededResponse(w, r)
+				return
+			}
+		}
+
+		app.usage.count(user.ID, client, now)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) showUsageHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	v := validator.New()
+
+	now := time.Now().UTC()
+
+	month := now
+	if s := r.URL.Query().Get("month"); s != "" {
+		t, err := time.Parse("2006-01", s)
+		if err != nil {
+			v.AddError("month", "must be in the format YYYY-MM")
+		}
+		month = t
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	from, to := monthBounds(month)
+
+	daily, err := app.models.Usage.GetForUser(user.ID, from, to)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var total int64
+	for _, c := range daily {
+		total += c.Requests
+	}
+
+	usage := map[string]interface{}{
+		"month":    from.Format("2006-01"),
+		"requests": total,
+		"daily":    daily,
+	}
+
+	if quota := app.config.usage.monthlyQuota; quota > 0 {
+		usage["quota"] = quota
+		usage["resets_at"] = to
+	}
+
+	err = app.writeJSON(w, r, http.StatusOK, envelope{"usage": usage}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
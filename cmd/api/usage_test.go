@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+	"github.com/levisthors/greenlight/internal/jsonlog"
+)
+
+type fakeUsageStore struct {
+	counts []data.UsageCount
+	fail   bool
+}
+
+func (s *fakeUsageStore) Add(counts []data.UsageCount) error {
+	if s.fail {
+		return errors.New("store unavailable")
+	}
+	s.counts = append(s.counts, counts...)
+	return nil
+}
+
+func (s *fakeUsageStore) TotalForUser(userID int64, from, to time.Time) (int64, error) {
+	var total int64
+	for _, c := range s.counts {
+		if c.UserID == userID && !c.Day.Before(from) && c.Day.Before(to) {
+			total += c.Requests
+		}
+	}
+	return total, nil
+}
+
+func TestUsageTrackerFlush(t *testing.T) {
+	store := &fakeUsageStore{}
+	tracker := newUsageTracker(store)
+
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	used, err := tracker.used(1, now)
+	if err != nil || used != 0 {
+		t.Fatalf("got %d, %v; want 0", used, err)
+	}
+
+	tracker.count(1, "token:aa", now)
+	tracker.count(1, "token:aa", now)
+	tracker.count(1, "token:bb", now)
+
+	if used, _ := tracker.used(1, now); used != 3 {
+		t.Errorf("before flush: got %d; want 3", used)
+	}
+
+	store.fail = true
+	if err := tracker.flush(now); err == nil {
+		t.Fatal("expected the flush to fail")
+	}
+
+	store.fail = false
+	if err := tracker.flush(now); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.counts) != 2 {
+		t.Errorf("got %d stored rows; want 2", len(store.counts))
+	}
+	if used, _ := tracker.used(1, now); used != 3 {
+		t.Errorf("after flush: got %d; want 3", used)
+	}
+
+	nextMonth := now.AddDate(0, 1, 0)
+	if used, _ := tracker.used(1, nextMonth); used != 0 {
+		t.Errorf("next month: got %d; want 0", used)
+	}
+}
+
+func TestUsageTrackerDropsQuietUsers(t *testing.T) {
+	tracker := newUsageTracker(&fakeUsageStore{})
+
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	tracker.used(1, now)
+	tracker.used(2, now)
+	tracker.count(1, "token:aa", now)
+
+	// Both users were active before the first flush, so both are kept.
+	if err := tracker.flush(now); err != nil {
+		t.Fatal(err)
+	}
+	if len(tracker.totals) != 2 {
+		t.Fatalf("after the first flush: got %d totals; want 2", len(tracker.totals))
+	}
+
+	// Only user 2 makes a request before the second flush.
+	tracker.used(2, now)
+
+	if err := tracker.flush(now); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tracker.totals[1]; ok {
+		t.Error("user 1 went quiet but is still tracked")
+	}
+	if _, ok := tracker.totals[2]; !ok {
+		t.Error("user 2 is active but was dropped")
+	}
+
+	if err := tracker.flush(now); err != nil {
+		t.Fatal(err)
+	}
+	if len(tracker.totals) != 0 {
+		t.Errorf("after everyone went quiet: got %d totals; want 0", len(tracker.totals))
+	}
+}
+
+func TestTrackUsageQuota(t *testing.T) {
+	app := &application{
+		logger: jsonlog.New(io.Discard, jsonlog.LevelOff),
+		usage:  newUsageTracker(&fakeUsageStore{}),
+	}
+	app.config.usage.monthlyQuota = 2
+
+	handler := app.trackUsage(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	do := func(path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r = app.contextSetUser(r, &data.User{ID: 7})
+		r = app.contextSetClient(r, "token:aa")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for i, wantRemaining := range []string{"1", "0"} {
+		w := do("/v1/movies")
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("request %d: got status %d", i, w.Code)
+		}
+		if got := w.Header().Get("X-Quota-Remaining"); got != wantRemaining {
+			t.Errorf("request %d: got X-Quota-Remaining %q; want %q", i, got, wantRemaining)
+		}
+	}
+
+	w := do("/v1/movies")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("got status %d; want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" || w.Header().Get("X-Quota-Reset") == "" {
+		t.Errorf("missing reset headers: %v", w.Header())
+	}
+
+	if w := do("/v1/me/usage"); w.Code != http.StatusNoContent {
+		t.Errorf("usage endpoint: got status %d; want it to stay reachable", w.Code)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/levisthors/greenlight/internal/jsonlog"
+	"github.com/levisthors/greenlight/internal/openapi"
+)
+
+func TestValidateRequests(t *testing.T) {
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{logger: jsonlog.New(io.Discard, jsonlog.LevelOff), spec: spec}
+
+	var reached bool
+	var body string
+	handler := app.validateRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		body      string
+		wantPass  bool
+		wantField string
+	}{
+		{"valid query", "GET", "/v1/movies?page=2&sort=-year&links=true", "", true, ""},
+		{"out of range page", "GET", "/v1/movies?page=0", "", false, "page"},
+		{"non-integer page", "GET", "/v1/movies?page_size=lots", "", false, "page_size"},
+		{"bad enum", "GET", "/v1/movies?sort=rating", "", false, "sort"},
+		{"pattern", "GET", "/v1/me/usage?month=Oct", "", false, "month"},
+		{"valid body", "POST", "/v1/movies", `{"title":"Moana","year":2016,"runtime":107,"genres":["animation"]}`, true, ""},
+		{"wrong type", "POST", "/v1/movies", `{"title":7,"year":2016,"runtime":107,"genres":["animation"]}`, false, "title"},
+		{"malformed body is left to the handler", "POST", "/v1/movies", `{"title":`, true, ""},
+		{"undocumented route", "GET", "/v1/nowhere?page=0", "", true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached, body = false, ""
+
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if reached != tt.wantPass {
+				t.Fatalf("handler reached = %v; want %v (status %d, body %s)", reached, tt.wantPass, w.Code, w.Body)
+			}
+
+			if tt.wantPass {
+				if body != tt.body {
+					t.Errorf("handler got body %q; want %q", body, tt.body)
+				}
+				return
+			}
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("got status %d; want 422", w.Code)
+			}
+
+			var resp struct {
+				Error map[string]string `json:"error"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := resp.Error[tt.wantField]; !ok {
+				t.Errorf("got errors %v; want one for %q", resp.Error, tt.wantField)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Topics that admin console connections can subscribe to.
+const (
+	topicMetrics = "metrics"
+	topicErrors  = "errors"
+	topicCatalog = "catalog"
+)
+
+const (
+	// wsSendBuffer is how many events may be queued for a connection. Events
+	// published while the queue is full are dropped for that connection, and
+	// it is told how many it missed once it catches up.
+	wsSendBuffer = 64
+	// wsMaxDropped is how many events a connection may miss in a row before
+	// it is disconnected as too slow.
+	wsMaxDropped = 1000
+
+	wsWriteWait       = 10 * time.Second
+	wsPongWait        = 60 * time.Second
+	wsPingInterval    = 50 * time.Second
+	wsMetricsInterval = 5 * time.Second
+	wsRecentErrors    = 50
+)
+
+type event struct {
+	Topic string      `json:"topic"`
+	Time  time.Time   `json:"time"`
+	Data  interface{} `json:"data"`
+}
+
+// catalogEvent is published on the catalog topic when movies change. Events
+// come from the change notifications, so they cover changes made through any
+// instance or the CLI.
+type catalogEvent struct {
+	Action  string `json:"action"`
+	MovieID int64  `json:"movie_id,omitempty"`
+	Count   int64  `json:"count,omitempty"`
+}
+
+type wsClient struct {
+	send    chan []byte
+	mu      sync.Mutex
+	topics  map[string]bool
+	dropped int
+	slow    bool
+	done    chan struct{}
+	once    sync.Once
+}
+
+func (c *wsClient) subscribed(topic string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.topics[topic]
+}
+
+func (c *wsClient) close() {
+	c.once.Do(func() { close(c.done) })
+}
+
+// eventHub fans events out to the connected admin consoles. A nil hub drops
+// every event, so handlers can publish without checking.
+type eventHub struct {
+	mu           sync.Mutex
+	clients      map[*wsClient]struct{}
+	recentErrors []event
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{clients: make(map[*wsClient]struct{})}
+}
+
+func (h *eventHub) register(c *wsClient) {
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+}
+
+func (h *eventHub) unregister(c *wsClient) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	h.mu.Unlock()
+}
+
+func (h *eventHub) connections() int {
+	if h == nil {
+		return 0
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// publish queues an event for every connection subscribed to its topic
+// without blocking.
+func (h *eventHub) publish(topic string, data interface{}) {
+	if h == nil {
+		return
+	}
+
+	e := event{Topic: topic, Time: time.Now(), Data: data}
+
+	msg, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if topic == topicErrors {
+		h.recentErrors = append(h.recentErrors, e)
+		if len(h.recentErrors) > wsRecentErrors {
+			h.recentErrors = h.recentErrors[len(h.recentErrors)-wsRecentErrors:]
+		}
+	}
+
+	for c := range h.clients {
+		if !c.subscribed(topic) {
+			continue
+		}
+
+		select {
+		case c.send <- msg:
+		default:
+			c.mu.Lock()
+			c.dropped++
+			tooSlow := c.dropped > wsMaxDropped
+			c.slow = c.slow || tooSlow
+			c.mu.Unlock()
+
+			if tooSlow {
+				delete(h.clients, c)
+				c.close()
+			}
+		}
+	}
+}
+
+func (h *eventHub) recent() []event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]event(nil), h.recentErrors...)
+}
+
+// publishMetrics sends a metrics snapshot every interval until ctx is done,
+// skipping the work while nobody is connected.
+func (app *application) publishMetrics(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if app.hub.connections() == 0 {
+			continue
+		}
+
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+
+		app.hub.publish(topicMetrics, map[string]interface{}{
+			"goroutines":       runtime.NumGoroutine(),
+			"heap_alloc":       mem.HeapAlloc,
+			"database":         app.db.Stats(),
+			"database_queries": app.db.QueryStats(),
+			"ws_connections":   app.hub.connections(),
+		})
+	}
+}
+
+// wsHandler streams events to an admin console. Clients choose what they
+// receive by sending {"action":"subscribe"|"unsubscribe","topics":[...]}.
+// Browsers cannot set headers on WebSocket requests, so the authentication
+// token may also be passed in the access_token query parameter.
+func (app *application) wsHandler(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+			app.errorResponse(w, r, status, codeWebSocketHandshake, reason.Error())
+		},
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already sent an error response.
+		return
+	}
+
+	c := &wsClient{
+		send:   make(chan []byte, wsSendBuffer),
+		topics: make(map[string]bool),
+		done:   make(chan struct{}),
+	}
+
+	app.hub.register(c)
+
+	go app.wsWriter(conn, c)
+	app.wsReader(conn, c)
+}
+
+func (app *application) wsReader(conn *websocket.Conn, c *wsClient) {
+	defer func() {
+		app.hub.unregister(c)
+		c.close()
+	}()
+
+	conn.SetReadLimit(4096)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var msg struct {
+			Action string   `json:"action"`
+			Topics []string `json:"topics"`
+		}
+
+		err := conn.ReadJSON(&msg)
+		if err != nil {
+			return
+		}
+
+		app.hub.subscribe(c, msg.Action, msg.Topics)
+	}
+}
+
+// subscribe applies a subscribe or unsubscribe message from a connection. A
+// new subscriber to the errors topic is first sent the recent errors.
+func (h *eventHub) subscribe(c *wsClient, action string, topics []string) {
+	wantBacklog := false
+
+	c.mu.Lock()
+	for _, topic := range topics {
+		if topic != topicMetrics && topic != topicErrors && topic != topicCatalog {
+			continue
+		}
+
+		switch action {
+		case "subscribe":
+			if topic == topicErrors && !c.topics[topic] {
+				wantBacklog = true
+			}
+			c.topics[topic] = true
+		case "unsubscribe":
+			delete(c.topics, topic)
+		}
+	}
+	c.mu.Unlock()
+
+	// The backlog is read only once c.mu is released: publish holds h.mu
+	// while it takes c.mu, so taking h.mu under c.mu would deadlock.
+	if !wantBacklog {
+		return
+	}
+
+	for _, e := range h.recent() {
+		msg, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+
+		select {
+		case c.send <- msg:
+		default:
+		}
+	}
+}
+
+func (app *application) wsWriter(conn *websocket.Conn, c *wsClient) {
+	ticker := time.NewTicker(wsPingInterval)
+
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	write := func(messageType int, data []byte) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		return conn.WriteMessage(messageType, data)
+	}
+
+	for {
+		select {
+		case msg := <-c.send:
+			c.mu.Lock()
+			dropped := c.dropped
+			c.dropped = 0
+			c.mu.Unlock()
+
+			if dropped > 0 {
+				notice, _ := json.Marshal(event{Topic: "system", Time: time.Now(), Data: map[string]int{"dropped": dropped}})
+				if write(websocket.TextMessage, notice) != nil {
+					return
+				}
+			}
+
+			if write(websocket.TextMessage, msg) != nil {
+				return
+			}
+		case <-ticker.C:
+			if write(websocket.PingMessage, nil) != nil {
+				return
+			}
+		case <-c.done:
+			c.mu.Lock()
+			slow := c.slow
+			c.mu.Unlock()
+
+			if slow {
+				write(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "too slow"))
+			}
+			return
+		}
+	}
+}
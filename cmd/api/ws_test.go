@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventHubBackpressure(t *testing.T) {
+	hub := newEventHub()
+
+	c := &wsClient{
+		send:   make(chan []byte, 2),
+		topics: map[string]bool{topicCatalog: true},
+		done:   make(chan struct{}),
+	}
+	hub.register(c)
+
+	hub.publish(topicMetrics, nil)
+	if len(c.send) != 0 {
+		t.Fatal("received an event for a topic it is not subscribed to")
+	}
+
+	for i := 0; i < 5; i++ {
+		hub.publish(topicCatalog, catalogEvent{Action: "created", MovieID: int64(i)})
+	}
+
+	if len(c.send) != 2 || c.dropped != 3 {
+		t.Fatalf("queued %d and dropped %d events; want 2 and 3", len(c.send), c.dropped)
+	}
+
+	var e event
+	if err := json.Unmarshal(<-c.send, &e); err != nil || e.Topic != topicCatalog {
+		t.Fatalf("got event %+v (%v)", e, err)
+	}
+
+	for i := 0; i < wsMaxDropped; i++ {
+		hub.publish(topicCatalog, catalogEvent{Action: "created"})
+	}
+
+	select {
+	case <-c.done:
+	default:
+		t.Fatal("slow connection was not closed")
+	}
+	if hub.connections() != 0 {
+		t.Error("slow connection is still registered")
+	}
+}
+
+func TestEventHubRecentErrors(t *testing.T) {
+	hub := newEventHub()
+
+	for i := 0; i < wsRecentErrors+10; i++ {
+		hub.publish(topicErrors, i)
+	}
+
+	recent := hub.recent()
+	if len(recent) != wsRecentErrors {
+		t.Fatalf("kept %d errors; want %d", len(recent), wsRecentErrors)
+	}
+	if recent[len(recent)-1].Data != wsRecentErrors+9 {
+		t.Errorf("last error is %v; want the most recent", recent[len(recent)-1].Data)
+	}
+
+	var nilHub *eventHub
+	nilHub.publish(topicErrors, "ignored")
+}
+
+func TestEventHubSubscribeWhilePublishing(t *testing.T) {
+	hub := newEventHub()
+
+	c := &wsClient{
+		send:   make(chan []byte, wsSendBuffer),
+		topics: make(map[string]bool),
+		done:   make(chan struct{}),
+	}
+	hub.register(c)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			hub.publish(topicErrors, i)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			hub.subscribe(c, "subscribe", []string{topicErrors})
+			hub.subscribe(c, "unsubscribe", []string{topicErrors})
+		}
+	}()
+
+	// Drain the queue so that publishing does not just drop everything.
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-c.send:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	defer close(stop)
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(10 * time.Second):
+		t.Fatal("subscribing while publishing deadlocked")
+	}
+}
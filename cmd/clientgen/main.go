@@ -0,0 +1,485 @@
+// Command clientgen writes the typed API client in ./client from the OpenAPI
+// document in internal/openapi. It is run by go generate ./client.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/levisthors/greenlight/internal/openapi"
+)
+
+// handWritten are the schemas the client package defines itself.
+var handWritten = map[string]bool{
+	"Error": true,
+}
+
+// initialisms are written in upper case in Go names.
+var initialisms = map[string]bool{
+	"id": true, "ip": true, "cidr": true, "url": true, "uri": true, "http": true, "json": true,
+}
+
+func main() {
+	out := flag.String("o", "client_gen.go", "File to write the client to")
+	flag.Parse()
+
+	doc, err := openapi.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	src, err := generate(doc)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	err = os.WriteFile(*out, src, 0o644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+type generator struct {
+	doc *openapi.Document
+	buf bytes.Buffer
+
+	// pending holds the named types still to be written; written stops a
+	// type being written twice.
+	pending []namedType
+	written map[string]bool
+
+	imports map[string]bool
+}
+
+type namedType struct {
+	name   string
+	schema *openapi.Schema
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// generate returns the formatted source of client_gen.go.
+func generate(doc *openapi.Document) ([]byte, error) {
+	g := &generator{doc: doc, written: make(map[string]bool), imports: map[string]bool{"context": true}}
+
+	names := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		if !handWritten[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		g.pending = append(g.pending, namedType{name, doc.Components.Schemas[name]})
+	}
+	g.writeTypes()
+
+	routes := doc.Routes()
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Operation.OperationID < routes[j].Operation.OperationID
+	})
+
+	for _, route := range routes {
+		err := g.writeOperation(route)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", route.Method, route.Path, err)
+		}
+		g.writeTypes()
+	}
+
+	imports := make([]string, 0, len(g.imports))
+	for imp := range g.imports {
+		imports = append(imports, fmt.Sprintf("%q", imp))
+	}
+	sort.Strings(imports)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by cmd/clientgen from the OpenAPI document. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package client\n\nimport (\n%s\n)\n\n", strings.Join(imports, "\n"))
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+func (g *generator) writeTypes() {
+	for len(g.pending) > 0 {
+		t := g.pending[0]
+		g.pending = g.pending[1:]
+
+		if g.written[t.name] {
+			continue
+		}
+		g.written[t.name] = true
+
+		g.writeType(t.name, t.schema)
+	}
+}
+
+func (g *generator) writeType(name string, s *openapi.Schema) {
+	if s.Description != "" {
+		g.printf("%s\n", comment(name+": "+s.Description))
+	}
+
+	if len(s.Properties) == 0 {
+		g.printf("type %s %s\n\n", name, g.goType(name, s, true))
+		return
+	}
+
+	required := make(map[string]bool)
+	for _, r := range s.Required {
+		required[r] = true
+	}
+
+	props := make([]string, 0, len(s.Properties))
+	for prop := range s.Properties {
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+
+	g.printf("type %s struct {\n", name)
+	for _, prop := range props {
+		ps := s.Properties[prop]
+		field := goName(prop)
+
+		if ps.Description != "" {
+			g.printf("%s\n", comment(ps.Description))
+		}
+
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+		}
+		g.printf("%s %s `json:%q`\n", field, g.goType(name+field, ps, required[prop]), tag)
+	}
+	g.printf("}\n\n")
+}
+
+// goType returns the Go type for s, queueing a named type called hint for
+// inline objects. Optional scalars and objects are pointers, so that the zero
+// value is distinguishable from a missing field.
+func (g *generator) goType(hint string, s *openapi.Schema, required bool) string {
+	pointer := func(t string) string {
+		if required && !s.Nullable {
+			return t
+		}
+		return "*" + t
+	}
+
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		if len(g.doc.Resolve(s).Properties) == 0 {
+			// Maps are nil when missing.
+			return name
+		}
+		return pointer(name)
+	}
+
+	if len(s.OneOf) > 0 {
+		return "interface{}"
+	}
+
+	switch s.Type {
+	case "string":
+		if s.Format == "date-time" {
+			g.imports["time"] = true
+			return pointer("time.Time")
+		}
+		return pointer("string")
+	case "integer":
+		return pointer("int64")
+	case "number":
+		return pointer("float64")
+	case "boolean":
+		return pointer("bool")
+	case "array":
+		return "[]" + g.goType(hint, s.Items, true)
+	case "object":
+		if len(s.Properties) == 0 {
+			if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+				return "map[string]" + g.goType(hint+"Entry", s.AdditionalProperties.Schema, true)
+			}
+			return "map[string]interface{}"
+		}
+		g.pending = append(g.pending, namedType{hint, s})
+		return pointer(hint)
+	default:
+		return "interface{}"
+	}
+}
+
+type param struct {
+	name   string
+	goName string
+	goType string
+}
+
+func (g *generator) writeOperation(route openapi.Route) error {
+	op := route.Operation
+	name := goName(op.OperationID)
+
+	status, response := successResponse(op)
+	if response == nil {
+		// Operations without a response body, such as the WebSocket
+		// upgrade, are not part of the client.
+		return nil
+	}
+
+	var pathParams, queryParams []param
+	for _, p := range op.Parameters {
+		required := p.Required || p.In == "path"
+		pp := param{name: p.Name, goName: goName(p.Name), goType: g.goType(name+goName(p.Name), p.Schema, required)}
+
+		switch p.In {
+		case "path":
+			pathParams = append(pathParams, pp)
+		case "query":
+			queryParams = append(queryParams, pp)
+		default:
+			return fmt.Errorf("unsupported %s parameter %s", p.In, p.Name)
+		}
+	}
+
+	args := []string{"ctx context.Context"}
+	for _, p := range pathParams {
+		args = append(args, lowerFirst(p.goName)+" "+p.goType)
+	}
+
+	if len(queryParams) > 0 {
+		g.printf("// %sParams are the query parameters of %s.\n", name, name)
+		g.printf("type %sParams struct {\n", name)
+		for _, p := range queryParams {
+			g.printf("%s %s\n", p.goName, p.goType)
+		}
+		g.printf("}\n\n")
+		args = append(args, "params "+name+"Params")
+	}
+
+	// JSON bodies are passed as values; anything else as a reader.
+	var bodyType, bodyContentType string
+	if op.RequestBody != nil {
+		if schema := openapi.JSONSchema(op.RequestBody.Content); schema != nil {
+			bodyType = g.goType(name+"Request", schema, true)
+			args = append(args, "body "+bodyType)
+		} else {
+			for ct := range op.RequestBody.Content {
+				bodyContentType = ct
+			}
+			args = append(args, "body io.Reader")
+			g.imports["io"] = true
+		}
+	}
+
+	jsonResponse := openapi.JSONSchema(response.Content)
+
+	var result string
+	if jsonResponse != nil {
+		result = strings.TrimPrefix(g.goType(name+"Response", jsonResponse, true), "*")
+	}
+
+	doc := fmt.Sprintf("%s calls %s %s", name, route.Method, route.Path)
+	if op.Summary != "" {
+		doc += ": " + strings.TrimSuffix(lowerFirst(op.Summary), ".")
+	}
+	g.printf("%s\n", comment(fmt.Sprintf("%s. It succeeds with %d.", doc, status)))
+	if jsonResponse == nil {
+		g.printf("// The caller must close the returned body.\n")
+		g.printf("func (c *Client) %s(%s) (io.ReadCloser, error) {\n", name, strings.Join(args, ", "))
+		g.imports["io"] = true
+	} else {
+		g.printf("func (c *Client) %s(%s) (*%s, error) {\n", name, strings.Join(args, ", "), result)
+	}
+
+	g.printf("path := %s\n", g.pathExpr(route.Path, pathParams))
+
+	query := "nil"
+	if len(queryParams) > 0 {
+		query = "query"
+		g.imports["net/url"] = true
+		g.printf("query := url.Values{}\n")
+		for _, p := range queryParams {
+			g.printf("setQuery(query, %q, params.%s)\n", p.name, p.goName)
+		}
+	}
+
+	if jsonResponse == nil {
+		bodyArg := "nil"
+		if bodyContentType != "" {
+			bodyArg = "body"
+		}
+		var accept string
+		for ct := range response.Content {
+			accept = ct
+		}
+		g.printf("resp, err := c.send(ctx, %q, path, %s, %q, %q, %s)\n", route.Method, query, accept, bodyContentType, bodyArg)
+		g.printf("if err != nil {\nreturn nil, err\n}\n")
+		g.printf("return resp.Body, nil\n}\n\n")
+		return nil
+	}
+
+	if bodyContentType != "" {
+		g.printf("resp, err := c.send(ctx, %q, path, %s, \"application/json\", %q, body)\n", route.Method, query, bodyContentType)
+		g.printf("if err != nil {\nreturn nil, err\n}\n")
+		g.printf("defer resp.Body.Close()\n\n")
+		g.imports["encoding/json"] = true
+		g.printf("var out %s\n", result)
+		g.printf("err = json.NewDecoder(resp.Body).Decode(&out)\n")
+		g.printf("if err != nil {\nreturn nil, err\n}\n")
+		g.printf("return &out, nil\n}\n\n")
+	} else {
+		in := "nil"
+		if bodyType != "" {
+			in = "body"
+		}
+		g.printf("var out %s\n", result)
+		g.printf("err := c.do(ctx, %q, path, %s, %s, &out)\n", route.Method, query, in)
+		g.printf("if err != nil {\nreturn nil, err\n}\n")
+		g.printf("return &out, nil\n}\n\n")
+	}
+
+	g.writePager(name, args, pathParams, jsonResponse)
+	return nil
+}
+
+// writePager adds an All method to list operations that take a page
+// parameter and return metadata alongside a single array of items.
+func (g *generator) writePager(name string, args []string, pathParams []param, response *openapi.Schema) {
+	s := g.doc.Resolve(response)
+	if s.Properties["metadata"] == nil {
+		return
+	}
+
+	var items string
+	for prop, ps := range s.Properties {
+		if ps.Type == "array" {
+			if items != "" {
+				return
+			}
+			items = prop
+		}
+	}
+	if items == "" {
+		return
+	}
+
+	itemType := g.goType("", s.Properties[items].Items, true)
+
+	callArgs := []string{"ctx"}
+	for _, p := range pathParams {
+		callArgs = append(callArgs, lowerFirst(p.goName))
+	}
+	callArgs = append(callArgs, "params")
+
+	g.printf("// %sAll calls %s for every page, starting from params.Page, and\n", name, name)
+	g.printf("// returns the %s from all of them.\n", items)
+	g.printf("func (c *Client) %sAll(%s) ([]%s, error) {\n", name, strings.Join(args, ", "), itemType)
+	g.printf("var all []%s\n", itemType)
+	g.printf("for {\n")
+	g.printf("resp, err := c.%s(%s)\n", name, strings.Join(callArgs, ", "))
+	g.printf("if err != nil {\nreturn nil, err\n}\n")
+	g.printf("all = append(all, resp.%s...)\n\n", goName(items))
+	g.printf("next := nextPage(resp.Metadata, len(resp.%s))\n", goName(items))
+	g.printf("if next == 0 {\nreturn all, nil\n}\n")
+	g.printf("params.Page = &next\n")
+	g.printf("}\n}\n\n")
+}
+
+// successResponse returns the lowest 2xx response that has a body.
+func successResponse(op *openapi.Operation) (int, *openapi.Response) {
+	codes := make([]string, 0, len(op.Responses))
+	for code := range op.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	for _, code := range codes {
+		if strings.HasPrefix(code, "2") && len(op.Responses[code].Content) > 0 {
+			var status int
+			fmt.Sscan(code, &status)
+			return status, op.Responses[code]
+		}
+	}
+	return 0, nil
+}
+
+// pathExpr returns a Go expression building path with the parameters filled
+// in.
+func (g *generator) pathExpr(path string, params []param) string {
+	expr := fmt.Sprintf("%q", path)
+	for _, p := range params {
+		var value string
+		if p.goType == "int64" {
+			g.imports["strconv"] = true
+			value = "strconv.FormatInt(" + lowerFirst(p.goName) + ", 10)"
+		} else {
+			g.imports["net/url"] = true
+			value = "url.PathEscape(" + lowerFirst(p.goName) + ")"
+		}
+		expr = strings.Replace(expr, "{"+p.name+"}", `" + `+value+` + "`, 1)
+	}
+	return strings.TrimSuffix(expr, ` + ""`)
+}
+
+// goName turns snake_case and camelCase names into exported Go names.
+func goName(s string) string {
+	var b strings.Builder
+
+	words := strings.FieldsFunc(s, func(r rune) bool { return r == '_' || r == '-' || r == '.' })
+	for _, word := range words {
+		// Split camelCase words at each upper-case letter.
+		start := 0
+		for i := 1; i <= len(word); i++ {
+			if i == len(word) || (word[i] >= 'A' && word[i] <= 'Z') {
+				part := word[start:i]
+				if initialisms[strings.ToLower(part)] {
+					b.WriteString(strings.ToUpper(part))
+				} else {
+					b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+				}
+				start = i
+			}
+		}
+	}
+
+	return b.String()
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	if initialisms[strings.ToLower(s)] {
+		return strings.ToLower(s)
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// comment wraps text into Go line comments of about 76 columns.
+func comment(text string) string {
+	var lines []string
+	line := "//"
+
+	for _, word := range strings.Fields(text) {
+		if len(line)+1+len(word) > 76 && line != "//" {
+			lines = append(lines, line)
+			line = "//"
+		}
+		line += " " + word
+	}
+
+	return strings.Join(append(lines, line), "\n")
+}
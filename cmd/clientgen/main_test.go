@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/levisthors/greenlight/internal/openapi"
+)
+
+func TestGeneratedClientIsCurrent(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := generate(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile("../../client/client_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Error("client/client_gen.go is out of date with the OpenAPI document; run go generate ./client")
+	}
+}
+
+func TestGoName(t *testing.T) {
+	tests := map[string]string{
+		"movie_id":        "MovieID",
+		"_links":          "Links",
+		"listIPRules":     "ListIPRules",
+		"showCurrentUser": "ShowCurrentUser",
+		"cidr":            "CIDR",
+	}
+
+	for in, want := range tests {
+		if got := goName(in); got != want {
+			t.Errorf("goName(%q) = %q; want %q", in, got, want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ go 1.22.3
 
 require (
 	github.com/go-mail/mail/v2 v2.3.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/lib/pq v1.10.9
 	golang.org/x/crypto v0.23.0
@@ -11,6 +12,8 @@ require (
 )
 
 require (
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/text v0.15.0 // indirect
 	gopkg.in/alexcesaro/quotedprintable.v3 v3.0.0-20150716171945-2caba252f4dc // indirect
 	gopkg.in/mail.v2 v2.3.1 // indirect
 )
@@ -0,0 +1,119 @@
+// Package analytics delivers batches of anonymised usage events to a sink:
+// a local file of JSON lines or an HTTP collector.
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is one thing a client did. User is a keyed hash of the user ID, or
+// empty for anonymous requests; events never carry the ID itself.
+type Event struct {
+	Type string                 `json:"type"`
+	Time time.Time              `json:"time"`
+	User string                 `json:"user,omitempty"`
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+type Sink interface {
+	Write(ctx context.Context, events []Event) error
+	Close() error
+}
+
+// Open returns the sink for a target: file:<path> appends JSON lines to a
+// file, and an http:// or https:// URL POSTs each batch as JSON lines.
+func Open(target string) (Sink, error) {
+	switch {
+	case strings.HasPrefix(target, "file:"):
+		f, err := os.OpenFile(strings.TrimPrefix(target, "file:"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+		if err != nil {
+			return nil, err
+		}
+		return &fileSink{f: f}, nil
+	case strings.HasPrefix(target, "http://"), strings.HasPrefix(target, "https://"):
+		return &httpSink{url: target, client: &http.Client{Timeout: 10 * time.Second}}, nil
+	case strings.HasPrefix(target, "kafka://"):
+		return nil, errors.New("kafka sinks are not supported; send events to a Kafka REST proxy with an http(s) URL")
+	default:
+		return nil, fmt.Errorf("unknown analytics sink %q", target)
+	}
+}
+
+func encodeLines(events []Event) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	for _, e := range events {
+		err := enc.Encode(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+type fileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func (s *fileSink) Write(ctx context.Context, events []Event) error {
+	lines, err := encodeLines(events)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.f.Write(lines)
+	return err
+}
+
+func (s *fileSink) Close() error {
+	return s.f.Close()
+}
+
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *httpSink) Write(ctx context.Context, events []Event) error {
+	lines, err := encodeLines(events)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(lines))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("analytics collector returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	return nil
+}
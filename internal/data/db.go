@@ -0,0 +1,221 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/jsonlog"
+)
+
+var queryBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+}
+
+// DB wraps a connection pool so that every statement issued by the models,
+// including those in transactions started with BeginTx, is timed, recorded in a per-query latency histogram and, if it runs for longer
+// than the slow query threshold, logged along with its SQL. Arguments are
+// never logged, as they include credentials and other personal data.
+type DB struct {
+	*sql.DB
+	logger        *jsonlog.Logger
+	slowThreshold time.Duration
+
+	mu    sync.Mutex
+	stats map[string]*queryHistogram
+
+	movies *MovieCache
+}
+
+type queryHistogram struct {
+	count   int64
+	total   time.Duration
+	max     time.Duration
+	buckets []int64
+}
+
+func NewDB(db *sql.DB, logger *jsonlog.Logger, slowThreshold time.Duration) *DB {
+	return &DB{
+		DB:            db,
+		logger:        logger,
+		slowThreshold: slowThreshold,
+		stats:         make(map[string]*queryHistogram),
+	}
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := db.DB.ExecContext(ctx, query, args...)
+	db.observe(callerName(), query, args, time.Since(start))
+	return result, err
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	db.observe(callerName(), query, args, time.Since(start))
+	return rows, err
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	db.observe(callerName(), query, args, time.Since(start))
+	return row
+}
+
+// BeginTx starts a transaction whose statements are timed and recorded like
+// those run on db itself.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, db: db}, nil
+}
+
+// Tx is a transaction on an instrumented DB. Statements are recorded under
+// the name of the function that issued them, as on DB.
+type Tx struct {
+	*sql.Tx
+	db *DB
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := tx.Tx.ExecContext(ctx, query, args...)
+	tx.db.observe(callerName(), query, args, time.Since(start))
+	return result, err
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := tx.Tx.QueryContext(ctx, query, args...)
+	tx.db.observe(callerName(), query, args, time.Since(start))
+	return rows, err
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := tx.Tx.QueryRowContext(ctx, query, args...)
+	tx.db.observe(callerName(), query, args, time.Since(start))
+	return row
+}
+
+// PrepareContext prepares a statement in the transaction. Its executions are
+// recorded under the name of the function that prepared it.
+func (tx *Tx) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
+	stmt, err := tx.Tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &Stmt{Stmt: stmt, db: tx.db, name: callerName(), query: query}, nil
+}
+
+// Stmt is a prepared statement in an instrumented transaction.
+type Stmt struct {
+	*sql.Stmt
+	db    *DB
+	name  string
+	query string
+}
+
+func (s *Stmt) ExecContext(ctx context.Context, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := s.Stmt.ExecContext(ctx, args...)
+	s.db.observe(s.name, s.query, args, time.Since(start))
+	return result, err
+}
+
+func (db *DB) observe(name, query string, args []interface{}, duration time.Duration) {
+	db.mu.Lock()
+	h, ok := db.stats[name]
+	if !ok {
+		h = &queryHistogram{buckets: make([]int64, len(queryBuckets)+1)}
+		db.stats[name] = h
+	}
+
+	h.count++
+	h.total += duration
+	if duration > h.max {
+		h.max = duration
+	}
+
+	i := 0
+	for i < len(queryBuckets) && duration > queryBuckets[i] {
+		i++
+	}
+	h.buckets[i]++
+	db.mu.Unlock()
+
+	if db.slowThreshold > 0 && duration >= db.slowThreshold && db.logger != nil {
+		db.logger.PrintInfo("slow query", map[string]string{
+			"query_name": name,
+			"duration":   duration.String(),
+			"query":      strings.Join(strings.Fields(query), " "),
+			"arg_count":  strconv.Itoa(len(args)),
+		})
+	}
+}
+
+// QueryStats returns a snapshot of the latency histograms keyed by query name,
+// in a shape suitable for publishing through expvar. Bucket counts are
+// cumulative, so each "le" entry holds the number of queries that completed
+// within that duration.
+func (db *DB) QueryStats() map[string]interface{} {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	snapshot := make(map[string]interface{}, len(db.stats))
+
+	for name, h := range db.stats {
+		buckets := make(map[string]int64, len(h.buckets))
+
+		var cumulative int64
+		for i, n := range h.buckets {
+			cumulative += n
+			if i < len(queryBuckets) {
+				buckets[queryBuckets[i].String()] = cumulative
+			} else {
+				buckets["+Inf"] = cumulative
+			}
+		}
+
+		snapshot[name] = map[string]interface{}{
+			"count":    h.count,
+			"total_ms": float64(h.total) / float64(time.Millisecond),
+			"max_ms":   float64(h.max) / float64(time.Millisecond),
+			"le":       buckets,
+		}
+	}
+
+	return snapshot
+}
+
+// callerName reports the model method that issued a statement, such as
+// "MovieModel.Get", which is used as the query name in metrics and logs.
+func callerName() string {
+	pc, _, _, ok := runtime.Caller(2)
+	if !ok {
+		return "unknown"
+	}
+
+	name := runtime.FuncForPC(pc).Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = name[strings.Index(name, ".")+1:]
+
+	return strings.NewReplacer("(*", "", "(", "", ")", "").Replace(name)
+}
@@ -0,0 +1,238 @@
+package data
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix marks a column value as ciphertext. Values without it are
+// plaintext written before encryption was enabled, and are read as-is.
+const encryptedPrefix = "enc:"
+
+var (
+	ErrUnknownEncryptionKey = errors.New("value is encrypted with an unknown key")
+	ErrNoEncryptionKeys     = errors.New("encryption keys are not configured")
+)
+
+// Keyring holds the AES-256-GCM keys used to encrypt columns, identified by
+// short key IDs that are stored alongside each ciphertext. New values are
+// always encrypted with the current key; older keys are kept so that existing
+// values can still be read until they are rotated.
+//
+// A nil *Keyring means encryption is not configured: values are stored as
+// plaintext and blind indexes are unkeyed.
+type Keyring struct {
+	currentID string
+	keys      map[string]cipher.AEAD
+	hmacKey   []byte
+}
+
+func NewKeyring(currentID string, keys map[string][]byte, hmacKey []byte) (*Keyring, error) {
+	if _, ok := keys[currentID]; !ok {
+		return nil, fmt.Errorf("current key %q is not in the keyring", currentID)
+	}
+	if len(hmacKey) < 32 {
+		return nil, errors.New("HMAC key must be at least 32 bytes")
+	}
+
+	k := &Keyring{
+		currentID: currentID,
+		keys:      make(map[string]cipher.AEAD),
+		hmacKey:   hmacKey,
+	}
+
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key ID %q", id)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %q must be 32 bytes", id)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+
+		k.keys[id], err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return k, nil
+}
+
+// ParseKeyring builds a keyring from a comma-separated list of id:base64key
+// pairs, the first of which is the current key, and a base64 HMAC key. It
+// returns nil if keys is empty.
+func ParseKeyring(keys, hmacKey string) (*Keyring, error) {
+	if keys == "" {
+		return nil, nil
+	}
+
+	var currentID string
+	parsed := make(map[string][]byte)
+
+	for _, pair := range strings.Split(keys, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("encryption key %q must be in id:base64key form", pair)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", id, err)
+		}
+
+		if currentID == "" {
+			currentID = id
+		}
+		parsed[id] = key
+	}
+
+	decodedHMACKey, err := base64.StdEncoding.DecodeString(hmacKey)
+	if err != nil {
+		return nil, fmt.Errorf("HMAC key: %w", err)
+	}
+
+	return NewKeyring(currentID, parsed, decodedHMACKey)
+}
+
+func (k *Keyring) encrypt(plaintext string) (string, error) {
+	aead := k.keys[k.currentID]
+
+	nonce := make([]byte, aead.NonceSize())
+
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return encryptedPrefix + k.currentID + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func (k *Keyring) decrypt(value string) (string, error) {
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if !ok {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	aead, ok := k.keys[id]
+	if !ok {
+		return "", ErrUnknownEncryptionKey
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// needsRotation reports whether a stored value is not yet encrypted with the
+// current key.
+func (k *Keyring) needsRotation(value string) bool {
+	return !strings.HasPrefix(value, encryptedPrefix+k.currentID+":")
+}
+
+// Encrypted returns a query argument that stores s encrypted with the current
+// key.
+func (k *Keyring) Encrypted(s string) driver.Valuer {
+	return encryptedString{keys: k, value: s}
+}
+
+// Decrypted returns a scan destination that decrypts a value written by
+// Encrypted into dst. Plaintext values, written before encryption was
+// enabled, are read as-is.
+func (k *Keyring) Decrypted(dst *string) sql.Scanner {
+	return decryptedString{keys: k, dst: dst}
+}
+
+type encryptedString struct {
+	keys  *Keyring
+	value string
+}
+
+func (s encryptedString) Value() (driver.Value, error) {
+	if s.keys == nil {
+		return s.value, nil
+	}
+
+	return s.keys.encrypt(s.value)
+}
+
+type decryptedString struct {
+	keys *Keyring
+	dst  *string
+}
+
+func (s decryptedString) Scan(src interface{}) error {
+	var value string
+
+	switch src := src.(type) {
+	case string:
+		value = src
+	case []byte:
+		value = string(src)
+	default:
+		return fmt.Errorf("cannot scan %T into an encrypted string", src)
+	}
+
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		*s.dst = value
+		return nil
+	}
+
+	if s.keys == nil {
+		return ErrUnknownEncryptionKey
+	}
+
+	plaintext, err := s.keys.decrypt(value)
+	if err != nil {
+		return err
+	}
+
+	*s.dst = plaintext
+	return nil
+}
+
+// blindIndex returns a keyed hash of a value for equality lookups on an
+// encrypted column. Without a keyring the hash is unkeyed, which still
+// supports lookups but should only be relied on in development.
+func (k *Keyring) blindIndex(value string) []byte {
+	var key []byte
+	if k != nil {
+		key = k.hmacKey
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// EmailIndex returns the blind index of an email address. Addresses are
+// compared case-insensitively, as the citext column they replace was.
+func (k *Keyring) EmailIndex(email string) []byte {
+	return k.blindIndex(strings.ToLower(email))
+}
@@ -0,0 +1,135 @@
+package data
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKeyring(t *testing.T, currentID string, ids ...string) *Keyring {
+	t.Helper()
+
+	keys := make(map[string][]byte)
+	for _, id := range ids {
+		keys[id] = bytes.Repeat([]byte(id[:1]), 32)
+	}
+
+	k, err := NewKeyring(currentID, keys, bytes.Repeat([]byte("h"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	keys := testKeyring(t, "a", "a")
+
+	value, err := keys.Encrypted("alice@example.com").Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := value.(string)
+	if !strings.HasPrefix(stored, "enc:a:") || strings.Contains(stored, "alice") {
+		t.Fatalf("stored value %q is not ciphertext under key a", stored)
+	}
+
+	again, _ := keys.Encrypted("alice@example.com").Value()
+	if again == value {
+		t.Error("encrypting the same value twice gave the same ciphertext")
+	}
+
+	var got string
+	if err := keys.Decrypted(&got).Scan([]byte(stored)); err != nil {
+		t.Fatal(err)
+	}
+	if got != "alice@example.com" {
+		t.Errorf("got %q; want %q", got, "alice@example.com")
+	}
+}
+
+func TestEncryptedRotation(t *testing.T) {
+	old, _ := testKeyring(t, "a", "a").Encrypted("bob@example.com").Value()
+
+	keys := testKeyring(t, "b", "a", "b")
+
+	if !keys.needsRotation(old.(string)) {
+		t.Error("value under the old key does not need rotation")
+	}
+	if !keys.needsRotation("bob@example.com") {
+		t.Error("plaintext value does not need rotation")
+	}
+
+	var got string
+	if err := keys.Decrypted(&got).Scan(old); err != nil || got != "bob@example.com" {
+		t.Errorf("reading value under old key: got %q, %v", got, err)
+	}
+
+	current, _ := keys.Encrypted("bob@example.com").Value()
+	if keys.needsRotation(current.(string)) {
+		t.Error("value under the current key needs rotation")
+	}
+
+	err := testKeyring(t, "b", "b").Decrypted(&got).Scan(old)
+	if !errors.Is(err, ErrUnknownEncryptionKey) {
+		t.Errorf("reading value under a removed key: got %v; want %v", err, ErrUnknownEncryptionKey)
+	}
+}
+
+func TestEncryptedWithoutKeyring(t *testing.T) {
+	var keys *Keyring
+
+	value, err := keys.Encrypted("carol@example.com").Value()
+	if err != nil || value != "carol@example.com" {
+		t.Errorf("writing without a keyring: got %v, %v", value, err)
+	}
+
+	var got string
+	if err := keys.Decrypted(&got).Scan("carol@example.com"); err != nil || got != "carol@example.com" {
+		t.Errorf("reading plaintext value: got %q, %v", got, err)
+	}
+
+	stored, _ := testKeyring(t, "a", "a").Encrypted("carol@example.com").Value()
+	if err := keys.Decrypted(&got).Scan(stored); !errors.Is(err, ErrUnknownEncryptionKey) {
+		t.Errorf("reading ciphertext without a keyring: got %v; want %v", err, ErrUnknownEncryptionKey)
+	}
+}
+
+func TestEmailIndex(t *testing.T) {
+	keys := testKeyring(t, "a", "a")
+
+	if !bytes.Equal(keys.EmailIndex("Dave@Example.com"), keys.EmailIndex("dave@example.com")) {
+		t.Error("email index is case sensitive")
+	}
+	if bytes.Equal(keys.EmailIndex("dave@example.com"), keys.EmailIndex("erin@example.com")) {
+		t.Error("different emails have the same index")
+	}
+	if bytes.Equal(keys.EmailIndex("dave@example.com"), (*Keyring)(nil).EmailIndex("dave@example.com")) {
+		t.Error("email index is not keyed")
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	hmacKey := "aGhoaGhoaGhoaGhoaGhoaGhoaGhoaGhoaGhoaGhoaGg="
+	key := "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s="
+
+	k, err := ParseKeyring("k2:"+key+", k1:"+key, hmacKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.currentID != "k2" || len(k.keys) != 2 {
+		t.Errorf("got current key %q and %d keys; want k2 and 2", k.currentID, len(k.keys))
+	}
+
+	if k, err := ParseKeyring("", ""); k != nil || err != nil {
+		t.Errorf("empty keys: got %v, %v; want nil, nil", k, err)
+	}
+
+	for _, keys := range []string{"k1", "k1:not-base64!", "k1:c2hvcnQ="} {
+		if _, err := ParseKeyring(keys, hmacKey); err == nil {
+			t.Errorf("%q: expected an error", keys)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Erasure is a request to erase a user's personal data. Pending erasures are
+// carried out once ScheduledFor has passed, unless cancelled first. The rows
+// are kept afterwards as an audit trail of what was erased and when; they
+// reference the user by ID only.
+type Erasure struct {
+	ID           int64            `json:"id"`
+	UserID       int64            `json:"-"`
+	RequestedAt  time.Time        `json:"requested_at"`
+	ScheduledFor time.Time        `json:"scheduled_for"`
+	CancelledAt  *time.Time       `json:"cancelled_at,omitempty"`
+	CompletedAt  *time.Time       `json:"completed_at,omitempty"`
+	Summary      map[string]int64 `json:"summary,omitempty"`
+}
+
+type ErasureModel struct {
+	DB *DB
+}
+
+// Request schedules an erasure of the user's data after the grace period.
+// If one is already pending it is returned unchanged.
+func (m *ErasureModel) Request(userID int64, grace time.Duration) (*Erasure, error) {
+	query := `
+	INSERT INTO user_erasures (user_id, scheduled_for)
+	VALUES ($1, $2)
+	ON CONFLICT (user_id) WHERE cancelled_at IS NULL AND completed_at IS NULL
+	DO UPDATE SET scheduled_for = user_erasures.scheduled_for
+	RETURNING id, user_id, requested_at, scheduled_for`
+
+	erasure := Erasure{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID, time.Now().Add(grace)).Scan(
+		&erasure.ID,
+		&erasure.UserID,
+		&erasure.RequestedAt,
+		&erasure.ScheduledFor,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &erasure, nil
+}
+
+// Cancel cancels the user's pending erasure.
+func (m *ErasureModel) Cancel(userID int64) error {
+	query := `
+	UPDATE user_erasures
+	SET cancelled_at = NOW()
+	WHERE user_id = $1 AND cancelled_at IS NULL AND completed_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetDue returns the pending erasures whose grace period has passed.
+func (m *ErasureModel) GetDue() ([]*Erasure, error) {
+	query := `
+	SELECT id, user_id, requested_at, scheduled_for
+	FROM user_erasures
+	WHERE cancelled_at IS NULL AND completed_at IS NULL AND scheduled_for <= NOW()
+	ORDER BY scheduled_for`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	erasures := []*Erasure{}
+
+	for rows.Next() {
+		var erasure Erasure
+
+		err := rows.Scan(&erasure.ID, &erasure.UserID, &erasure.RequestedAt, &erasure.ScheduledFor)
+		if err != nil {
+			return nil, err
+		}
+
+		erasures = append(erasures, &erasure)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return erasures, nil
+}
+
+// erasureStatements remove or anonymise a user's personal data. Each is
+// passed the user ID, and the number of rows it affects is recorded in the
+// erasure summary under its key. The users row itself is kept, with its PII
+// replaced, so that anything referencing it stays valid. The user's export
+// (cmd/api/export.go) covers the same tables.
+var erasureStatements = []struct {
+	key   string
+	query string
+}{
+	{"tokens", `DELETE FROM tokens WHERE user_id = $1`},
+	{"permissions", `DELETE FROM users_permissions WHERE user_id = $1`},
+	{"exports", `DELETE FROM user_exports WHERE user_id = $1`},
+	{"notifications", `DELETE FROM notifications WHERE user_id = $1`},
+	{"saved_searches", `DELETE FROM saved_searches WHERE user_id = $1`},
+	{"watch_history", `DELETE FROM watch_history WHERE user_id = $1`},
+	{"operations", `DELETE FROM operations WHERE user_id = $1`},
+	{"usage", `DELETE FROM usage WHERE user_id = $1`},
+	{"users", `
+	UPDATE users
+	SET name = '', email = 'erased-' || id || '@erased.invalid', email_hash = NULL, password_hash = '\x',
+		activated = false, erased_at = NOW(), version = version + 1
+	WHERE id = $1 AND erased_at IS NULL`},
+}
+
+// Complete erases the user's data and marks the erasure as done, in a single
+// transaction.
+func (m *ErasureModel) Complete(erasure *Erasure) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	summary := make(map[string]int64)
+
+	for _, stmt := range erasureStatements {
+		result, err := tx.ExecContext(ctx, stmt.query, erasure.UserID)
+		if err != nil {
+			return err
+		}
+
+		summary[stmt.key], err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+	}
+
+	js, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE user_erasures
+	SET completed_at = NOW(), summary = $2
+	WHERE id = $1 AND cancelled_at IS NULL AND completed_at IS NULL
+	RETURNING completed_at`
+
+	var completedAt time.Time
+
+	err = tx.QueryRowContext(ctx, query, erasure.ID, js).Scan(&completedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// Cancelled or completed since it was loaded.
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	erasure.CompletedAt = &completedAt
+	erasure.Summary = summary
+
+	m.DB.notify(Change{Entity: EntityUser, Action: ChangeDeleted, ID: erasure.UserID})
+	return nil
+}
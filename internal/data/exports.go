@@ -0,0 +1,58 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Export is a user's data archive, kept until it is replaced by a newer
+// export or the user is deleted.
+type Export struct {
+	UserID    int64
+	CreatedAt time.Time
+	Archive   []byte
+}
+
+type ExportModel struct {
+	DB *DB
+}
+
+// Upsert stores an export, replacing any previous export for the user.
+func (m *ExportModel) Upsert(export *Export) error {
+	query := `
+	INSERT INTO user_exports (user_id, archive)
+	VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET archive = EXCLUDED.archive, created_at = NOW()
+	RETURNING created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, export.UserID, export.Archive).Scan(&export.CreatedAt)
+}
+
+func (m *ExportModel) GetForUser(userID int64) (*Export, error) {
+	query := `
+	SELECT user_id, created_at, archive
+	FROM user_exports
+	WHERE user_id = $1`
+
+	var export Export
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&export.UserID, &export.CreatedAt, &export.Archive)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &export, nil
+}
@@ -7,19 +7,30 @@ import (
 	"github.com/levisthors/greenlight/internal/validator"
 )
 
+// Count modes control how the total record count in Metadata is worked out.
+// Exact counts every matching row, estimated uses the planner's statistics,
+// which is instant on huge tables, and none skips counting.
+const (
+	CountExact     = "exact"
+	CountEstimated = "estimated"
+	CountNone      = "none"
+)
+
 type Filters struct {
 	Page         int
 	PageSize     int
 	Sort         string
 	SortSafelist []string
+	Count        string
 }
 
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage           int  `json:"current_page,omitempty"`
+	PageSize              int  `json:"page_size,omitempty"`
+	FirstPage             int  `json:"first_page,omitempty"`
+	LastPage              int  `json:"last_page,omitempty"`
+	TotalRecords          int  `json:"total_records,omitempty"`
+	TotalRecordsEstimated bool `json:"total_records_estimated,omitempty"`
 }
 
 func (f *Filters) sortColumn() string {
@@ -48,6 +59,13 @@ func (f *Filters) offset() int {
 	return (f.Page - 1) * f.PageSize
 }
 
+func (f *Filters) countMode() string {
+	if f.Count == "" {
+		return CountExact
+	}
+	return f.Count
+}
+
 func calculateMetadata(totalRecords, page, pageSize int) Metadata {
 	if totalRecords == 0 {
 		return Metadata{}
@@ -68,4 +86,5 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
 
 	v.Check(validator.In(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+	v.Check(f.Count == "" || validator.In(f.Count, CountExact, CountEstimated, CountNone), "count", "must be one of exact, estimated or none")
 }
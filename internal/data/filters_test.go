@@ -0,0 +1,41 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+func FuzzFilters(f *testing.F) {
+	f.Add(1, 20, "-year", 0)
+	f.Add(0, 0, "", 10)
+	f.Add(10_000_000, 100, "title", 1_000_000_000)
+	f.Add(-1, -1, "-", -1)
+
+	safelist := []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+
+	f.Fuzz(func(t *testing.T, page, pageSize int, sort string, totalRecords int) {
+		filters := Filters{Page: page, PageSize: pageSize, Sort: sort, SortSafelist: safelist}
+
+		v := validator.New()
+		if ValidateFilters(v, filters); !v.Valid() {
+			return
+		}
+
+		filters.sortColumn()
+		filters.sortDirection()
+
+		if filters.offset() < 0 {
+			t.Errorf("negative offset %d for page %d and page size %d", filters.offset(), page, pageSize)
+		}
+
+		if totalRecords < 0 {
+			return
+		}
+
+		metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+		if totalRecords > 0 && metadata.LastPage < 1 {
+			t.Errorf("last page %d for %d records", metadata.LastPage, totalRecords)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+// WriteFreeze puts a write endpoint, named by its route, into read-only mode.
+// Requests to it are refused with Message until the freeze is lifted.
+type WriteFreeze struct {
+	Route     string    `json:"route"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func ValidateWriteFreeze(v *validator.Validator, f *WriteFreeze) {
+	v.Check(len(f.Message) <= 500, "message", "must not be more than 500 bytes long")
+}
+
+type WriteFreezeModel struct {
+	DB *DB
+}
+
+// Upsert freezes a route, or replaces the message of an existing freeze.
+func (m *WriteFreezeModel) Upsert(f *WriteFreeze) error {
+	query := `
+	INSERT INTO write_freezes (route, message)
+	VALUES ($1, $2)
+	ON CONFLICT (route) DO UPDATE SET message = EXCLUDED.message
+	RETURNING created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, f.Route, f.Message).Scan(&f.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	m.DB.notify(Change{Entity: EntityWriteFreeze, Action: ChangeUpdated})
+	return nil
+}
+
+func (m *WriteFreezeModel) GetAll() ([]*WriteFreeze, error) {
+	query := `
+	SELECT route, message, created_at
+	FROM write_freezes
+	ORDER BY route`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	freezes := []*WriteFreeze{}
+
+	for rows.Next() {
+		var f WriteFreeze
+
+		err := rows.Scan(&f.Route, &f.Message, &f.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		freezes = append(freezes, &f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return freezes, nil
+}
+
+func (m *WriteFreezeModel) Delete(route string) error {
+	query := `
+	DELETE FROM write_freezes
+	WHERE route = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, route)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	m.DB.notify(Change{Entity: EntityWriteFreeze, Action: ChangeDeleted})
+	return nil
+}
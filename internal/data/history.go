@@ -0,0 +1,142 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+// Watch records that a user watched a movie. Progress is how far in they got,
+// in seconds, and is what clients resume playback from.
+type Watch struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"-"`
+	MovieID   int64     `json:"movie_id"`
+	WatchedAt time.Time `json:"watched_at"`
+	Progress  *int32    `json:"progress,omitempty"`
+}
+
+// HistoryEntry is one movie in a user's watch history. Repeat watches are
+// folded together: WatchedAt and Progress come from the latest one and Views
+// counts them all.
+type HistoryEntry struct {
+	MovieID   int64     `json:"movie_id"`
+	Title     string    `json:"title"`
+	WatchedAt time.Time `json:"watched_at"`
+	Progress  *int32    `json:"progress,omitempty"`
+	Views     int       `json:"views"`
+}
+
+func ValidateWatch(v *validator.Validator, w *Watch, runtime Runtime) {
+	v.Check(!w.WatchedAt.After(time.Now().Add(time.Minute)), "watched_at", "must not be in the future")
+
+	if w.Progress != nil {
+		v.Check(*w.Progress >= 0, "progress", "must not be negative")
+		if runtime > 0 {
+			v.Check(*w.Progress <= int32(runtime)*60, "progress", "must not be greater than the movie's runtime")
+		}
+	}
+}
+
+type HistoryModel struct {
+	DB *DB
+}
+
+func (m *HistoryModel) Insert(w *Watch) error {
+	query := `
+	INSERT INTO watch_history (user_id, movie_id, watched_at, progress)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, w.UserID, w.MovieID, w.WatchedAt, w.Progress).Scan(&w.ID)
+}
+
+// GetForUser returns a page of the user's history, one entry per movie, most
+// recently watched first.
+func (m *HistoryModel) GetForUser(userID int64, filters Filters) ([]*HistoryEntry, Metadata, error) {
+	query := `
+	WITH latest AS (
+		SELECT DISTINCT ON (movie_id) movie_id, watched_at, progress,
+			count(*) OVER (PARTITION BY movie_id) AS views
+		FROM watch_history
+		WHERE user_id = $1
+		ORDER BY movie_id, watched_at DESC, id DESC
+	)
+	SELECT count(*) OVER(), latest.movie_id, movies.title, latest.watched_at, latest.progress, latest.views
+	FROM latest
+	INNER JOIN movies ON movies.id = latest.movie_id
+	ORDER BY latest.watched_at DESC, latest.movie_id ASC
+	LIMIT $2 OFFSET $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	entries := []*HistoryEntry{}
+
+	for rows.Next() {
+		var e HistoryEntry
+
+		err := rows.Scan(&totalRecords, &e.MovieID, &e.Title, &e.WatchedAt, &e.Progress, &e.Views)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		entries = append(entries, &e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return entries, metadata, nil
+}
+
+// GetAllForUser returns every watch the user has recorded, oldest first.
+func (m *HistoryModel) GetAllForUser(userID int64) ([]*Watch, error) {
+	query := `
+	SELECT id, user_id, movie_id, watched_at, progress
+	FROM watch_history
+	WHERE user_id = $1
+	ORDER BY watched_at, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	watches := []*Watch{}
+
+	for rows.Next() {
+		var w Watch
+
+		err := rows.Scan(&w.ID, &w.UserID, &w.MovieID, &w.WatchedAt, &w.Progress)
+		if err != nil {
+			return nil, err
+		}
+
+		watches = append(watches, &w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return watches, nil
+}
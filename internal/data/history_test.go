@@ -0,0 +1,41 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+func TestValidateWatch(t *testing.T) {
+	progress := func(n int32) *int32 { return &n }
+
+	tests := []struct {
+		name    string
+		watch   Watch
+		runtime Runtime
+		invalid string
+	}{
+		{"no progress", Watch{WatchedAt: time.Now()}, 107, ""},
+		{"part way", Watch{WatchedAt: time.Now(), Progress: progress(600)}, 107, ""},
+		{"at the end", Watch{WatchedAt: time.Now(), Progress: progress(107 * 60)}, 107, ""},
+		{"unknown runtime", Watch{WatchedAt: time.Now(), Progress: progress(100000)}, 0, ""},
+		{"past the end", Watch{WatchedAt: time.Now(), Progress: progress(107*60 + 1)}, 107, "progress"},
+		{"negative", Watch{WatchedAt: time.Now(), Progress: progress(-1)}, 107, "progress"},
+		{"future", Watch{WatchedAt: time.Now().Add(time.Hour)}, 107, "watched_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateWatch(v, &tt.watch, tt.runtime)
+
+			if tt.invalid == "" && !v.Valid() {
+				t.Errorf("got errors %v; want none", v.Errors)
+			}
+			if _, ok := v.Errors[tt.invalid]; tt.invalid != "" && !ok {
+				t.Errorf("got errors %v; want one for %q", v.Errors, tt.invalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+var ErrDuplicateIPRule = errors.New("duplicate ip rule")
+
+// The lists an IP rule can belong to. Addresses on the admin allowlist are the
+// only ones that may use admin routes (once the list is not empty); addresses
+// on the denylist are refused on every route.
+const (
+	IPListAdminAllow = "admin_allow"
+	IPListDeny       = "deny"
+)
+
+type IPRule struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	CIDR      string    `json:"cidr"`
+	List      string    `json:"list"`
+	Note      string    `json:"note"`
+}
+
+// ParsePrefix parses a CIDR block or a single address, which is treated as a
+// block containing only that address.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(s)
+	if err == nil {
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+}
+
+func ValidateIPRule(v *validator.Validator, rule *IPRule) {
+	_, err := ParsePrefix(rule.CIDR)
+	v.Check(err == nil, "cidr", "must be an IP address or CIDR block")
+	v.Check(validator.In(rule.List, IPListAdminAllow, IPListDeny), "list", "must be one of admin_allow or deny")
+	v.Check(len(rule.Note) <= 500, "note", "must not be more than 500 bytes long")
+}
+
+type IPRuleModel struct {
+	DB *DB
+}
+
+func (m *IPRuleModel) Insert(rule *IPRule) error {
+	prefix, err := ParsePrefix(rule.CIDR)
+	if err != nil {
+		return err
+	}
+	rule.CIDR = prefix.String()
+
+	query := `INSERT INTO ip_rules (cidr, list, note)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, rule.CIDR, rule.List, rule.Note).Scan(&rule.ID, &rule.CreatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "ip_rules_cidr_list_key"`:
+			return ErrDuplicateIPRule
+		default:
+			return err
+		}
+	}
+
+	m.DB.notify(Change{Entity: EntityIPRule, Action: ChangeCreated, ID: rule.ID})
+	return nil
+}
+
+func (m *IPRuleModel) GetAll() ([]*IPRule, error) {
+	query := `SELECT id, created_at, cidr, list, note
+	FROM ip_rules
+	ORDER BY list, cidr`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []*IPRule{}
+
+	for rows.Next() {
+		var rule IPRule
+
+		err := rows.Scan(&rule.ID, &rule.CreatedAt, &rule.CIDR, &rule.List, &rule.Note)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, &rule)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (m *IPRuleModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `DELETE FROM ip_rules
+	WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	m.DB.notify(Change{Entity: EntityIPRule, Action: ChangeDeleted, ID: id})
+	return nil
+}
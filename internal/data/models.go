@@ -1,7 +1,6 @@
 package data
 
 import (
-	"database/sql"
 	"errors"
 )
 
@@ -11,15 +10,47 @@ var (
 )
 
 type Models struct {
-	Movies MovieModel
-	Users  UserModel
-	Tokens TokenModel
+	Erasures      ErasureModel
+	Exports       ExportModel
+	History       HistoryModel
+	IPRules       IPRuleModel
+	Movies        MovieModel
+	Notifications NotificationModel
+	Operations    OperationModel
+	Partners      PartnerModel
+	Permissions   PermissionModel
+	Providers     ProviderModel
+	Retention     RetentionModel
+	SavedSearches SavedSearchModel
+	Series        SeriesModel
+	Sync          SyncModel
+	Users         UserModel
+	Tokens        TokenModel
+	Usage         UsageModel
+	WriteFreezes  WriteFreezeModel
 }
 
-func NewModels(db *sql.DB) Models {
+// NewModels returns the models backed by db. Sensitive columns are encrypted
+// with keys, which may be nil, and movies are held to rules.
+func NewModels(db *DB, keys *Keyring, rules MovieRules) Models {
 	return Models{
-		Movies: MovieModel{DB: db},
-		Users:  UserModel{DB: db},
-		Tokens: TokenModel{DB: db},
+		Erasures:      ErasureModel{DB: db},
+		Exports:       ExportModel{DB: db},
+		History:       HistoryModel{DB: db},
+		IPRules:       IPRuleModel{DB: db},
+		Movies:        MovieModel{DB: db, Rules: rules},
+		Notifications: NotificationModel{DB: db},
+		Operations:    OperationModel{DB: db},
+		Partners:      PartnerModel{DB: db, Keys: keys},
+		Permissions:   PermissionModel{DB: db},
+		Providers:     ProviderModel{DB: db},
+		Retention:     RetentionModel{DB: db},
+		SavedSearches: SavedSearchModel{DB: db},
+		Series:        SeriesModel{DB: db},
+		Sync:          SyncModel{DB: db},
+		Users:         UserModel{DB: db, Keys: keys},
+		Tokens:        TokenModel{DB: db},
+		Usage:         UsageModel{DB: db},
+		WriteFreezes:  WriteFreezeModel{DB: db},
 	}
 }
@@ -0,0 +1,191 @@
+package data
+
+import (
+	"container/list"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MovieCache holds recently read movies in memory for MovieModel.Get, evicting
+// the least recently used once it is full and reloading entries older than
+// its ttl. Concurrent misses for the same movie share a single query.
+//
+// The cache is emptied of a movie as soon as this instance changes it, and of
+// every movie after a bulk change. Changes made by other instances only reach
+// it through the change notifications passed to DB.Forget, so without those
+// entries can be up to ttl out of date.
+type MovieCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[int64]*list.Element
+	calls   map[int64]*movieCall
+	// gen counts invalidations, so that a load started before one is not
+	// cached after it.
+	gen    uint64
+	hits   int64
+	misses int64
+}
+
+type movieCacheEntry struct {
+	movie   *Movie
+	expires time.Time
+}
+
+// movieCall is a load of a movie that concurrent misses wait on.
+type movieCall struct {
+	done  chan struct{}
+	movie *Movie
+	err   error
+}
+
+// MovieCacheStats is a snapshot of the cache's size and effectiveness.
+type MovieCacheStats struct {
+	Entries int   `json:"entries"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+}
+
+func NewMovieCache(size int, ttl time.Duration) *MovieCache {
+	return &MovieCache{
+		size:    size,
+		ttl:     ttl,
+		lru:     list.New(),
+		entries: make(map[int64]*list.Element),
+		calls:   make(map[int64]*movieCall),
+	}
+}
+
+// get returns the movie with the given id, calling load on a miss. Each caller
+// gets its own copy, which it is free to change.
+func (c *MovieCache) get(id int64, load func() (*Movie, error)) (*Movie, error) {
+	if c == nil {
+		return load()
+	}
+
+	c.mu.Lock()
+
+	if el, ok := c.entries[id]; ok {
+		entry := el.Value.(*movieCacheEntry)
+		if time.Now().Before(entry.expires) {
+			c.lru.MoveToFront(el)
+			c.hits++
+			c.mu.Unlock()
+			return entry.movie.clone(), nil
+		}
+		c.remove(el)
+	}
+
+	c.misses++
+
+	if call, ok := c.calls[id]; ok {
+		c.mu.Unlock()
+		<-call.done
+		if call.err != nil {
+			return nil, call.err
+		}
+		return call.movie.clone(), nil
+	}
+
+	call := &movieCall{done: make(chan struct{})}
+	c.calls[id] = call
+	gen := c.gen
+
+	c.mu.Unlock()
+
+	call.movie, call.err = load()
+	close(call.done)
+
+	c.mu.Lock()
+	if c.calls[id] == call {
+		delete(c.calls, id)
+	}
+	// Errors, including ErrRecordNotFound, are not cached.
+	if call.err == nil && gen == c.gen {
+		c.put(id, call.movie)
+	}
+	c.mu.Unlock()
+
+	if call.err != nil {
+		return nil, call.err
+	}
+	return call.movie.clone(), nil
+}
+
+func (c *MovieCache) put(id int64, movie *Movie) {
+	entry := &movieCacheEntry{movie: movie, expires: time.Now().Add(c.ttl)}
+
+	if el, ok := c.entries[id]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.entries[id] = c.lru.PushFront(entry)
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *MovieCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*movieCacheEntry).movie.ID)
+}
+
+// Forget drops the movies made stale by a change: the one changed, or all of
+// them for a bulk change. Loads in progress are not cached when they finish.
+func (c *MovieCache) Forget(change Change) {
+	if c == nil || change.Entity != EntityMovie {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+
+	if change.ID == 0 {
+		c.lru.Init()
+		clear(c.entries)
+		clear(c.calls)
+		return
+	}
+
+	if el, ok := c.entries[change.ID]; ok {
+		c.remove(el)
+	}
+	// Later misses start a fresh load rather than waiting on one that may
+	// have read the old row.
+	delete(c.calls, change.ID)
+}
+
+func (c *MovieCache) Stats() MovieCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return MovieCacheStats{Entries: c.lru.Len(), Hits: c.hits, Misses: c.misses}
+}
+
+// clone returns a deep copy of the movie.
+func (movie *Movie) clone() *Movie {
+	c := *movie
+
+	c.Genres = slices.Clone(movie.Genres)
+	c.AllowedRegions = slices.Clone(movie.AllowedRegions)
+	c.BlockedRegions = slices.Clone(movie.BlockedRegions)
+
+	if movie.ReleaseDate != nil {
+		d := *movie.ReleaseDate
+		c.ReleaseDate = &d
+	}
+	if movie.SeriesID != nil {
+		id := *movie.SeriesID
+		c.SeriesID = &id
+	}
+
+	return &c
+}
@@ -0,0 +1,114 @@
+package data
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMovieCache(t *testing.T) {
+	c := NewMovieCache(2, time.Hour)
+
+	loads := 0
+	load := func(id int64) func() (*Movie, error) {
+		return func() (*Movie, error) {
+			loads++
+			return &Movie{ID: id, Title: "Moana", Genres: []string{"animation"}}, nil
+		}
+	}
+
+	movie, _ := c.get(1, load(1))
+	movie.Genres[0] = "changed"
+
+	movie, _ = c.get(1, load(1))
+	if loads != 1 {
+		t.Errorf("got %d loads for a cached movie; want 1", loads)
+	}
+	if movie.Genres[0] != "animation" {
+		t.Error("a caller's change to its copy reached the cache")
+	}
+
+	// Reading 2 and 3 evicts 1, the least recently used.
+	c.get(2, load(2))
+	c.get(3, load(3))
+	c.get(1, load(1))
+	if loads != 4 {
+		t.Errorf("got %d loads; want the evicted movie reloaded", loads)
+	}
+
+	c.Forget(Change{Entity: EntityMovie, Action: ChangeUpdated, ID: 1})
+	c.get(1, load(1))
+	if loads != 5 {
+		t.Errorf("got %d loads; want the changed movie reloaded", loads)
+	}
+
+	c.Forget(Change{Entity: EntityMovie, Action: ChangeBulkUpdated, Count: 2})
+	if stats := c.Stats(); stats.Entries != 0 {
+		t.Errorf("got %d entries after a bulk change; want 0", stats.Entries)
+	}
+
+	expiring := NewMovieCache(10, -time.Second)
+	expiring.get(1, load(1))
+	expiring.get(1, load(1))
+	if loads != 7 {
+		t.Errorf("got %d loads; want expired entries reloaded", loads)
+	}
+
+	var nilCache *MovieCache
+	if movie, err := nilCache.get(1, load(1)); err != nil || movie.ID != 1 {
+		t.Errorf("nil cache: got %+v, %v", movie, err)
+	}
+}
+
+func TestMovieCacheCollapsesMisses(t *testing.T) {
+	c := NewMovieCache(10, time.Hour)
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+
+	load := func() (*Movie, error) {
+		loads.Add(1)
+		<-release
+		return &Movie{ID: 1}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if movie, err := c.get(1, load); err != nil || movie.ID != 1 {
+				t.Errorf("got %+v, %v", movie, err)
+			}
+		}()
+	}
+
+	// Wait for every reader to miss before letting the load finish.
+	for c.Stats().Misses < 10 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Errorf("got %d loads for concurrent misses; want 1", n)
+	}
+}
+
+func TestMovieCacheDropsLoadsRacingAChange(t *testing.T) {
+	c := NewMovieCache(10, time.Hour)
+
+	c.get(1, func() (*Movie, error) {
+		// The movie changes while the old row is being read.
+		c.Forget(Change{Entity: EntityMovie, Action: ChangeUpdated, ID: 1})
+		return &Movie{ID: 1, Title: "Old"}, nil
+	})
+
+	movie, _ := c.get(1, func() (*Movie, error) {
+		return &Movie{ID: 1, Title: "New"}, nil
+	})
+	if movie.Title != "New" {
+		t.Errorf("got %q; want the load that raced a change left uncached", movie.Title)
+	}
+}
@@ -3,80 +3,259 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/levisthors/greenlight/internal/validator"
 	"github.com/lib/pq"
 )
 
+// Movie budgets and box office takings are whole US dollars, with zero
+// meaning unknown.
 type Movie struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"-"`
-	Title     string    `json:"title"`
-	Year      int32     `json:"year,omitempty"`
-	Runtime   int32     `json:"runtime,omitempty"`
-	Genres    []string  `json:"genres,omitempty"`
-	Version   int32     `json:"version"`
+	ID               int64     `json:"id"`
+	CreatedAt        time.Time `json:"-"`
+	UpdatedAt        time.Time `json:"-"`
+	Title            string    `json:"title"`
+	Year             int32     `json:"year,omitempty"`
+	ReleaseDate      *Date     `json:"release_date,omitempty"`
+	Runtime          Runtime   `json:"runtime,omitempty"`
+	Genres           []string  `json:"genres,omitempty"`
+	Synopsis         string    `json:"synopsis,omitempty"`
+	OriginalLanguage string    `json:"original_language,omitempty"`
+	Country          string    `json:"country,omitempty"`
+	Budget           int64     `json:"budget,omitempty"`
+	BoxOffice        int64     `json:"box_office,omitempty"`
+	Certification    string    `json:"certification,omitempty"`
+	SeriesID         *int64    `json:"series_id,omitempty"`
+	SeriesOrder      int32     `json:"series_order,omitempty"`
+	AllowedRegions   []string  `json:"allowed_regions,omitempty"`
+	BlockedRegions   []string  `json:"blocked_regions,omitempty"`
+	Version          int32     `json:"version"`
 }
 
-func ValidateMovie(v *validator.Validator, movie *Movie) {
+// Certifications are the MPA film ratings, plus NR for unrated movies.
+var Certifications = []string{"G", "PG", "PG-13", "R", "NC-17", "NR"}
+
+var (
+	LanguageRX = regexp.MustCompile("^[a-z]{2}$")
+	CountryRX  = regexp.MustCompile("^[A-Z]{2}$")
+)
+
+// MovieRules are the configurable limits that movies are validated against.
+type MovieRules struct {
+	// MinGenres and MaxGenres bound how many genres a movie may have.
+	MinGenres int
+	MaxGenres int
+	// ReleaseDateHorizon is how far into the future a release date may be
+	// set, so that announced movies can be added ahead of their release.
+	ReleaseDateHorizon time.Duration
+}
+
+// DefaultMovieRules returns the limits used unless a server is configured
+// otherwise.
+func DefaultMovieRules() MovieRules {
+	return MovieRules{
+		MinGenres:          1,
+		MaxGenres:          5,
+		ReleaseDateHorizon: 2 * 365 * 24 * time.Hour,
+	}
+}
+
+// GenreRX matches genre names such as "sci-fi" or "rock & roll": letters and
+// digits, with spaces, hyphens, apostrophes and ampersands between them.
+var GenreRX = regexp.MustCompile(`^[\p{L}\p{N}](?:[\p{L}\p{N} &'-]*[\p{L}\p{N}])?$`)
+
+func ValidGenre(genre string) bool {
+	return validator.RuneCountBetween(genre, 1, 50) && validator.Matches(genre, GenreRX)
+}
+
+func ValidateMovie(v *validator.Validator, movie *Movie, rules MovieRules) {
 	v.Check(movie.Title != "", "title", "must be provided")
 	v.Check(len(movie.Title) <= 500, "title", "must be less than 500 characters long")
 
+	// Movies with a release date may be announced up to the release date
+	// horizon ahead, so their year can be later than the current one.
+	maxYear := time.Now().Year()
+	if movie.ReleaseDate != nil {
+		maxYear = time.Now().Add(rules.ReleaseDateHorizon).Year()
+	}
+
 	v.Check(movie.Year != 0, "year", "must be provided")
-	v.Check(movie.Year > 1888 && movie.Year < int32(time.Now().Year()), "year", "year must be in 1888 - current year range")
+	v.Check(movie.Year > 1888 && movie.Year <= int32(maxYear), "year", "year must be in 1888 - current year range")
+
+	if movie.ReleaseDate != nil {
+		v.Check(movie.ReleaseDate.Year() > 1888, "release_date", "must be after 1888")
+		v.Check(!movie.ReleaseDate.After(time.Now().Add(rules.ReleaseDateHorizon)), "release_date", "must not be that far in the future")
+		v.Check(movie.Year == int32(movie.ReleaseDate.Year()), "year", "must match the release date")
+	}
 
-	v.Check(movie.Runtime != 0, "runtime", "must be provided")
-	v.Check(movie.Runtime > 0, "runtime", "must be a positive integer")
+	movie.Runtime.Validate(v)
 
 	v.Check(movie.Genres != nil, "genres", "must be provided")
-	v.Check(len(movie.Genres) > 1 && len(movie.Genres) < 5, "genres", "genres must contain at least 1 and maximum 5 genres")
+	v.Check(len(movie.Genres) >= rules.MinGenres && len(movie.Genres) <= rules.MaxGenres, "genres", fmt.Sprintf("must contain at least %d and at most %d genres", rules.MinGenres, rules.MaxGenres))
 	v.Check(validator.Unique(movie.Genres), "genres", "genres must be unique")
+	v.Check(validator.All(movie.Genres, ValidGenre), "genres", "each genre must be 1 to 50 letters, digits, spaces, hyphens, apostrophes or ampersands")
+
+	v.Check(len(movie.Synopsis) <= 5000, "synopsis", "must not be more than 5000 bytes long")
+	v.Check(movie.OriginalLanguage == "" || validator.Matches(movie.OriginalLanguage, LanguageRX), "original_language", "must be a two-letter ISO 639-1 code")
+	v.Check(movie.Country == "" || validator.Matches(movie.Country, CountryRX), "country", "must be a two-letter ISO 3166-1 code")
+	v.Check(movie.Budget >= 0, "budget", "must not be negative")
+	v.Check(movie.BoxOffice >= 0, "box_office", "must not be negative")
+	v.Check(movie.Certification == "" || validator.In(movie.Certification, Certifications...), "certification", fmt.Sprintf("must be one of %v", Certifications))
+
+	if movie.SeriesID != nil {
+		v.Check(movie.SeriesOrder > 0, "series_order", "must be a positive integer when the movie is part of a series")
+	}
+}
+
+// MovieQuery holds the search criteria for MovieModel.GetAll. Zero values
+// match every movie.
+type MovieQuery struct {
+	Title            string   `json:"title,omitempty"`
+	Genres           []string `json:"genres,omitempty"`
+	ReleasedAfter    *Date    `json:"released_after,omitempty"`
+	ReleasedBefore   *Date    `json:"released_before,omitempty"`
+	OriginalLanguage string   `json:"original_language,omitempty"`
+	Country          string   `json:"country,omitempty"`
+	Certification    string   `json:"certification,omitempty"`
+}
+
+func ValidateMovieQuery(v *validator.Validator, q MovieQuery) {
+	v.Check(q.OriginalLanguage == "" || validator.Matches(q.OriginalLanguage, LanguageRX), "original_language", "must be a two-letter ISO 639-1 code")
+	v.Check(q.Country == "" || validator.Matches(q.Country, CountryRX), "country", "must be a two-letter ISO 3166-1 code")
+	v.Check(q.Certification == "" || validator.In(q.Certification, Certifications...), "certification", fmt.Sprintf("must be one of %v", Certifications))
+}
+
+// IsEmpty reports whether q matches every movie.
+func (q MovieQuery) IsEmpty() bool {
+	return q.Title == "" && len(q.Genres) == 0 && q.ReleasedAfter == nil && q.ReleasedBefore == nil &&
+		q.OriginalLanguage == "" && q.Country == "" && q.Certification == ""
+}
+
+// movieQueryWhere is the WHERE condition for a MovieQuery, taking the values
+// returned by its args method as $1 to $7.
+const movieQueryWhere = `(to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		AND (genres @> $2 OR $2 = '{}')
+		AND (release_date >= $3 OR $3 IS NULL)
+		AND (release_date <= $4 OR $4 IS NULL)
+		AND (original_language = $5 OR $5 = '')
+		AND (country = $6 OR $6 = '')
+		AND (certification = $7 OR $7 = '')`
+
+func (q MovieQuery) args() []interface{} {
+	// A nil slice is sent as NULL, which would match no movies.
+	genres := q.Genres
+	if genres == nil {
+		genres = []string{}
+	}
+
+	return []interface{}{
+		q.Title,
+		pq.Array(genres),
+		q.ReleasedAfter,
+		q.ReleasedBefore,
+		q.OriginalLanguage,
+		q.Country,
+		q.Certification,
+	}
+}
+
+const movieColumns = `id, created_at, updated_at, title, year, release_date, runtime, genres,
+	synopsis, original_language, country, budget, box_office, certification, series_id, series_order,
+	allowed_regions, blocked_regions, version`
+
+// scanDest returns the scan destinations for the columns in movieColumns.
+func (movie *Movie) scanDest() []interface{} {
+	return []interface{}{
+		&movie.ID,
+		&movie.CreatedAt,
+		&movie.UpdatedAt,
+		&movie.Title,
+		&movie.Year,
+		&movie.ReleaseDate,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.Synopsis,
+		&movie.OriginalLanguage,
+		&movie.Country,
+		&movie.Budget,
+		&movie.BoxOffice,
+		&movie.Certification,
+		&movie.SeriesID,
+		&movie.SeriesOrder,
+		pq.Array(&movie.AllowedRegions),
+		pq.Array(&movie.BlockedRegions),
+		&movie.Version,
+	}
 }
 
 type MovieModel struct {
-	DB *sql.DB
+	DB *DB
+	// Rules are the limits that bulk updates must keep movies within.
+	Rules MovieRules
 }
 
 func (m *MovieModel) Insert(movie *Movie) error {
-	query := `INSERT INTO movies (title, year, runtime, genres)
-	VALUES ($1, $2, $3, $4)
-	RETURNING id, created_at, version`
+	query := `INSERT INTO movies (title, year, release_date, runtime, genres, synopsis, original_language, country, budget, box_office, certification, series_id, series_order)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	RETURNING id, created_at, updated_at, version`
 
-	args := []interface{}{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
+	args := []interface{}{
+		movie.Title,
+		movie.Year,
+		movie.ReleaseDate,
+		movie.Runtime,
+		pq.Array(movie.Genres),
+		movie.Synopsis,
+		movie.OriginalLanguage,
+		movie.Country,
+		movie.Budget,
+		movie.BoxOffice,
+		movie.Certification,
+		movie.SeriesID,
+		movie.SeriesOrder,
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.UpdatedAt, &movie.Version)
+	if err != nil {
+		return err
+	}
+
+	m.DB.notify(Change{Entity: EntityMovie, Action: ChangeCreated, ID: movie.ID})
+	return nil
 }
 
+// Get returns the movie with the given id, from the DB's movie cache if it has
+// one.
 func (m *MovieModel) Get(id int64) (*Movie, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
+	return m.DB.movies.get(id, func() (*Movie, error) {
+		return m.get(id)
+	})
+}
+
+func (m *MovieModel) get(id int64) (*Movie, error) {
 	var movie Movie
 
-	query := `SELECT id, created_at, title, year, runtime, genres, version
-	FROM movies	
-	WHERE id=$1`
+	query := `SELECT ` + movieColumns + `
+	FROM movies
+	WHERE id = $1`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
-		&movie.ID,
-		&movie.CreatedAt,
-		&movie.Title,
-		&movie.Year,
-		&movie.Runtime,
-		pq.Array(&movie.Genres),
-		&movie.Version,
-	)
-
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(movie.scanDest()...)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -91,15 +270,26 @@ func (m *MovieModel) Get(id int64) (*Movie, error) {
 
 func (m *MovieModel) Update(movie *Movie) error {
 	query := `UPDATE movies
-	SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-	WHERE id = $5 AND version = $6
-	RETURNING version`
+	SET title = $1, year = $2, release_date = $3, runtime = $4, genres = $5,
+		synopsis = $6, original_language = $7, country = $8, budget = $9, box_office = $10, certification = $11,
+		series_id = $12, series_order = $13, updated_at = NOW(), version = version + 1
+	WHERE id = $14 AND version = $15
+	RETURNING updated_at, version`
 
 	args := []interface{}{
 		movie.Title,
 		movie.Year,
+		movie.ReleaseDate,
 		movie.Runtime,
 		pq.Array(movie.Genres),
+		movie.Synopsis,
+		movie.OriginalLanguage,
+		movie.Country,
+		movie.Budget,
+		movie.BoxOffice,
+		movie.Certification,
+		movie.SeriesID,
+		movie.SeriesOrder,
 		movie.ID,
 		movie.Version,
 	}
@@ -107,60 +297,99 @@ func (m *MovieModel) Update(movie *Movie) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	err := updateVersioned(ctx, m.DB, "movies", movie.ID, query, args, &movie.UpdatedAt, &movie.Version)
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrEditConflict
-		default:
-			return err
-		}
+		return err
 	}
 
+	m.DB.notify(Change{Entity: EntityMovie, Action: ChangeUpdated, ID: movie.ID})
 	return nil
 }
 
-func (m *MovieModel) Delete(id int64) error {
+// MovieDeletion counts the rows deleting a movie removes along with it.
+type MovieDeletion struct {
+	Availability  int64 `json:"availability"`
+	WatchHistory  int64 `json:"watch_history"`
+	Notifications int64 `json:"notifications"`
+}
+
+// Delete deletes the movie and returns what went with it. With dryRun set the
+// delete is rolled back, so nothing changes but the counts are the same.
+func (m *MovieModel) Delete(id int64, dryRun bool) (*MovieDeletion, error) {
 	if id < 1 {
-		return ErrRecordNotFound
+		return nil, ErrRecordNotFound
 	}
 
-	query := `DELETE FROM movies 
-	WHERE id = $1`
-
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The rows are counted before the delete cascades to them.
+	query := `SELECT
+		(SELECT count(*) FROM movie_availability WHERE movie_id = $1),
+		(SELECT count(*) FROM watch_history WHERE movie_id = $1),
+		(SELECT count(*) FROM notifications WHERE movie_id = $1)`
+
+	var deletion MovieDeletion
+
+	err = tx.QueryRowContext(ctx, query, id).Scan(&deletion.Availability, &deletion.WatchHistory, &deletion.Notifications)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM movies WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if rowsAffected == 0 {
-		return ErrRecordNotFound
+		return nil, ErrRecordNotFound
 	}
 
-	return nil
+	if dryRun {
+		return &deletion, nil
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	m.DB.notify(Change{Entity: EntityMovie, Action: ChangeDeleted, ID: id})
+	return &deletion, nil
 }
 
-func (m *MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
+// GetAll returns a page of the movies matching q that are available in
+// region.
+func (m *MovieModel) GetAll(q MovieQuery, region string, filters Filters) ([]*Movie, Metadata, error) {
+	// Only an exact count needs the window function, which has to visit
+	// every matching row.
+	countColumn := "0"
+	if filters.countMode() == CountExact {
+		countColumn = "count(*) OVER()"
+	}
+
 	query := fmt.Sprintf(`
-		SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version 
-		FROM movies 
-		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-		AND (genres @> $2 OR $2 = '{}')
-		ORDER BY %s %s, id ASC
-		LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+		SELECT %s, %s
+		FROM movies
+		WHERE %s AND %s
+		ORDER BY %s %s NULLS LAST, id ASC
+		LIMIT $8 OFFSET $9`, countColumn, movieColumns, movieQueryWhere, regionWhere(10), filters.sortColumn(), filters.sortDirection())
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []interface{}{title, pq.Array(genres), filters.limit(), filters.offset()}
+	args := append(q.args(), filters.limit(), filters.offset(), region)
 
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -174,17 +403,129 @@ func (m *MovieModel) GetAll(title string, genres []string, filters Filters) ([]*
 	for rows.Next() {
 		var movie Movie
 
-		err := rows.Scan(
-			&totalRecords,
-			&movie.ID,
-			&movie.CreatedAt,
-			&movie.Title,
-			&movie.Year,
-			&movie.Runtime,
-			pq.Array(&movie.Genres),
-			&movie.Version,
+		err := rows.Scan(append([]interface{}{&totalRecords}, movie.scanDest()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	switch filters.countMode() {
+	case CountEstimated:
+		totalRecords, err = m.estimateCount(ctx, q)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		// The estimate can be behind; never report fewer records than the
+		// pages up to this one hold.
+		if seen := filters.offset() + len(movies); totalRecords < seen {
+			totalRecords = seen
+		}
+	case CountNone:
+		return movies, Metadata{CurrentPage: filters.Page, PageSize: filters.PageSize, FirstPage: 1}, nil
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	metadata.TotalRecordsEstimated = filters.countMode() == CountEstimated && totalRecords > 0
+
+	return movies, metadata, nil
+}
+
+// estimateCount returns an estimate of how many movies match q. Without
+// criteria it reads the table's row estimate from pg_class; otherwise it asks
+// the planner how many rows the query would return.
+func (m *MovieModel) estimateCount(ctx context.Context, q MovieQuery) (int, error) {
+	if q.IsEmpty() {
+		var reltuples float64
+
+		err := m.DB.QueryRowContext(ctx, `SELECT reltuples FROM pg_class WHERE oid = 'movies'::regclass`).Scan(&reltuples)
+		if err != nil {
+			return 0, err
+		}
+
+		// reltuples is -1 until the table has been vacuumed or analyzed.
+		if reltuples >= 0 {
+			return int(reltuples), nil
+		}
+	}
+
+	var plan []byte
+
+	query := fmt.Sprintf(`EXPLAIN (FORMAT JSON) SELECT 1 FROM movies WHERE %s`, movieQueryWhere)
+
+	err := m.DB.QueryRowContext(ctx, query, q.args()...).Scan(&plan)
+	if err != nil {
+		return 0, err
+	}
+
+	var explained []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+
+	err = json.Unmarshal(plan, &explained)
+	if err != nil {
+		return 0, err
+	}
+	if len(explained) == 0 {
+		return 0, errors.New("empty query plan")
+	}
+
+	return int(explained[0].Plan.Rows), nil
+}
+
+// GetRelated returns movies similar to the given one, most similar first.
+// Movies score a point for each genre they share with it and two more for
+// being in the same series; movies scoring nothing are left out. When
+// excludeWatchedBy is non-zero, movies in that user's watch history are left
+// out too, as are movies not available in region.
+func (m *MovieModel) GetRelated(id, excludeWatchedBy int64, region string, filters Filters) ([]*Movie, Metadata, error) {
+	query := `
+		WITH target AS (
+			SELECT id AS target_id, genres AS target_genres, series_id AS target_series_id
+			FROM movies
+			WHERE id = $1
+		), scored AS (
+			SELECT movies.*,
+				cardinality(ARRAY(SELECT unnest(genres) INTERSECT SELECT unnest(target_genres))) +
+				CASE WHEN series_id = target_series_id THEN 2 ELSE 0 END AS score
+			FROM movies, target
+			WHERE id <> target_id
+			AND (genres && target_genres OR series_id = target_series_id)
+			AND NOT EXISTS (
+				SELECT 1 FROM watch_history
+				WHERE watch_history.user_id = $4 AND watch_history.movie_id = movies.id
+			)
+			AND ` + regionWhere(5) + `
 		)
+		SELECT count(*) OVER(), ` + movieColumns + `
+		FROM scored
+		ORDER BY score DESC, id ASC
+		LIMIT $2 OFFSET $3`
 
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id, filters.limit(), filters.offset(), excludeWatchedBy, region)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(append([]interface{}{&totalRecords}, movie.scanDest()...)...)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
@@ -200,3 +541,227 @@ func (m *MovieModel) GetAll(title string, genres []string, filters Filters) ([]*
 
 	return movies, metadata, nil
 }
+
+// GetRecent returns the most recently added movies available in region,
+// newest first.
+func (m *MovieModel) GetRecent(region string, limit int) ([]*Movie, error) {
+	query := `SELECT ` + movieColumns + `
+	FROM movies
+	WHERE ` + regionWhere(2) + `
+	ORDER BY created_at DESC, id DESC
+	LIMIT $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit, region)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(movie.scanDest()...)
+		if err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// MovieStamp is a movie's ID and when it last changed.
+type MovieStamp struct {
+	ID        int64
+	UpdatedAt time.Time
+}
+
+// GetStamps returns the IDs and modification times of the most recently
+// updated movies available in region, most recent first.
+func (m *MovieModel) GetStamps(region string, limit int) ([]MovieStamp, error) {
+	query := `
+	SELECT id, updated_at
+	FROM movies
+	WHERE ` + regionWhere(2) + `
+	ORDER BY updated_at DESC, id DESC
+	LIMIT $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit, region)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stamps := []MovieStamp{}
+
+	for rows.Next() {
+		var s MovieStamp
+
+		err := rows.Scan(&s.ID, &s.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		stamps = append(stamps, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stamps, nil
+}
+
+var ErrInvalidBulkUpdate = errors.New("invalid bulk update")
+
+// MovieChanges are the edits UpdateAll applies to every matching movie. Zero
+// values leave the corresponding field alone.
+type MovieChanges struct {
+	RenameGenreFrom  string
+	RenameGenreTo    string
+	AddGenre         string
+	RemoveGenre      string
+	RuntimeDelta     int32
+	OriginalLanguage string
+	Country          string
+	Certification    string
+}
+
+func (c MovieChanges) IsEmpty() bool {
+	return c == MovieChanges{}
+}
+
+func ValidateMovieChanges(v *validator.Validator, c MovieChanges) {
+	v.Check(!c.IsEmpty(), "changes", "must contain at least one change")
+	v.Check((c.RenameGenreFrom == "") == (c.RenameGenreTo == ""), "rename_genre", "must have both from and to")
+	v.Check(c.RenameGenreFrom == "" || c.RenameGenreFrom != c.RenameGenreTo, "rename_genre", "from and to must be different")
+	v.Check(c.AddGenre == "" || c.AddGenre != c.RemoveGenre, "add_genre", "must not be the genre being removed")
+	v.Check(c.RenameGenreTo == "" || ValidGenre(c.RenameGenreTo), "rename_genre", "to must be a valid genre name")
+	v.Check(c.AddGenre == "" || ValidGenre(c.AddGenre), "add_genre", "must be a valid genre name")
+	v.Check(c.OriginalLanguage == "" || validator.Matches(c.OriginalLanguage, LanguageRX), "original_language", "must be a two-letter ISO 639-1 code")
+	v.Check(c.Country == "" || validator.Matches(c.Country, CountryRX), "country", "must be a two-letter ISO 3166-1 code")
+	v.Check(c.Certification == "" || validator.In(c.Certification, Certifications...), "certification", fmt.Sprintf("must be one of %v", Certifications))
+}
+
+// UpdateAll applies changes to every movie matching q in a single statement
+// and returns the number of movies changed. With dryRun set the update is
+// rolled back, so nothing changes but the result, including any error, is
+// the same. If the changes would break a constraint on any movie, such as
+// leaving it with no genres, nothing is changed and ErrInvalidBulkUpdate is
+// returned.
+func (m *MovieModel) UpdateAll(q MovieQuery, c MovieChanges, dryRun bool) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	args := q.args()
+
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	// Each changed column with its new value. Only movies where at least one
+	// of them actually differs are updated, so movies that already match keep
+	// their version and are not counted.
+	var sets, changed []string
+
+	set := func(column, value string) {
+		sets = append(sets, column+" = "+value)
+		changed = append(changed, column+" IS DISTINCT FROM "+value)
+	}
+
+	if c.RenameGenreFrom != "" || c.RemoveGenre != "" || c.AddGenre != "" {
+		genres := "genres"
+		if c.RenameGenreFrom != "" {
+			from, to := arg(c.RenameGenreFrom), arg(c.RenameGenreTo)
+			genres = fmt.Sprintf("(CASE WHEN %[3]s = ANY(%[1]s) THEN array_remove(%[1]s, %[2]s) ELSE array_replace(%[1]s, %[2]s, %[3]s) END)", genres, from, to)
+		}
+		if c.RemoveGenre != "" {
+			genres = fmt.Sprintf("array_remove(%s, %s)", genres, arg(c.RemoveGenre))
+		}
+		if c.AddGenre != "" {
+			genre := arg(c.AddGenre)
+			genres = fmt.Sprintf("(CASE WHEN %[2]s = ANY(%[1]s) THEN %[1]s ELSE array_append(%[1]s, %[2]s) END)", genres, genre)
+		}
+		set("genres", genres)
+	}
+
+	// Unknown runtimes are stored as zero and stay unknown. A known runtime
+	// pushed outside 1 to MaxRuntime becomes -1, which the runtime check
+	// constraint rejects, failing the whole update.
+	if c.RuntimeDelta != 0 {
+		delta := arg(c.RuntimeDelta)
+		set("runtime", fmt.Sprintf("(CASE WHEN runtime = 0 THEN 0 WHEN runtime + %[1]s BETWEEN 1 AND %[2]s THEN runtime + %[1]s ELSE -1 END)", delta, arg(MaxRuntime)))
+	}
+	if c.OriginalLanguage != "" {
+		set("original_language", arg(c.OriginalLanguage))
+	}
+	if c.Country != "" {
+		set("country", arg(c.Country))
+	}
+	if c.Certification != "" {
+		set("certification", arg(c.Certification))
+	}
+
+	// The genre count limits are configurable, so they are checked here
+	// rather than by a constraint, rolling back if any movie breaks them.
+	minGenres, maxGenres := arg(m.Rules.MinGenres), arg(m.Rules.MaxGenres)
+
+	query := fmt.Sprintf(`WITH updated AS (
+		UPDATE movies
+		SET %s, updated_at = NOW(), version = version + 1
+		WHERE %s AND (%s)
+		RETURNING genres
+	)
+	SELECT count(*), count(*) FILTER (WHERE cardinality(genres) NOT BETWEEN %s AND %s)
+	FROM updated`, strings.Join(sets, ", "), movieQueryWhere, strings.Join(changed, " OR "), minGenres, maxGenres)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var affected, invalid int64
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&affected, &invalid)
+	if err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), `pq: new row for relation "movies" violates check constraint`):
+			return 0, ErrInvalidBulkUpdate
+		default:
+			return 0, err
+		}
+	}
+
+	if invalid > 0 {
+		return 0, ErrInvalidBulkUpdate
+	}
+
+	if dryRun {
+		return affected, nil
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	if affected > 0 {
+		m.DB.notify(Change{Entity: EntityMovie, Action: ChangeBulkUpdated, Count: affected})
+	}
+	return affected, nil
+}
@@ -0,0 +1,77 @@
+package data
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+func TestValidateMovieGenres(t *testing.T) {
+	tests := []struct {
+		name   string
+		genres []string
+		valid  bool
+	}{
+		{"one genre", []string{"drama"}, true},
+		{"five genres", []string{"a", "b", "c", "d", "e"}, true},
+		{"no genres", []string{}, false},
+		{"six genres", []string{"a", "b", "c", "d", "e", "f"}, false},
+		{"duplicates", []string{"drama", "drama"}, false},
+		{"punctuation", []string{"sci-fi", "rock & roll", "children's", "film noir"}, true},
+		{"non-ASCII letters", []string{"comédie"}, true},
+		{"markup", []string{"<b>drama</b>"}, false},
+		{"leading space", []string{" drama"}, false},
+		{"empty genre", []string{""}, false},
+		{"too long", []string{strings.Repeat("a", 51)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movie := &Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: tt.genres}
+
+			v := validator.New()
+			ValidateMovie(v, movie, DefaultMovieRules())
+
+			if _, invalid := v.Errors["genres"]; invalid == tt.valid {
+				t.Errorf("genres %q: valid = %t; want %t (%v)", tt.genres, !invalid, tt.valid, v.Errors)
+			}
+		})
+	}
+}
+
+func TestValidateMovieGenreLimits(t *testing.T) {
+	rules := MovieRules{MinGenres: 2, MaxGenres: 6}
+
+	for n, valid := range map[int]bool{1: false, 2: true, 6: true, 7: false} {
+		genres := make([]string, n)
+		for i := range genres {
+			genres[i] = string(rune('a' + i))
+		}
+
+		v := validator.New()
+		ValidateMovie(v, &Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: genres}, rules)
+
+		if _, invalid := v.Errors["genres"]; invalid == valid {
+			t.Errorf("%d genres: valid = %t; want %t", n, !invalid, valid)
+		}
+	}
+}
+
+func TestValidateMovieReleaseDateHorizon(t *testing.T) {
+	rules := DefaultMovieRules()
+	rules.ReleaseDateHorizon = 90 * 24 * time.Hour
+
+	for days, valid := range map[int]bool{30: true, 89: true, 120: false} {
+		releaseDate := Date{time.Now().AddDate(0, 0, days).Truncate(24 * time.Hour)}
+		movie := &Movie{Title: "Moana 3", Year: int32(releaseDate.Year()), ReleaseDate: &releaseDate, Runtime: 107, Genres: []string{"animation"}}
+
+		v := validator.New()
+		ValidateMovie(v, movie, rules)
+
+		if _, invalid := v.Errors["release_date"]; invalid == valid {
+			t.Errorf("release in %d days: valid = %t; want %t (%v)", days, !invalid, valid, v.Errors)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// ChangesChannel is the Postgres channel the models NOTIFY on after changing
+// movies, users, IP rules or write freezes, so that every API instance listening on it can
+// drop state it holds in memory and tell its WebSocket clients.
+const ChangesChannel = "greenlight_changes"
+
+const (
+	EntityMovie       = "movie"
+	EntityUser        = "user"
+	EntityIPRule      = "ip_rule"
+	EntityWriteFreeze = "write_freeze"
+)
+
+const (
+	ChangeCreated     = "created"
+	ChangeUpdated     = "updated"
+	ChangeDeleted     = "deleted"
+	ChangeBulkUpdated = "bulk_updated"
+)
+
+// Change is the payload of a notification on ChangesChannel. ID is set for
+// changes to a single record and Count for bulk changes.
+type Change struct {
+	Entity string `json:"entity"`
+	Action string `json:"action"`
+	ID     int64  `json:"id,omitempty"`
+	Count  int64  `json:"count,omitempty"`
+}
+
+// SetMovieCache makes MovieModel.Get read through c. It must be called before
+// the DB is used.
+func (db *DB) SetMovieCache(c *MovieCache) {
+	db.movies = c
+}
+
+// Forget drops the state the DB holds in memory that change makes stale. The
+// DB forgets its own changes by itself; changes made through other instances
+// are passed in as their notifications arrive.
+func (db *DB) Forget(change Change) {
+	if db == nil {
+		return
+	}
+
+	db.movies.Forget(change)
+}
+
+// notify sends a change notification. A failure is logged rather than
+// returned: the change itself has already been made, and listeners fall back
+// to reloading their state when they reconnect or on their refresh timers.
+func (db *DB) notify(change Change) {
+	db.Forget(change)
+
+	payload, err := json.Marshal(change)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, ChangesChannel, string(payload))
+	if err != nil && db.logger != nil {
+		db.logger.PrintError(err, map[string]string{
+			"channel": ChangesChannel,
+			"entity":  change.Entity,
+			"id":      strconv.FormatInt(change.ID, 10),
+		})
+	}
+}
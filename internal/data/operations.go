@@ -0,0 +1,221 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	OperationPending   = "pending"
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+)
+
+const (
+	OperationExport = "export"
+	OperationImport = "import"
+)
+
+// Operation tracks a long-running task started by a request, so that the
+// request can return 202 Accepted and the client can poll for the outcome.
+// Result holds links and counts once the operation has succeeded.
+type Operation struct {
+	ID        int64                  `json:"id"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+	UserID    int64                  `json:"-"`
+	Kind      string                 `json:"kind"`
+	Status    string                 `json:"status"`
+	Progress  int                    `json:"progress"`
+	Result    map[string]interface{} `json:"result"`
+	Errors    []string               `json:"errors"`
+	// Owner identifies the process running the operation.
+	Owner string `json:"-"`
+}
+
+func (op *Operation) Finished() bool {
+	return op.Status == OperationSucceeded || op.Status == OperationFailed
+}
+
+type OperationModel struct {
+	DB *DB
+}
+
+func (m *OperationModel) Insert(op *Operation) error {
+	query := `INSERT INTO operations (user_id, kind, status, owner)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at, updated_at`
+
+	op.Status = OperationPending
+	op.Result = map[string]interface{}{}
+	op.Errors = []string{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, op.UserID, op.Kind, op.Status, op.Owner).Scan(&op.ID, &op.CreatedAt, &op.UpdatedAt)
+}
+
+// GetForUser returns an operation started by the given user.
+func (m *OperationModel) GetForUser(id, userID int64) (*Operation, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT id, created_at, updated_at, user_id, kind, status, progress, result, errors
+	FROM operations
+	WHERE id = $1 AND user_id = $2`
+
+	var op Operation
+	var result []byte
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id, userID).Scan(
+		&op.ID,
+		&op.CreatedAt,
+		&op.UpdatedAt,
+		&op.UserID,
+		&op.Kind,
+		&op.Status,
+		&op.Progress,
+		&result,
+		pq.Array(&op.Errors),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = json.Unmarshal(result, &op.Result)
+	if err != nil {
+		return nil, err
+	}
+
+	if op.Errors == nil {
+		op.Errors = []string{}
+	}
+
+	return &op, nil
+}
+
+// GetAllForUser returns every operation the user has started, oldest first.
+func (m *OperationModel) GetAllForUser(userID int64) ([]*Operation, error) {
+	query := `SELECT id, created_at, updated_at, user_id, kind, status, progress, result, errors
+	FROM operations
+	WHERE user_id = $1
+	ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ops := []*Operation{}
+
+	for rows.Next() {
+		var op Operation
+		var result []byte
+
+		err := rows.Scan(
+			&op.ID,
+			&op.CreatedAt,
+			&op.UpdatedAt,
+			&op.UserID,
+			&op.Kind,
+			&op.Status,
+			&op.Progress,
+			&result,
+			pq.Array(&op.Errors),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(result, &op.Result)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Errors == nil {
+			op.Errors = []string{}
+		}
+
+		ops = append(ops, &op)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ops, nil
+}
+
+// Update saves the operation's status, progress, result and errors.
+func (m *OperationModel) Update(op *Operation) error {
+	result, err := json.Marshal(op.Result)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE operations
+	SET status = $1, progress = $2, result = $3, errors = $4, updated_at = NOW()
+	WHERE id = $5
+	RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, op.Status, op.Progress, result, pq.Array(op.Errors), op.ID).Scan(&op.UpdatedAt)
+}
+
+// Heartbeat marks the unfinished operations of the given owner as still
+// being worked on.
+func (m *OperationModel) Heartbeat(owner string) error {
+	query := `UPDATE operations
+	SET updated_at = NOW()
+	WHERE owner = $1 AND status IN ('pending', 'running')`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, owner)
+	return err
+}
+
+// FailAbandoned marks pending or running operations as failed once their
+// owner has not touched them for staleAfter. Work is not persisted between
+// restarts, so this stops clients polling operations whose process has gone
+// and that will never finish. Operations of a process still draining after
+// an upgrade, or of other instances, keep their heartbeat fresh and are left
+// alone.
+func (m *OperationModel) FailAbandoned(staleAfter time.Duration) (int64, error) {
+	query := `UPDATE operations
+	SET status = 'failed', errors = array_append(errors, 'interrupted by a server restart'), updated_at = NOW()
+	WHERE status IN ('pending', 'running') AND updated_at < NOW() - make_interval(secs => $1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, staleAfter.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,178 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+// Partner is a trusted server-to-server integration that signs its requests
+// with a shared secret instead of using a bearer token. Signed requests act as
+// the partner's service user, so its permissions apply.
+type Partner struct {
+	ID        int64
+	CreatedAt time.Time
+	Name      string
+	KeyID     string
+	Secret    string
+	UserID    int64
+}
+
+func ValidatePartner(v *validator.Validator, partner *Partner) {
+	v.Check(partner.Name != "", "name", "must be provided")
+	v.Check(len(partner.Name) <= 500, "name", "must not be more than 500 bytes long")
+	v.Check(partner.UserID > 0, "user_id", "must be provided")
+}
+
+type PartnerModel struct {
+	DB *DB
+	// Keys encrypts signing secrets and service users' email addresses.
+	Keys *Keyring
+}
+
+// Insert generates a key ID and secret for the partner and stores it. The
+// secret is a credential, so unlike an email address it is never stored as
+// plaintext: without a keyring Insert returns ErrNoEncryptionKeys.
+func (m *PartnerModel) Insert(partner *Partner) error {
+	if m.Keys == nil {
+		return ErrNoEncryptionKeys
+	}
+
+	keyID := make([]byte, 8)
+	secret := make([]byte, 32)
+
+	for _, b := range [][]byte{keyID, secret} {
+		_, err := rand.Read(b)
+		if err != nil {
+			return err
+		}
+	}
+
+	partner.KeyID = hex.EncodeToString(keyID)
+	partner.Secret = base64.RawURLEncoding.EncodeToString(secret)
+
+	query := `INSERT INTO partners (name, key_id, secret, user_id)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{partner.Name, partner.KeyID, m.Keys.Encrypted(partner.Secret), partner.UserID}
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&partner.ID, &partner.CreatedAt)
+}
+
+// GetForKeyID returns the partner with the given key ID along with its
+// service user.
+func (m *PartnerModel) GetForKeyID(keyID string) (*Partner, *User, error) {
+	query := `
+	SELECT partners.id, partners.created_at, partners.name, partners.key_id, partners.secret, partners.user_id,
+		users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version, users.analytics_consent
+	FROM partners
+	INNER JOIN users
+	ON users.id = partners.user_id
+	WHERE partners.key_id = $1`
+
+	var partner Partner
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, keyID).Scan(
+		&partner.ID,
+		&partner.CreatedAt,
+		&partner.Name,
+		&partner.KeyID,
+		m.Keys.Decrypted(&partner.Secret),
+		&partner.UserID,
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		m.Keys.Decrypted(&user.Email),
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+		&user.AnalyticsConsent,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrRecordNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+
+	return &partner, &user, nil
+}
+
+// RotateSecrets re-encrypts every partner secret that is not encrypted with
+// the current key, returning the number of partners updated. It also encrypts
+// secrets stored before they were always encrypted.
+func (m *PartnerModel) RotateSecrets() (int64, error) {
+	if m.Keys == nil {
+		return 0, ErrNoEncryptionKeys
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT id, secret FROM partners`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	type rotation struct {
+		id     int64
+		secret string
+	}
+
+	var pending []rotation
+
+	for rows.Next() {
+		var (
+			id     int64
+			raw    string
+			secret string
+		)
+
+		err := rows.Scan(&id, &raw)
+		if err != nil {
+			return 0, err
+		}
+
+		if !m.Keys.needsRotation(raw) {
+			continue
+		}
+
+		err = m.Keys.Decrypted(&secret).Scan(raw)
+		if err != nil {
+			return 0, fmt.Errorf("partner %d: %w", id, err)
+		}
+
+		pending = append(pending, rotation{id, secret})
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, r := range pending {
+		_, err := m.DB.ExecContext(ctx, `UPDATE partners SET secret = $1 WHERE id = $2`, m.Keys.Encrypted(r.secret), r.id)
+		if err != nil {
+			return 0, fmt.Errorf("partner %d: %w", r.id, err)
+		}
+	}
+
+	return int64(len(pending)), nil
+}
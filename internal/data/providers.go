@@ -0,0 +1,242 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+var ErrDuplicateProvider = errors.New("duplicate provider")
+
+// AvailabilityTypes are the ways a provider can offer a movie.
+var AvailabilityTypes = []string{"stream", "rent", "buy"}
+
+// Provider is a streaming service or store that offers movies.
+type Provider struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	Name      string    `json:"name"`
+	Version   int32     `json:"version"`
+}
+
+// Availability records that a provider offers a movie in a region.
+type Availability struct {
+	MovieID      int64  `json:"-"`
+	ProviderID   int64  `json:"provider_id"`
+	ProviderName string `json:"provider_name"`
+	Region       string `json:"region"`
+	Type         string `json:"type"`
+	URL          string `json:"url"`
+}
+
+func ValidateProvider(v *validator.Validator, provider *Provider) {
+	v.Check(provider.Name != "", "name", "must be provided")
+	v.Check(len(provider.Name) <= 500, "name", "must not be more than 500 bytes long")
+}
+
+func ValidateAvailability(v *validator.Validator, a *Availability) {
+	v.Check(a.ProviderID > 0, "provider_id", "must be provided")
+	v.Check(validator.Matches(a.Region, CountryRX), "region", "must be a two-letter ISO 3166-1 code")
+	v.Check(validator.In(a.Type, AvailabilityTypes...), "type", "must be one of stream, rent or buy")
+
+	u, err := url.Parse(a.URL)
+	v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "url", "must be an absolute http or https URL")
+}
+
+type ProviderModel struct {
+	DB *DB
+}
+
+func (m *ProviderModel) Insert(provider *Provider) error {
+	query := `INSERT INTO providers (name)
+	VALUES ($1)
+	RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, provider.Name).Scan(&provider.ID, &provider.CreatedAt, &provider.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "providers_name_key"`:
+			return ErrDuplicateProvider
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *ProviderModel) Get(id int64) (*Provider, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT id, created_at, name, version
+	FROM providers
+	WHERE id = $1`
+
+	var provider Provider
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&provider.ID, &provider.CreatedAt, &provider.Name, &provider.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &provider, nil
+}
+
+func (m *ProviderModel) GetAll() ([]*Provider, error) {
+	query := `SELECT id, created_at, name, version
+	FROM providers
+	ORDER BY name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	providers := []*Provider{}
+
+	for rows.Next() {
+		var provider Provider
+
+		err := rows.Scan(&provider.ID, &provider.CreatedAt, &provider.Name, &provider.Version)
+		if err != nil {
+			return nil, err
+		}
+
+		providers = append(providers, &provider)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return providers, nil
+}
+
+// GetAvailability returns where a movie can be watched. An empty region
+// returns every region.
+func (m *ProviderModel) GetAvailability(movieID int64, region string) ([]*Availability, error) {
+	query := `SELECT a.movie_id, a.provider_id, p.name, a.region, a.type, a.url
+	FROM movie_availability a
+	INNER JOIN providers p ON p.id = a.provider_id
+	WHERE a.movie_id = $1
+	AND (a.region = $2 OR $2 = '')
+	ORDER BY a.region, p.name, a.type`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, region)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	availability := []*Availability{}
+
+	for rows.Next() {
+		var a Availability
+
+		err := rows.Scan(&a.MovieID, &a.ProviderID, &a.ProviderName, &a.Region, &a.Type, &a.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		availability = append(availability, &a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return availability, nil
+}
+
+// SetAvailability adds a movie availability entry, replacing the URL of an
+// existing entry for the same provider, region and type.
+func (m *ProviderModel) SetAvailability(a *Availability) error {
+	query := `INSERT INTO movie_availability (movie_id, provider_id, region, type, url)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (movie_id, provider_id, region, type) DO UPDATE SET url = EXCLUDED.url`
+
+	_, err := m.changeAvailability(a.MovieID, query, a.MovieID, a.ProviderID, a.Region, a.Type, a.URL)
+	return err
+}
+
+func (m *ProviderModel) DeleteAvailability(movieID, providerID int64, region, availabilityType string) error {
+	query := `DELETE FROM movie_availability
+	WHERE movie_id = $1 AND provider_id = $2 AND region = $3 AND type = $4`
+
+	rowsAffected, err := m.changeAvailability(movieID, query, movieID, providerID, region, availabilityType)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// changeAvailability runs query against the movie's availability and, if it
+// changed anything, bumps the movie's version in the same transaction.
+// Availability is part of the movie response, so this keeps its ETag and
+// the caches that rely on the version from serving the old entries.
+func (m *ProviderModel) changeAvailability(movieID int64, query string, args ...interface{}) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if rowsAffected == 0 {
+		return 0, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE movies SET updated_at = NOW(), version = version + 1 WHERE id = $1`, movieID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	m.DB.notify(Change{Entity: EntityMovie, Action: ChangeUpdated, ID: movieID})
+	return rowsAffected, nil
+}
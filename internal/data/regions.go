@@ -0,0 +1,82 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// AnyRegion is the region of clients that region restrictions do not apply
+// to, such as all clients when the server does not work out their region.
+const AnyRegion = "*"
+
+// MaxRegions bounds the length of a movie's allowed and blocked region lists.
+const MaxRegions = 250
+
+func ValidateRegions(v *validator.Validator, allowed, blocked []string) {
+	check := func(key string, regions []string) {
+		v.Check(len(regions) <= MaxRegions, key, fmt.Sprintf("must not contain more than %d regions", MaxRegions))
+		v.Check(validator.All(regions, func(r string) bool { return validator.Matches(r, CountryRX) }), key, "must only contain two-letter ISO 3166-1 codes")
+		v.Check(validator.Unique(regions), key, "must not contain duplicate values")
+	}
+
+	check("allowed_regions", allowed)
+	check("blocked_regions", blocked)
+
+	for _, region := range blocked {
+		if validator.In(region, allowed...) {
+			v.AddError("blocked_regions", "must not contain allowed regions")
+			break
+		}
+	}
+}
+
+// AvailableIn reports whether the movie may be shown to a client in region,
+// which is a two-letter country code, AnyRegion, or "" if the client's region
+// is unknown. Movies with allowed regions are not shown in unknown regions.
+func (movie *Movie) AvailableIn(region string) bool {
+	if region == AnyRegion {
+		return true
+	}
+	if validator.In(region, movie.BlockedRegions...) {
+		return false
+	}
+	return len(movie.AllowedRegions) == 0 || validator.In(region, movie.AllowedRegions...)
+}
+
+// regionWhere returns the condition matching the movies that AvailableIn
+// allows in the region passed as parameter $n.
+func regionWhere(n int) string {
+	return fmt.Sprintf(`($%[1]d = '*' OR (NOT $%[1]d = ANY(blocked_regions) AND (allowed_regions = '{}' OR $%[1]d = ANY(allowed_regions))))`, n)
+}
+
+// SetRegions saves the movie's allowed and blocked regions.
+func (m *MovieModel) SetRegions(movie *Movie) error {
+	if movie.AllowedRegions == nil {
+		movie.AllowedRegions = []string{}
+	}
+	if movie.BlockedRegions == nil {
+		movie.BlockedRegions = []string{}
+	}
+
+	query := `UPDATE movies
+	SET allowed_regions = $1, blocked_regions = $2, updated_at = NOW(), version = version + 1
+	WHERE id = $3 AND version = $4
+	RETURNING updated_at, version`
+
+	args := []interface{}{pq.Array(movie.AllowedRegions), pq.Array(movie.BlockedRegions), movie.ID, movie.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := updateVersioned(ctx, m.DB, "movies", movie.ID, query, args, &movie.UpdatedAt, &movie.Version)
+	if err != nil {
+		return err
+	}
+
+	m.DB.notify(Change{Entity: EntityMovie, Action: ChangeUpdated, ID: movie.ID})
+	return nil
+}
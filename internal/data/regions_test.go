@@ -0,0 +1,51 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+func TestMovieAvailableIn(t *testing.T) {
+	tests := []struct {
+		name             string
+		allowed, blocked []string
+		region           string
+		want             bool
+	}{
+		{"unrestricted", nil, nil, "DE", true},
+		{"unrestricted, unknown region", nil, nil, "", true},
+		{"allowed", []string{"DE", "AT"}, nil, "AT", true},
+		{"not allowed", []string{"DE", "AT"}, nil, "FR", false},
+		{"allow list, unknown region", []string{"DE"}, nil, "", false},
+		{"blocked", nil, []string{"CN"}, "CN", false},
+		{"not blocked", nil, []string{"CN"}, "DE", true},
+		{"block list, unknown region", nil, []string{"CN"}, "", true},
+		{"restrictions off", []string{"DE"}, []string{"CN"}, AnyRegion, true},
+	}
+
+	for _, tt := range tests {
+		movie := &Movie{AllowedRegions: tt.allowed, BlockedRegions: tt.blocked}
+
+		if got := movie.AvailableIn(tt.region); got != tt.want {
+			t.Errorf("%s: AvailableIn(%q) = %t; want %t", tt.name, tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRegions(t *testing.T) {
+	v := validator.New()
+	ValidateRegions(v, []string{"DE", "FR"}, []string{"CN"})
+	if !v.Valid() {
+		t.Errorf("valid regions rejected: %v", v.Errors)
+	}
+
+	v = validator.New()
+	ValidateRegions(v, []string{"de", "FR", "FR"}, []string{"FR"})
+	if _, ok := v.Errors["allowed_regions"]; !ok {
+		t.Error("lower-case and duplicate allowed regions were accepted")
+	}
+	if _, ok := v.Errors["blocked_regions"]; !ok {
+		t.Error("a region both allowed and blocked was accepted")
+	}
+}
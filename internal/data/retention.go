@@ -0,0 +1,107 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// retentionTarget says which rows of a table count as old: those where the
+// column expression is before the cutoff (and the filter holds, if any).
+type retentionTarget struct {
+	table  string
+	column string
+	filter string
+}
+
+// The purgeable data. Completed and cancelled erasures are the audit trail of
+// account deletions; pending ones are never purged.
+var retentionTargets = map[string]retentionTarget{
+	"tokens":        {table: "tokens", column: "expiry"},
+	"operations":    {table: "operations", column: "updated_at", filter: "status IN ('succeeded', 'failed')"},
+	"usage":         {table: "usage", column: "day"},
+	"exports":       {table: "user_exports", column: "created_at"},
+	"notifications": {table: "notifications", column: "created_at"},
+	"erasures":      {table: "user_erasures", column: "COALESCE(completed_at, cancelled_at)", filter: "(completed_at IS NOT NULL OR cancelled_at IS NOT NULL)"},
+}
+
+// RetentionTargets returns the names of the data that retention policies can
+// apply to.
+func RetentionTargets() []string {
+	names := make([]string, 0, len(retentionTargets))
+	for name := range retentionTargets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// purgeBatchSize limits how many rows one DELETE removes, so that a large
+// purge does not hold locks for long.
+const purgeBatchSize = 5000
+
+type RetentionModel struct {
+	DB *DB
+}
+
+func (t retentionTarget) where() string {
+	where := fmt.Sprintf("%s < $1", t.column)
+	if t.filter != "" {
+		where += " AND " + t.filter
+	}
+	return where
+}
+
+// Count returns how many rows of the target are older than the cutoff.
+func (m *RetentionModel) Count(target string, cutoff time.Time) (int64, error) {
+	t, ok := retentionTargets[target]
+	if !ok {
+		return 0, fmt.Errorf("unknown retention target %q", target)
+	}
+
+	query := fmt.Sprintf(`SELECT count(*) FROM %s WHERE %s`, t.table, t.where())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var n int64
+
+	err := m.DB.QueryRowContext(ctx, query, cutoff).Scan(&n)
+	return n, err
+}
+
+// Purge deletes the rows of the target that are older than the cutoff, in
+// batches, and returns how many were deleted.
+func (m *RetentionModel) Purge(target string, cutoff time.Time) (int64, error) {
+	t, ok := retentionTargets[target]
+	if !ok {
+		return 0, fmt.Errorf("unknown retention target %q", target)
+	}
+
+	query := fmt.Sprintf(`
+	DELETE FROM %[1]s
+	WHERE ctid IN (SELECT ctid FROM %[1]s WHERE %[2]s LIMIT %[3]d)`, t.table, t.where(), purgeBatchSize)
+
+	var total int64
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		result, err := m.DB.ExecContext(ctx, query, cutoff)
+		cancel()
+		if err != nil {
+			return total, err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+
+		if n < purgeBatchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+const MaxRuntime Runtime = 24 * 60
+
+var ErrInvalidRuntimeFormat = errors.New("invalid runtime format")
+
+// RuntimeFormat selects how Runtime values are written in API responses.
+// MarshalJSON always writes minutes; the string format is applied to the
+// encoded response by the API server.
+type RuntimeFormat int
+
+const (
+	RuntimeFormatMinutes RuntimeFormat = iota
+	RuntimeFormatString
+)
+
+// Runtime is a movie runtime in minutes.
+type Runtime int32
+
+func ParseRuntimeFormat(s string) (RuntimeFormat, error) {
+	switch s {
+	case "minutes":
+		return RuntimeFormatMinutes, nil
+	case "string":
+		return RuntimeFormatString, nil
+	default:
+		return 0, fmt.Errorf("unknown runtime format %q (must be minutes or string)", s)
+	}
+}
+
+// ParseRuntime accepts a plain number of minutes ("107"), a number followed
+// by a minutes unit ("107 mins") or a Go-style duration made up of whole
+// minutes ("1h47m").
+func ParseRuntime(s string) (Runtime, error) {
+	s = strings.TrimSpace(s)
+
+	for _, suffix := range []string{" minutes", " minute", " mins", " min"} {
+		if strings.HasSuffix(s, suffix) {
+			s = strings.TrimSuffix(s, suffix)
+			break
+		}
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 32); err == nil {
+		return Runtime(i), nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d%time.Minute != 0 || d.Minutes() > float64(1<<31-1) || d.Minutes() < -float64(1<<31) {
+		return 0, ErrInvalidRuntimeFormat
+	}
+
+	return Runtime(d / time.Minute), nil
+}
+
+func (r Runtime) String() string {
+	return fmt.Sprintf("%d mins", r)
+}
+
+func (r Runtime) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(int64(r), 10)), nil
+}
+
+func (r *Runtime) UnmarshalJSON(jsonValue []byte) error {
+	if string(jsonValue) == "null" {
+		return nil
+	}
+
+	if len(jsonValue) > 0 && jsonValue[0] == '"' {
+		var s string
+
+		err := json.Unmarshal(jsonValue, &s)
+		if err != nil {
+			return ErrInvalidRuntimeFormat
+		}
+
+		parsed, err := ParseRuntime(s)
+		if err != nil {
+			return err
+		}
+
+		*r = parsed
+		return nil
+	}
+
+	i, err := strconv.ParseInt(string(jsonValue), 10, 32)
+	if err != nil {
+		return ErrInvalidRuntimeFormat
+	}
+
+	*r = Runtime(i)
+	return nil
+}
+
+func (r Runtime) Validate(v *validator.Validator) {
+	v.Check(r != 0, "runtime", "must be provided")
+	v.Check(r > 0, "runtime", "must be a positive integer")
+	v.Check(r <= MaxRuntime, "runtime", fmt.Sprintf("must not be more than %d minutes", MaxRuntime))
+}
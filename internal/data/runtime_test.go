@@ -0,0 +1,74 @@
+package data
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+func TestRuntimeUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Runtime
+		wantErr bool
+	}{
+		{input: `107`, want: 107},
+		{input: `"107"`, want: 107},
+		{input: `"107 mins"`, want: 107},
+		{input: `"1 min"`, want: 1},
+		{input: `"1h47m"`, want: 107},
+		{input: `"90m"`, want: 90},
+		{input: `"1h47m30s"`, wantErr: true},
+		{input: `"107 hours"`, wantErr: true},
+		{input: `107.5`, wantErr: true},
+		{input: `"99999999999 mins"`, wantErr: true},
+		{input: `true`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var got Runtime
+
+		err := json.Unmarshal([]byte(tt.input), &got)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: got %d; want an error", tt.input, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tt.input, err)
+		} else if got != tt.want {
+			t.Errorf("%s: got %d; want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func FuzzRuntimeUnmarshalJSON(f *testing.F) {
+	f.Add(`107`)
+	f.Add(`"107 mins"`)
+	f.Add(`"1h47m"`)
+	f.Add(`"-5m"`)
+	f.Add(`null`)
+	f.Add(`"9223372036854775807ns"`)
+
+	f.Fuzz(func(t *testing.T, input string) {
+		var r Runtime
+		if err := r.UnmarshalJSON([]byte(input)); err != nil {
+			return
+		}
+
+		minutes, err := r.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The string format is what responses carry with -runtime-format=string.
+		for _, js := range [][]byte{minutes, []byte(strconv.Quote(r.String()))} {
+			var roundTripped Runtime
+			if err := roundTripped.UnmarshalJSON(js); err != nil || roundTripped != r {
+				t.Errorf("%s: round trip through %s gave %d, %v; want %d", input, js, roundTripped, err, r)
+			}
+		}
+	})
+}
@@ -0,0 +1,447 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+const (
+	NotifyInApp = "in_app"
+	NotifyEmail = "email"
+
+	// MaxSavedSearches is how many searches one user may save.
+	MaxSavedSearches = 20
+
+	// maxSearchMatches caps the movies one check of a search reports. Any
+	// further matches are picked up by the next check.
+	maxSearchMatches = 50
+)
+
+// SavedSearch is a movie filter a user has kept. If Notify names any
+// channels, the user is told through them about new movies that match it.
+type SavedSearch struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"-"`
+	Name        string     `json:"name"`
+	Filter      MovieQuery `json:"filter"`
+	Notify      []string   `json:"notify"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastMovieID int64      `json:"-"`
+	// Region is the client region the search was saved from. Only movies
+	// available there are notified about.
+	Region string `json:"-"`
+}
+
+// Notification is an in-app notice that a movie matching one of the user's
+// saved searches was added.
+type Notification struct {
+	ID            int64     `json:"id"`
+	UserID        int64     `json:"-"`
+	SavedSearchID int64     `json:"saved_search_id"`
+	MovieID       int64     `json:"movie_id"`
+	Title         string    `json:"title"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func ValidateSavedSearch(v *validator.Validator, s *SavedSearch) {
+	v.Check(s.Name != "", "name", "must be provided")
+	v.Check(len(s.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	v.Check(!s.Filter.IsEmpty(), "filter", "must contain at least one criterion")
+	ValidateMovieQuery(v, s.Filter)
+
+	for _, channel := range s.Notify {
+		v.Check(validator.In(channel, NotifyInApp, NotifyEmail), "notify", fmt.Sprintf("must only contain %s or %s", NotifyInApp, NotifyEmail))
+	}
+	v.Check(validator.Unique(s.Notify), "notify", "must not contain duplicate values")
+}
+
+// Notifies reports whether the search notifies through channel.
+func (s *SavedSearch) Notifies(channel string) bool {
+	return validator.In(channel, s.Notify...)
+}
+
+const savedSearchColumns = `id, user_id, name, filter, notify, created_at, last_movie_id, region`
+
+func (s *SavedSearch) scan(row interface{ Scan(...interface{}) error }) error {
+	var filter []byte
+
+	err := row.Scan(&s.ID, &s.UserID, &s.Name, &filter, pq.Array(&s.Notify), &s.CreatedAt, &s.LastMovieID, &s.Region)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(filter, &s.Filter)
+}
+
+type SavedSearchModel struct {
+	DB *DB
+}
+
+// Insert saves a search. Only movies added after it is saved are notified
+// about.
+func (m *SavedSearchModel) Insert(s *SavedSearch) error {
+	filter, err := json.Marshal(s.Filter)
+	if err != nil {
+		return err
+	}
+
+	if s.Notify == nil {
+		s.Notify = []string{}
+	}
+
+	query := `
+	INSERT INTO saved_searches (user_id, name, filter, notify, region, last_movie_id)
+	VALUES ($1, $2, $3, $4, $5, (SELECT COALESCE(max(id), 0) FROM movies))
+	RETURNING id, created_at, last_movie_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, s.UserID, s.Name, filter, pq.Array(s.Notify), s.Region).Scan(&s.ID, &s.CreatedAt, &s.LastMovieID, &s.Region)
+}
+
+// GetAllForUser returns the user's saved searches, oldest first.
+func (m *SavedSearchModel) GetAllForUser(userID int64) ([]*SavedSearch, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM saved_searches
+	WHERE user_id = $1
+	ORDER BY id`, savedSearchColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	searches := []*SavedSearch{}
+
+	for rows.Next() {
+		var s SavedSearch
+
+		err := s.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		searches = append(searches, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return searches, nil
+}
+
+// Delete deletes one of the user's saved searches, along with the
+// notifications it raised.
+func (m *SavedSearchModel) Delete(id, userID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+	DELETE FROM saved_searches
+	WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetDue returns the IDs of searches that notify through any channel and have
+// not been checked against the newest movie.
+func (m *SavedSearchModel) GetDue() ([]int64, error) {
+	query := `
+	SELECT id
+	FROM saved_searches
+	WHERE notify <> '{}' AND last_movie_id < (SELECT COALESCE(max(id), 0) FROM movies)
+	ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+
+	for rows.Next() {
+		var id int64
+
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// Check finds the movies added since the search was last checked that match
+// it, records in-app notifications for them if the search has those turned
+// on, and marks the search as checked, in a single transaction. It returns a
+// nil search if the search was deleted or another instance is checking it.
+//
+// With enforceRegions set, only movies available in the search's region are
+// reported; a search saved while restrictions were off counts as from an
+// unknown region.
+func (m *SavedSearchModel) Check(id int64, enforceRegions bool) (*SavedSearch, []*Movie, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM saved_searches
+	WHERE id = $1
+	FOR UPDATE SKIP LOCKED`, savedSearchColumns)
+
+	var s SavedSearch
+
+	err = s.scan(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, nil
+		default:
+			return nil, nil, err
+		}
+	}
+
+	var checkedUpTo int64
+
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(max(id), 0) FROM movies`).Scan(&checkedUpTo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query = fmt.Sprintf(`
+	SELECT %s
+	FROM movies
+	WHERE %s AND id > $8 AND id <= $9 AND %s
+	ORDER BY id
+	LIMIT $10`, movieColumns, movieQueryWhere, regionWhere(11))
+
+	region := AnyRegion
+	if enforceRegions {
+		region = s.Region
+		if region == AnyRegion {
+			region = ""
+		}
+	}
+
+	args := append(s.Filter.args(), s.LastMovieID, checkedUpTo, maxSearchMatches, region)
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	movies := []*Movie{}
+	movieIDs := []int64{}
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(movie.scanDest()...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		movies = append(movies, &movie)
+		movieIDs = append(movieIDs, movie.ID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(movies) == maxSearchMatches {
+		checkedUpTo = movies[len(movies)-1].ID
+	}
+
+	if len(movies) > 0 && s.Notifies(NotifyInApp) {
+		query := `
+		INSERT INTO notifications (user_id, saved_search_id, movie_id)
+		SELECT $1, $2, unnest($3::bigint[])
+		ON CONFLICT (saved_search_id, movie_id) DO NOTHING`
+
+		_, err := tx.ExecContext(ctx, query, s.UserID, s.ID, pq.Array(movieIDs))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE saved_searches SET last_movie_id = $1 WHERE id = $2`, checkedUpTo, s.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.LastMovieID = checkedUpTo
+
+	return &s, movies, nil
+}
+
+type NotificationModel struct {
+	DB *DB
+}
+
+// GetForUser returns a page of the user's notifications, newest first.
+func (m *NotificationModel) GetForUser(userID int64, filters Filters) ([]*Notification, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), notifications.id, notifications.user_id, notifications.saved_search_id,
+		notifications.movie_id, movies.title, notifications.created_at
+	FROM notifications
+	INNER JOIN movies ON movies.id = notifications.movie_id
+	WHERE notifications.user_id = $1
+	ORDER BY notifications.id DESC
+	LIMIT $2 OFFSET $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	notifications := []*Notification{}
+
+	for rows.Next() {
+		var n Notification
+
+		err := rows.Scan(&totalRecords, &n.ID, &n.UserID, &n.SavedSearchID, &n.MovieID, &n.Title, &n.CreatedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		notifications = append(notifications, &n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return notifications, metadata, nil
+}
+
+// GetAllForUser returns every notification the user has, oldest first.
+func (m *NotificationModel) GetAllForUser(userID int64) ([]*Notification, error) {
+	query := `
+	SELECT notifications.id, notifications.user_id, notifications.saved_search_id,
+		notifications.movie_id, movies.title, notifications.created_at
+	FROM notifications
+	INNER JOIN movies ON movies.id = notifications.movie_id
+	WHERE notifications.user_id = $1
+	ORDER BY notifications.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*Notification{}
+
+	for rows.Next() {
+		var n Notification
+
+		err := rows.Scan(&n.ID, &n.UserID, &n.SavedSearchID, &n.MovieID, &n.Title, &n.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		notifications = append(notifications, &n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// Delete dismisses one of the user's notifications.
+func (m *NotificationModel) Delete(id, userID int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+	DELETE FROM notifications
+	WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
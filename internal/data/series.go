@@ -0,0 +1,104 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/validator"
+)
+
+// Series groups movies into a franchise. Movies are linked to a series by
+// their SeriesID and listed in SeriesOrder.
+type Series struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	Name      string    `json:"name"`
+	Version   int32     `json:"version"`
+}
+
+func ValidateSeries(v *validator.Validator, series *Series) {
+	v.Check(series.Name != "", "name", "must be provided")
+	v.Check(len(series.Name) <= 500, "name", "must not be more than 500 bytes long")
+}
+
+type SeriesModel struct {
+	DB *DB
+}
+
+func (m *SeriesModel) Insert(series *Series) error {
+	query := `INSERT INTO series (name)
+	VALUES ($1)
+	RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, series.Name).Scan(&series.ID, &series.CreatedAt, &series.Version)
+}
+
+func (m *SeriesModel) Get(id int64) (*Series, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT id, created_at, name, version
+	FROM series
+	WHERE id = $1`
+
+	var series Series
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&series.ID, &series.CreatedAt, &series.Name, &series.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &series, nil
+}
+
+// GetMovies returns the movies in a series that are available in region, in
+// their series order, falling back to release order for movies sharing a
+// position.
+func (m *SeriesModel) GetMovies(id int64, region string) ([]*Movie, error) {
+	query := `SELECT ` + movieColumns + `
+	FROM movies
+	WHERE series_id = $1 AND ` + regionWhere(2) + `
+	ORDER BY series_order, release_date NULLS LAST, year, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id, region)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(movie.scanDest()...)
+		if err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
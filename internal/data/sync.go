@@ -0,0 +1,210 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+const (
+	SyncUpsert = "upsert"
+	SyncDelete = "delete"
+
+	// MaxSyncChanges is the most changes one sync batch may hold.
+	MaxSyncChanges = 500
+)
+
+// SyncChange is one change in a batch from an upstream catalogue. Movies are
+// matched on ExternalID, the upstream's own ID for them, among the movies of
+// the same source, so one source cannot change another's movies. Movie is set for
+// upserts; its series fields are ignored, as series are curated locally.
+type SyncChange struct {
+	Op         string
+	ExternalID string
+	Movie      *Movie
+}
+
+// SyncResult reports what a batch did. A batch whose cursor is not past the
+// source's current one has already been applied and is not applied again.
+type SyncResult struct {
+	Cursor    int64 `json:"cursor"`
+	Applied   bool  `json:"applied"`
+	Created   int   `json:"created"`
+	Updated   int   `json:"updated"`
+	Deleted   int   `json:"deleted"`
+	Unchanged int   `json:"unchanged"`
+}
+
+// SyncCursor is the position of the last batch applied from a source.
+type SyncCursor struct {
+	Source    string    `json:"source"`
+	Cursor    int64     `json:"cursor"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func ValidateSyncBatch(v *validator.Validator, cursor int64, changes []SyncChange) {
+	v.Check(cursor > 0, "cursor", "must be greater than zero")
+	v.Check(len(changes) > 0, "changes", "must contain at least one change")
+	v.Check(len(changes) <= MaxSyncChanges, "changes", "must not contain more than 500 changes")
+}
+
+func ValidateSyncChange(v *validator.Validator, c SyncChange, rules MovieRules) {
+	v.Check(validator.In(c.Op, SyncUpsert, SyncDelete), "op", "must be upsert or delete")
+	v.Check(c.ExternalID != "", "external_id", "must be provided")
+	v.Check(len(c.ExternalID) <= 200, "external_id", "must not be more than 200 bytes long")
+
+	if c.Op == SyncUpsert {
+		v.Check(c.Movie != nil, "movie", "must be provided")
+		if c.Movie != nil {
+			ValidateMovie(v, c.Movie, rules)
+		}
+	}
+}
+
+type SyncModel struct {
+	DB *DB
+}
+
+// GetCursor returns the source's cursor, which is zero if it has never synced.
+func (m *SyncModel) GetCursor(source string) (*SyncCursor, error) {
+	query := `
+	SELECT source, cursor, updated_at
+	FROM sync_cursors
+	WHERE source = $1`
+
+	cursor := SyncCursor{Source: source}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, source).Scan(&cursor.Source, &cursor.Cursor, &cursor.UpdatedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return &cursor, nil
+}
+
+// Apply applies a batch of changes from source and moves its cursor to cursor,
+// all in one transaction, so a batch is applied entirely or not at all.
+// Batches at or behind the current cursor are skipped, which makes resending
+// one safe. Upserts that would not change a movie leave it, and its version,
+// alone.
+func (m *SyncModel) Apply(source string, cursor int64, changes []SyncChange) (*SyncResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO sync_cursors (source) VALUES ($1) ON CONFLICT DO NOTHING`, source)
+	if err != nil {
+		return nil, err
+	}
+
+	// Locking the cursor row makes concurrent batches from the same source
+	// apply one after the other.
+	result := &SyncResult{}
+
+	err = tx.QueryRowContext(ctx, `SELECT cursor FROM sync_cursors WHERE source = $1 FOR UPDATE`, source).Scan(&result.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	if cursor <= result.Cursor {
+		return result, nil
+	}
+
+	upsert := `
+	INSERT INTO movies (source, external_id, title, year, release_date, runtime, genres, synopsis, original_language, country, budget, box_office, certification)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	ON CONFLICT (source, external_id) DO UPDATE
+	SET title = EXCLUDED.title, year = EXCLUDED.year, release_date = EXCLUDED.release_date, runtime = EXCLUDED.runtime,
+		genres = EXCLUDED.genres, synopsis = EXCLUDED.synopsis, original_language = EXCLUDED.original_language,
+		country = EXCLUDED.country, budget = EXCLUDED.budget, box_office = EXCLUDED.box_office,
+		certification = EXCLUDED.certification, updated_at = NOW(), version = movies.version + 1
+	WHERE (movies.title, movies.year, movies.release_date, movies.runtime, movies.genres, movies.synopsis,
+		movies.original_language, movies.country, movies.budget, movies.box_office, movies.certification)
+		IS DISTINCT FROM
+		(EXCLUDED.title, EXCLUDED.year, EXCLUDED.release_date, EXCLUDED.runtime, EXCLUDED.genres, EXCLUDED.synopsis,
+		EXCLUDED.original_language, EXCLUDED.country, EXCLUDED.budget, EXCLUDED.box_office, EXCLUDED.certification)
+	RETURNING xmax = 0`
+
+	for _, c := range changes {
+		switch c.Op {
+		case SyncUpsert:
+			movie := c.Movie
+			args := []interface{}{
+				source,
+				c.ExternalID,
+				movie.Title,
+				movie.Year,
+				movie.ReleaseDate,
+				movie.Runtime,
+				pq.Array(movie.Genres),
+				movie.Synopsis,
+				movie.OriginalLanguage,
+				movie.Country,
+				movie.Budget,
+				movie.BoxOffice,
+				movie.Certification,
+			}
+
+			// xmax is zero for a freshly inserted row. No row comes back when
+			// the movie is already up to date.
+			var inserted bool
+
+			err := tx.QueryRowContext(ctx, upsert, args...).Scan(&inserted)
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				result.Unchanged++
+			case err != nil:
+				return nil, err
+			case inserted:
+				result.Created++
+			default:
+				result.Updated++
+			}
+		case SyncDelete:
+			res, err := tx.ExecContext(ctx, `DELETE FROM movies WHERE source = $1 AND external_id = $2`, source, c.ExternalID)
+			if err != nil {
+				return nil, err
+			}
+
+			n, err := res.RowsAffected()
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				result.Unchanged++
+			} else {
+				result.Deleted++
+			}
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE sync_cursors SET cursor = $1, updated_at = NOW() WHERE source = $2`, cursor, source)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	result.Cursor = cursor
+	result.Applied = true
+
+	if changed := int64(result.Created + result.Updated + result.Deleted); changed > 0 {
+		m.DB.notify(Change{Entity: EntityMovie, Action: ChangeBulkUpdated, Count: changed})
+	}
+
+	return result, nil
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
-	"database/sql"
 	"encoding/base32"
 	"time"
 
@@ -14,6 +13,7 @@ import (
 const (
 	ScopeActivation     = "activation"
 	ScopeAuthentication = "authentication"
+	ScopeExport         = "export"
 )
 
 type Token struct {
@@ -25,7 +25,7 @@ type Token struct {
 }
 
 type TokenModel struct {
-	DB *sql.DB
+	DB *DB
 }
 
 func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string) {
@@ -81,7 +81,7 @@ func (m *TokenModel) Insert(token *Token) error {
 func (m *TokenModel) DeleteAllForUser(scope string, userID int64) error {
 	query := `
 	DELETE FROM tokens 
-	WHERE scope = &1 AND user_id = $2`
+	WHERE scope = $1 AND user_id = $2`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -89,3 +89,57 @@ func (m *TokenModel) DeleteAllForUser(scope string, userID int64) error {
 	_, err := m.DB.ExecContext(ctx, query, scope, userID)
 	return err
 }
+
+func (m *TokenModel) DeleteAll(scope string) (int64, error) {
+	query := `
+	DELETE FROM tokens
+	WHERE scope = $1 OR $1 = ''`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, scope)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// GetAllForUser returns a user's unexpired tokens. Only the scope and expiry
+// are loaded; plaintexts are never stored and hashes are left out.
+func (m *TokenModel) GetAllForUser(userID int64) ([]*Token, error) {
+	query := `
+	SELECT user_id, expiry, scope
+	FROM tokens
+	WHERE user_id = $1 AND expiry > NOW()
+	ORDER BY expiry`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*Token{}
+
+	for rows.Next() {
+		var token Token
+
+		err := rows.Scan(&token.UserID, &token.Expiry, &token.Scope)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, &token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
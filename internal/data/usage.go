@@ -0,0 +1,112 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// UsageCount is a number of requests made by one of a user's clients on one
+// day. Client identifies the credential used, e.g. "token:1a2b3c4d" or
+// "partner:gl_abc".
+type UsageCount struct {
+	UserID   int64  `json:"-"`
+	Client   string `json:"client"`
+	Day      Date   `json:"day"`
+	Requests int64  `json:"requests"`
+}
+
+type UsageModel struct {
+	DB *DB
+}
+
+// Add adds the counts to the stored totals in a single statement.
+func (m *UsageModel) Add(counts []UsageCount) error {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	userIDs := make([]int64, len(counts))
+	clients := make([]string, len(counts))
+	days := make([]string, len(counts))
+	requests := make([]int64, len(counts))
+
+	for i, c := range counts {
+		userIDs[i], clients[i], days[i], requests[i] = c.UserID, c.Client, c.Day.String(), c.Requests
+	}
+
+	query := `
+	INSERT INTO usage (user_id, client, day, requests)
+	SELECT u.user_id, u.client, u.day::date, u.requests
+	FROM unnest($1::bigint[], $2::text[], $3::text[], $4::bigint[]) AS u(user_id, client, day, requests)
+	WHERE EXISTS (SELECT 1 FROM users WHERE users.id = u.user_id)
+	ON CONFLICT (user_id, client, day) DO UPDATE SET requests = usage.requests + EXCLUDED.requests`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, pq.Array(userIDs), pq.Array(clients), pq.Array(days), pq.Array(requests))
+	return err
+}
+
+// GetForUser returns the user's daily counts for days in [from, to), ordered
+// by day and client.
+func (m *UsageModel) GetForUser(userID int64, from, to time.Time) ([]*UsageCount, error) {
+	query := `
+	SELECT user_id, client, day, requests
+	FROM usage
+	WHERE user_id = $1 AND day >= $2 AND day < $3
+	ORDER BY day, client`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []*UsageCount{}
+
+	for rows.Next() {
+		var c UsageCount
+
+		err := rows.Scan(&c.UserID, &c.Client, &c.Day, &c.Requests)
+		if err != nil {
+			return nil, err
+		}
+
+		counts = append(counts, &c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// GetAllForUser returns every daily count recorded for the user, ordered by
+// day and client.
+func (m *UsageModel) GetAllForUser(userID int64) ([]*UsageCount, error) {
+	return m.GetForUser(userID, time.Time{}, time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// TotalForUser returns the number of requests the user made on days in
+// [from, to).
+func (m *UsageModel) TotalForUser(userID int64, from, to time.Time) (int64, error) {
+	query := `
+	SELECT COALESCE(SUM(requests), 0)
+	FROM usage
+	WHERE user_id = $1 AND day >= $2 AND day < $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var total int64
+
+	err := m.DB.QueryRowContext(ctx, query, userID, from, to).Scan(&total)
+	return total, err
+}
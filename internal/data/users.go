@@ -1,10 +1,12 @@
 package data
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/levisthors/greenlight/internal/validator"
@@ -23,11 +25,16 @@ type User struct {
 	Email     string    `json:"email"`
 	Password  password  `json:"-"`
 	Activated bool      `json:"activated"`
-	Version   int       `json:"-"`
+	Version   int       `json:"version"`
+
+	// AnalyticsConsent is nil until the user opts in or out of analytics.
+	AnalyticsConsent *bool `json:"analytics_consent,omitempty"`
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB *DB
+	// Keys encrypts email addresses; see Keyring.
+	Keys *Keyring
 }
 
 type password struct {
@@ -93,47 +100,115 @@ func (p *password) Matches(plaintextPassword string) (bool, error) {
 
 func (m *UserModel) Insert(user *User) error {
 	query := `
-	INSERT INTO users (name, email, password_hash, activated)
-	VALUES ($1, $2, $3, $4)
+	INSERT INTO users (name, email, email_hash, password_hash, activated)
+	VALUES ($1, $2, $3, $4, $5)
 	RETURNING id, created_at, version`
 
-	args := []interface{}{user.Name, user.Email, user.Password.hash, user.Activated}
+	args := []interface{}{user.Name, m.Keys.Encrypted(user.Email), m.Keys.EmailIndex(user.Email), user.Password.hash, user.Activated}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
+	err := m.checkLegacyEmail(ctx, user.Email, 0)
+	if err != nil {
+		return err
+	}
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_hash_key"`:
 			return ErrDuplicateEmail
 		default:
 			return err
 		}
 	}
 
+	m.DB.notify(Change{Entity: EntityUser, Action: ChangeCreated, ID: user.ID})
+	return nil
+}
+
+// checkLegacyEmail returns ErrDuplicateEmail if a user other than exceptID
+// still has email as a plaintext address. users_email_hash_key only covers
+// rows with an email_hash, and rows from before email encryption have none
+// until "greenlight keys rotate" encrypts them. No new rows like that are
+// written, so checking first cannot race with another insert.
+func (m *UserModel) checkLegacyEmail(ctx context.Context, email string, exceptID int64) error {
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM users
+		WHERE email_hash IS NULL AND erased_at IS NULL AND lower(email) = lower($1) AND id <> $2
+	)`
+
+	var taken bool
+
+	err := m.DB.QueryRowContext(ctx, query, email, exceptID).Scan(&taken)
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		return ErrDuplicateEmail
+	}
 	return nil
 }
 
 func (m *UserModel) GetByEmail(email string) (*User, error) {
 	query := `
-	SELECT id, created_at, name, email, password_hash, activated, version
+	SELECT id, created_at, name, email, password_hash, activated, version, analytics_consent
 	FROM users
-	WHERE email = $1`
+	WHERE email_hash = $1
+	OR (email_hash IS NULL AND erased_at IS NULL AND lower(email) = lower($2))`
 
 	var user User
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, email).Scan(
+	// Rows without an email_hash predate email encryption and still hold the
+	// plaintext address; "greenlight keys rotate" encrypts and indexes them.
+	err := m.DB.QueryRowContext(ctx, query, m.Keys.EmailIndex(email), email).Scan(
 		&user.ID,
 		&user.CreatedAt,
 		&user.Name,
-		&user.Email,
+		m.Keys.Decrypted(&user.Email),
 		&user.Password.hash,
 		&user.Activated,
 		&user.Version,
+		&user.AnalyticsConsent,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+func (m *UserModel) Get(id int64) (*User, error) {
+	query := `
+	SELECT id, created_at, name, email, password_hash, activated, version, analytics_consent
+	FROM users
+	WHERE id = $1`
+
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		m.Keys.Decrypted(&user.Email),
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+		&user.AnalyticsConsent,
 	)
 	if err != nil {
 		switch {
@@ -150,15 +225,17 @@ func (m *UserModel) GetByEmail(email string) (*User, error) {
 func (m UserModel) Update(user *User) error {
 	query := `
 	UPDATE users
-	SET name = $1, email = $2, password_hash = $3, activated = $4, version = version + 1
-	WHERE id = $5 AND version = $6
+	SET name = $1, email = $2, email_hash = $3, password_hash = $4, activated = $5, analytics_consent = $6, version = version + 1
+	WHERE id = $7 AND version = $8
 	RETURNING version`
 
 	args := []interface{}{
 		user.Name,
-		user.Email,
+		m.Keys.Encrypted(user.Email),
+		m.Keys.EmailIndex(user.Email),
 		user.Password.hash,
 		user.Activated,
+		user.AnalyticsConsent,
 		user.ID,
 		user.Version,
 	}
@@ -166,25 +243,29 @@ func (m UserModel) Update(user *User) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.Version)
+	err := m.checkLegacyEmail(ctx, user.Email, user.ID)
+	if err != nil {
+		return err
+	}
+
+	err = updateVersioned(ctx, m.DB, "users", user.ID, query, args, &user.Version)
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_hash_key"`:
 			return ErrDuplicateEmail
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrEditConflict
 		default:
 			return err
 		}
 	}
 
+	m.DB.notify(Change{Entity: EntityUser, Action: ChangeUpdated, ID: user.ID})
 	return nil
 }
 
 func (m *UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 	query := `
-	SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+	SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version, users.analytics_consent
 	FROM users
 	INNER JOIN tokens
 	ON users.id = tokens.user_id
@@ -203,10 +284,11 @@ func (m *UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error
 		&user.ID,
 		&user.CreatedAt,
 		&user.Name,
-		&user.Email,
+		m.Keys.Decrypted(&user.Email),
 		&user.Password.hash,
 		&user.Activated,
 		&user.Version,
+		&user.AnalyticsConsent,
 	)
 	if err != nil {
 		switch {
@@ -219,3 +301,65 @@ func (m *UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error
 
 	return &user, nil
 }
+
+// RotateEmails re-encrypts every email address that is not encrypted with the
+// current key, and recomputes its blind index, returning the number of users
+// updated. It also encrypts addresses stored before encryption was enabled.
+func (m *UserModel) RotateEmails() (int64, error) {
+	if m.Keys == nil {
+		return 0, ErrNoEncryptionKeys
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT id, email, email_hash FROM users WHERE erased_at IS NULL`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	type rotation struct {
+		id    int64
+		email string
+	}
+
+	var pending []rotation
+
+	for rows.Next() {
+		var (
+			id    int64
+			raw   string
+			index []byte
+			email string
+		)
+
+		err := rows.Scan(&id, &raw, &index)
+		if err != nil {
+			return 0, err
+		}
+
+		err = m.Keys.Decrypted(&email).Scan(raw)
+		if err != nil {
+			return 0, fmt.Errorf("user %d: %w", id, err)
+		}
+
+		if m.Keys.needsRotation(raw) || !bytes.Equal(index, m.Keys.EmailIndex(email)) {
+			pending = append(pending, rotation{id, email})
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, r := range pending {
+		_, err := m.DB.ExecContext(ctx, `UPDATE users SET email = $1, email_hash = $2 WHERE id = $3`,
+			m.Keys.Encrypted(r.email), m.Keys.EmailIndex(r.email), r.id)
+		if err != nil {
+			return 0, fmt.Errorf("user %d: %w", r.id, err)
+		}
+	}
+
+	return int64(len(pending)), nil
+}
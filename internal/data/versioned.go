@@ -0,0 +1,48 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// EditConflictError is returned when a versioned update finds the record at a
+// different version than the one it was read at. It matches ErrEditConflict.
+type EditConflictError struct {
+	CurrentVersion int64
+}
+
+func (e *EditConflictError) Error() string {
+	return fmt.Sprintf("%s (current version %d)", ErrEditConflict, e.CurrentVersion)
+}
+
+func (e *EditConflictError) Is(target error) bool {
+	return target == ErrEditConflict
+}
+
+// updateVersioned runs query, an UPDATE of the row with the given id in table
+// that is guarded by "version = <expected version>", scanning its RETURNING
+// columns into dest. If no row matched it reads the stored version to tell
+// the two reasons apart: ErrRecordNotFound if the row has gone, otherwise an
+// *EditConflictError holding the version now stored.
+func updateVersioned(ctx context.Context, db *DB, table string, id int64, query string, args []interface{}, dest ...interface{}) error {
+	err := db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	var current int64
+
+	err = db.QueryRowContext(ctx, `SELECT version FROM `+table+` WHERE id = $1`, id).Scan(&current)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return &EditConflictError{CurrentVersion: current}
+}
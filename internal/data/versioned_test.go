@@ -0,0 +1,20 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestEditConflictError(t *testing.T) {
+	err := fmt.Errorf("saving: %w", &EditConflictError{CurrentVersion: 4})
+
+	if !errors.Is(err, ErrEditConflict) {
+		t.Error("EditConflictError does not match ErrEditConflict")
+	}
+
+	var conflict *EditConflictError
+	if !errors.As(err, &conflict) || conflict.CurrentVersion != 4 {
+		t.Errorf("got %+v; want current version 4", conflict)
+	}
+}
@@ -0,0 +1,99 @@
+// Package geoip looks up the country of IP addresses in a CSV database of
+// address ranges, one "first,last,country" line per range, as in the free
+// DB-IP "IP to Country Lite" download.
+package geoip
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+type ipRange struct {
+	first, last netip.Addr
+	country     string
+}
+
+// DB is a loaded database. It is read-only and safe for concurrent use.
+type DB struct {
+	ranges []ipRange
+}
+
+// Open loads the database in the file at path.
+func Open(path string) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Load(f)
+}
+
+// Load reads a database. Ranges must not overlap.
+func Load(r io.Reader) (*DB, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 3
+	cr.ReuseRecord = true
+
+	db := &DB{}
+
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := cr.FieldPos(0)
+
+		first, err := netip.ParseAddr(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("geoip: line %d: %w", line, err)
+		}
+		last, err := netip.ParseAddr(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("geoip: line %d: %w", line, err)
+		}
+		if first.Is4() != last.Is4() || last.Less(first) {
+			return nil, fmt.Errorf("geoip: line %d: invalid range %s-%s", line, first, last)
+		}
+
+		db.ranges = append(db.ranges, ipRange{first: first.Unmap(), last: last.Unmap(), country: strings.ToUpper(record[2])})
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return db.ranges[i].first.Less(db.ranges[j].first)
+	})
+
+	for i := 1; i < len(db.ranges); i++ {
+		if !db.ranges[i-1].last.Less(db.ranges[i].first) {
+			return nil, fmt.Errorf("geoip: ranges starting at %s and %s overlap", db.ranges[i-1].first, db.ranges[i].first)
+		}
+	}
+
+	return db, nil
+}
+
+// Country returns the country code for addr, or "" if no range holds it.
+func (db *DB) Country(addr netip.Addr) string {
+	addr = addr.Unmap()
+
+	// The last range starting at or before addr is the only one that can
+	// hold it.
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return addr.Less(db.ranges[i].first)
+	}) - 1
+
+	if i < 0 || db.ranges[i].last.Less(addr) {
+		return ""
+	}
+	return db.ranges[i].country
+}
@@ -0,0 +1,55 @@
+package geoip
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+const testDB = `1.0.0.0,1.0.0.255,au
+2.16.0.0,2.16.5.255,DE
+1.0.1.0,1.0.3.255,CN
+2001:db8::,2001:db8::ffff,FR
+`
+
+func TestCountry(t *testing.T) {
+	db, err := Load(strings.NewReader(testDB))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"1.0.0.0", "AU"},
+		{"1.0.0.255", "AU"},
+		{"1.0.2.7", "CN"},
+		{"2.16.5.1", "DE"},
+		{"2.16.6.0", ""},
+		{"0.255.255.255", ""},
+		{"::ffff:2.16.0.1", "DE"},
+		{"2001:db8::1", "FR"},
+		{"2001:db9::", ""},
+	}
+
+	for _, tt := range tests {
+		if got := db.Country(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Country(%s) = %q; want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestLoadRejectsBadRanges(t *testing.T) {
+	for _, csv := range []string{
+		"1.0.0.0,1.0.0.255\n",
+		"1.0.0.9,1.0.0.1,AU\n",
+		"1.0.0.0,2001:db8::,AU\n",
+		"1.0.0.0,1.0.0.255,AU\n1.0.0.128,1.0.1.0,CN\n",
+		"localhost,1.0.0.255,AU\n",
+	} {
+		if _, err := Load(strings.NewReader(csv)); err == nil {
+			t.Errorf("Load(%q) succeeded; want an error", csv)
+		}
+	}
+}
@@ -13,8 +13,9 @@ import (
 var templateFS embed.FS
 
 type Mailer struct {
-	dialer *mail.Dialer
-	sender string
+	dialer      *mail.Dialer
+	sender      string
+	credentials func() (username, password string)
 }
 
 func New(host string, port int, username, password, sender string) Mailer {
@@ -27,6 +28,13 @@ func New(host string, port int, username, password, sender string) Mailer {
 	}
 }
 
+// WithCredentials returns a copy of m that looks up the SMTP username and
+// password before each send, so rotated credentials take effect immediately.
+func (m Mailer) WithCredentials(credentials func() (username, password string)) Mailer {
+	m.credentials = credentials
+	return m
+}
+
 func (m Mailer) Send(recipient, templateFile string, data interface{}) error {
 	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
 	if err != nil {
@@ -58,7 +66,14 @@ func (m Mailer) Send(recipient, templateFile string, data interface{}) error {
 	msg.SetBody("text/plain", plainBody.String())
 	msg.AddAlternative("text/html", htmlBody.String())
 
-	err = m.dialer.DialAndSend(msg)
+	dialer := m.dialer
+	if m.credentials != nil {
+		d := *m.dialer
+		d.Username, d.Password = m.credentials()
+		dialer = &d
+	}
+
+	err = dialer.DialAndSend(msg)
 	if err != nil {
 		return err
 	}
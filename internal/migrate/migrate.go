@@ -0,0 +1,251 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+var (
+	ErrDirty     = errors.New("database is in a dirty state, fix it manually and use force")
+	ErrNoVersion = errors.New("no migration with that version")
+
+	filenameRX = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+)
+
+type Migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+// Migrator applies the SQL files in the migrations directory. It records its
+// progress in the same schema_migrations table as the golang-migrate CLI, so
+// the two can be used interchangeably against the same database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []*Migration
+}
+
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, entry := range entries {
+		matches := filenameRX.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		contents, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		}
+
+		if matches[3] == "up" {
+			migration.up = string(contents)
+		} else {
+			migration.down = string(contents)
+		}
+	}
+
+	m := &Migrator{db: db}
+	for _, migration := range byVersion {
+		m.migrations = append(m.migrations, migration)
+	}
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+
+	return m, nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint NOT NULL PRIMARY KEY,
+		dirty boolean NOT NULL
+	)`)
+	return err
+}
+
+// Version returns the currently applied migration version, or 0 if no
+// migrations have been applied yet.
+func (m *Migrator) Version(ctx context.Context) (int64, bool, error) {
+	err := m.ensureTable(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var version int64
+	var dirty bool
+
+	err = m.db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+
+	return version, dirty, err
+}
+
+// Up applies up to steps pending migrations, or all of them if steps is 0.
+func (m *Migrator) Up(ctx context.Context, steps int) ([]*Migration, error) {
+	current, dirty, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if dirty {
+		return nil, ErrDirty
+	}
+
+	var applied []*Migration
+
+	for _, migration := range m.migrations {
+		if migration.Version <= current {
+			continue
+		}
+		if steps > 0 && len(applied) == steps {
+			break
+		}
+
+		err := m.apply(ctx, migration.up, migration.Version)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// Down rolls back up to steps applied migrations, or all of them if steps is 0.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	current, dirty, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if dirty {
+		return nil, ErrDirty
+	}
+
+	var reverted []*Migration
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+
+		if migration.Version > current {
+			continue
+		}
+		if steps > 0 && len(reverted) == steps {
+			break
+		}
+
+		var previous int64
+		if i > 0 {
+			previous = m.migrations[i-1].Version
+		}
+
+		err := m.apply(ctx, migration.down, previous)
+		if err != nil {
+			return reverted, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		reverted = append(reverted, migration)
+	}
+
+	return reverted, nil
+}
+
+// Force sets the recorded version without running any migrations and clears
+// the dirty flag. It is used to recover after a failed migration has been
+// fixed by hand.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return ErrNoVersion
+	}
+
+	err := m.ensureTable(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = setVersion(ctx, tx, version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, statements string, version int64) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if statements != "" {
+		_, err = tx.ExecContext(ctx, statements)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = setVersion(ctx, tx, version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func setVersion(ctx context.Context, tx *sql.Tx, version int64) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+
+	if version == 0 {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, version)
+	return err
+}
@@ -0,0 +1,186 @@
+// Package openapi loads the API's OpenAPI document and validates JSON values
+// against the schemas it declares. Only the subset of JSON Schema that the
+// document actually uses is supported.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+//go:embed openapi.json
+var document []byte
+
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]json.RawMessage `json:"securitySchemes,omitempty"`
+}
+
+// PathItem maps a lower-case HTTP method to the operation it performs.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Cases       []*Case               `json:"x-contract-cases,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Case is an example exchange used by the contract tests. Params, Query and
+// Body may contain placeholders such as "$movie" or "$operation" which the test
+// fills in with fixtures. Auth is one of none, user, inactive or admin.
+type Case struct {
+	Name   string            `json:"name"`
+	Auth   string            `json:"auth,omitempty"`
+	Params map[string]string `json:"params,omitempty"`
+	Query  string            `json:"query,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+	Status int               `json:"status"`
+}
+
+// Route identifies a single operation in the document.
+type Route struct {
+	Method    string
+	Path      string
+	Operation *Operation
+}
+
+// Load parses the embedded OpenAPI document.
+func Load() (*Document, error) {
+	var doc Document
+
+	err := json.Unmarshal(document, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &doc, nil
+}
+
+// Raw returns the embedded document as JSON.
+func Raw() []byte {
+	return document
+}
+
+// Routes returns every operation in the document, sorted by path and method.
+func (d *Document) Routes() []Route {
+	var routes []Route
+
+	for path, item := range d.Paths {
+		for method, op := range item {
+			routes = append(routes, Route{Method: strings.ToUpper(method), Path: path, Operation: op})
+		}
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	return routes
+}
+
+// FindRoute returns the operation matching a request method and URL path,
+// along with the values of any path parameters. When several templates match,
+// the one with the fewest parameters wins, so static segments take precedence.
+func (d *Document) FindRoute(method, path string) (*Route, map[string]string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	var route *Route
+	var routeParams map[string]string
+
+	for template, item := range d.Paths {
+		op, ok := item[strings.ToLower(method)]
+		if !ok {
+			continue
+		}
+
+		params, ok := matchTemplate(template, segments)
+		if ok && (route == nil || len(params) < len(routeParams)) {
+			route = &Route{Method: strings.ToUpper(method), Path: template, Operation: op}
+			routeParams = params
+		}
+	}
+
+	return route, routeParams
+}
+
+// Expand substitutes path parameters into a path template.
+func Expand(template string, params map[string]string) string {
+	for name, value := range params {
+		template = strings.ReplaceAll(template, "{"+name+"}", value)
+	}
+	return template
+}
+
+func matchTemplate(template string, segments []string) (map[string]string, bool) {
+	parts := strings.Split(strings.Trim(template, "/"), "/")
+	if len(parts) != len(segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+
+	for i, part := range parts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			params[part[1:len(part)-1]] = segments[i]
+			continue
+		}
+		if part != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// JSONSchema returns the schema for a JSON request or response body, or nil
+// if the body is not documented.
+func JSONSchema(content map[string]MediaType) *Schema {
+	if mt, ok := content["application/json"]; ok {
+		return mt.Schema
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var emailRX = regexp.MustCompile(`^[^@\s]+@[^@\s]+$`)
+
+// patterns caches compiled pattern keywords by their source.
+var patterns sync.Map
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Additional        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	UniqueItems          bool               `json:"uniqueItems,omitempty"`
+}
+
+// Additional holds the value of additionalProperties, which may be either a
+// boolean or a schema for the extra values.
+type Additional struct {
+	Allowed bool
+	Schema  *Schema
+}
+
+func (a *Additional) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &a.Allowed); err == nil {
+		return nil
+	}
+
+	a.Allowed = true
+	return json.Unmarshal(b, &a.Schema)
+}
+
+func (a Additional) MarshalJSON() ([]byte, error) {
+	if a.Schema != nil {
+		return json.Marshal(a.Schema)
+	}
+	return json.Marshal(a.Allowed)
+}
+
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Resolve follows a "#/components/schemas/..." reference.
+func (d *Document) Resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// Validate checks a decoded JSON value against a schema. Objects may only
+// contain the properties their schema declares unless additionalProperties
+// allows more, so undocumented fields are reported as errors.
+func (d *Document) Validate(s *Schema, value interface{}) []ValidationError {
+	var errs []ValidationError
+	d.validate(s, value, "", &errs)
+	return errs
+}
+
+func (d *Document) validate(s *Schema, value interface{}, path string, errs *[]ValidationError) {
+	s = d.Resolve(s)
+	if s == nil {
+		return
+	}
+
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil {
+		if !s.Nullable && s.Type != "" {
+			fail("must not be null")
+		}
+		return
+	}
+
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, option := range s.OneOf {
+			if len(d.Validate(option, value)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			fail("must match exactly one of the allowed schemas")
+		}
+		return
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("must be one of %v", s.Enum)
+			return
+		}
+	}
+
+	switch s.Type {
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		n := len([]rune(str))
+		if s.MinLength != nil && n < *s.MinLength {
+			fail("must be at least %d characters long", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("must not be more than %d characters long", *s.MaxLength)
+		}
+		d.validateFormat(s.Format, str, fail)
+		if s.Pattern != "" {
+			rx, ok := patterns.Load(s.Pattern)
+			if !ok {
+				rx, _ = patterns.LoadOrStore(s.Pattern, regexp.MustCompile(s.Pattern))
+			}
+			if !rx.(*regexp.Regexp).MatchString(str) {
+				fail("must match the pattern %s", s.Pattern)
+			}
+		}
+
+	case "integer", "number":
+		num, ok := value.(float64)
+		if !ok {
+			fail("must be a %s", s.Type)
+			return
+		}
+		if s.Type == "integer" && num != math.Trunc(num) {
+			fail("must be an integer")
+		}
+		if s.Minimum != nil && num < *s.Minimum {
+			fail("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && num > *s.Maximum {
+			fail("must be at most %v", *s.Maximum)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+		}
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			fail("must contain at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			fail("must not contain more than %d items", *s.MaxItems)
+		}
+		if s.UniqueItems {
+			seen := make(map[string]bool)
+			for _, item := range items {
+				key := fmt.Sprint(item)
+				if seen[key] {
+					fail("must not contain duplicate items")
+					break
+				}
+				seen[key] = true
+			}
+		}
+		for i, item := range items {
+			d.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				*errs = append(*errs, ValidationError{Path: joinPath(path, name), Message: "must be provided"})
+			}
+		}
+
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if prop, ok := s.Properties[name]; ok {
+				d.validate(prop, obj[name], joinPath(path, name), errs)
+				continue
+			}
+
+			switch {
+			case s.AdditionalProperties == nil || !s.AdditionalProperties.Allowed:
+				*errs = append(*errs, ValidationError{Path: joinPath(path, name), Message: "is not a documented field"})
+			case s.AdditionalProperties.Schema != nil:
+				d.validate(s.AdditionalProperties.Schema, obj[name], joinPath(path, name), errs)
+			}
+		}
+	}
+}
+
+// ValidateParameter checks the raw string value of a query or path parameter
+// against the parameter's schema, converting it to a number or boolean first
+// if the schema calls for one.
+func (d *Document) ValidateParameter(p *Parameter, raw string) []ValidationError {
+	s := d.Resolve(p.Schema)
+	if s == nil {
+		return nil
+	}
+
+	var value interface{} = raw
+
+	switch s.Type {
+	case "integer", "number":
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return []ValidationError{{Path: p.Name, Message: "must be a " + s.Type}}
+		}
+		value = n
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return []ValidationError{{Path: p.Name, Message: "must be a boolean"}}
+		}
+		value = b
+	}
+
+	var errs []ValidationError
+	d.validate(s, value, p.Name, &errs)
+	return errs
+}
+
+func (d *Document) validateFormat(format, value string, fail func(string, ...interface{})) {
+	switch format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			fail("must be an RFC 3339 date-time")
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			fail("must be a date in YYYY-MM-DD format")
+		}
+	case "email":
+		if !emailRX.MatchString(value) {
+			fail("must be a valid email address")
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,162 @@
+// Package secrets resolves configuration values that refer to secrets held
+// in HashiCorp Vault or AWS Secrets Manager, so that credentials do not have
+// to be passed as plaintext flags.
+//
+// A reference has the form scheme://path#key, for example
+// "vault://secret/greenlight#db_dsn" or "awssm://prod/greenlight#smtp_password".
+// The key selects a field of a secret holding a JSON object and may be omitted
+// for AWS secrets stored as a plain string. Any other value is returned as-is.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNotConfigured = errors.New("secrets provider is not configured")
+
+// Provider fetches secrets from one backend.
+type Provider interface {
+	// Fetch returns the secret at path. If key is not empty the secret must
+	// be a JSON object and the string value of that field is returned.
+	Fetch(ctx context.Context, path, key string) (string, error)
+}
+
+// Resolver resolves secret references using the provider registered for each
+// scheme.
+type Resolver struct {
+	providers map[string]Provider
+}
+
+func NewResolver(providers map[string]Provider) *Resolver {
+	return &Resolver{providers: providers}
+}
+
+// FromEnv returns a resolver for the "vault" and "awssm" schemes, configured
+// from the standard VAULT_ADDR, VAULT_TOKEN and AWS_* environment variables.
+func FromEnv() *Resolver {
+	return NewResolver(map[string]Provider{
+		"vault": VaultFromEnv(),
+		"awssm": AWSFromEnv(),
+	})
+}
+
+// IsReference reports whether value refers to a secret.
+func (r *Resolver) IsReference(value string) bool {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return false
+	}
+	_, ok = r.providers[scheme]
+	return ok
+}
+
+// Resolve returns the secret a reference points to, or value itself if it is
+// not a reference.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	scheme, rest, ok := strings.Cut(value, "://")
+	if !ok {
+		return value, nil
+	}
+
+	provider, ok := r.providers[scheme]
+	if !ok {
+		return value, nil
+	}
+
+	path, key, _ := strings.Cut(rest, "#")
+
+	secret, err := provider.Fetch(ctx, path, key)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s://%s: %w", scheme, path, err)
+	}
+
+	return secret, nil
+}
+
+// Value is a resolved configuration value that can be refreshed while the
+// application is running.
+type Value struct {
+	ref     string
+	mu      sync.RWMutex
+	current string
+}
+
+// Value resolves ref and returns it as a refreshable Value.
+func (r *Resolver) Value(ctx context.Context, ref string) (*Value, error) {
+	current, err := r.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Value{ref: ref, current: current}, nil
+}
+
+func (v *Value) Get() string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	return v.current
+}
+
+// Refresh re-resolves values that are secret references every interval until
+// ctx is done. A value that fails to refresh keeps its previous contents and
+// the error is passed to onError.
+func (r *Resolver) Refresh(ctx context.Context, interval time.Duration, onError func(error), values ...*Value) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, v := range values {
+			if !r.IsReference(v.ref) {
+				continue
+			}
+
+			fetchCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			current, err := r.Resolve(fetchCtx, v.ref)
+			cancel()
+
+			if err != nil {
+				onError(err)
+				continue
+			}
+
+			v.mu.Lock()
+			v.current = current
+			v.mu.Unlock()
+		}
+	}
+}
+
+// field extracts a string field from a JSON object secret.
+func field(secret []byte, key string) (string, error) {
+	var fields map[string]interface{}
+
+	err := json.Unmarshal(secret, &fields)
+	if err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %w", err)
+	}
+
+	value, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no field %q", key)
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("secret field %q is not a string", key)
+	}
+
+	return s, nil
+}
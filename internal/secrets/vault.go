@@ -0,0 +1,79 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Vault reads secrets from a HashiCorp Vault KV version 2 engine. Paths start
+// with the engine's mount point, as in "secret/greenlight".
+type Vault struct {
+	Addr   string
+	Token  string
+	Client *http.Client
+}
+
+func VaultFromEnv() *Vault {
+	return &Vault{
+		Addr:   os.Getenv("VAULT_ADDR"),
+		Token:  os.Getenv("VAULT_TOKEN"),
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (v *Vault) Fetch(ctx context.Context, path, key string) (string, error) {
+	if v.Addr == "" || v.Token == "" {
+		return "", ErrNotConfigured
+	}
+
+	mount, rest, ok := strings.Cut(strings.Trim(path, "/"), "/")
+	if !ok {
+		return "", fmt.Errorf("vault path %q must include the mount point", path)
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimRight(v.Addr, "/"), mount, rest)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+
+	rs, err := v.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer rs.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(rs.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+
+	if rs.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s", rs.Status)
+	}
+
+	var response struct {
+		Data struct {
+			Data json.RawMessage `json:"data"`
+		} `json:"data"`
+	}
+
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return "", err
+	}
+
+	if key == "" {
+		return string(response.Data.Data), nil
+	}
+
+	return field(response.Data.Data, key)
+}
@@ -0,0 +1,202 @@
+// Package testutil provides helpers for integration tests that need a real
+// PostgreSQL database and a running instance of the API.
+package testutil
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/migrate"
+	"github.com/levisthors/greenlight/migrations"
+	_ "github.com/lib/pq"
+)
+
+// DSNEnv names the environment variable holding a DSN for an existing
+// PostgreSQL server. The role must be allowed to create databases. When it is
+// unset the helpers try to start a throwaway server with docker instead.
+const DSNEnv = "GREENLIGHT_TEST_DB_DSN"
+
+const postgresImage = "postgres:16-alpine"
+
+var server struct {
+	once      sync.Once
+	dsn       string
+	container string
+	err       error
+}
+
+// Main runs the tests in a package and then removes the docker container
+// started for them, if any. Packages using NewDB should call it from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(testutil.Main(m))
+//	}
+func Main(m *testing.M) int {
+	code := m.Run()
+
+	if server.container != "" {
+		exec.Command("docker", "rm", "-f", server.container).Run()
+	}
+
+	return code
+}
+
+// NewDB creates an empty database with every migration applied and returns a
+// connection pool for it. The database is dropped when the test finishes. If
+// no PostgreSQL server is available the test is skipped.
+func NewDB(t testing.TB) *sql.DB {
+	t.Helper()
+
+	adminDSN, err := serverDSN()
+	if err != nil {
+		t.Skipf("no PostgreSQL server for integration tests: %s", err)
+	}
+
+	admin, err := sql.Open("postgres", adminDSN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	name := "greenlight_test_" + randomHex(6)
+
+	_, err = admin.Exec("CREATE DATABASE " + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dsn, err := withDatabase(adminDSN, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+
+		admin, err := sql.Open("postgres", adminDSN)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer admin.Close()
+
+		_, err = admin.Exec("DROP DATABASE IF EXISTS " + name + " WITH (FORCE)")
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err = db.ExecContext(ctx, "CREATE EXTENSION IF NOT EXISTS citext")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = m.Up(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func serverDSN() (string, error) {
+	server.once.Do(func() {
+		if dsn := os.Getenv(DSNEnv); dsn != "" {
+			server.dsn = dsn
+		} else {
+			server.dsn, server.err = startContainer()
+		}
+
+		if server.err == nil {
+			server.err = waitForServer(server.dsn, 30*time.Second)
+		}
+	})
+
+	return server.dsn, server.err
+}
+
+func startContainer() (string, error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return "", fmt.Errorf("%s is not set and docker is not installed", DSNEnv)
+	}
+
+	password := randomHex(12)
+
+	out, err := exec.Command("docker", "run", "--rm", "-d",
+		"-e", "POSTGRES_PASSWORD="+password,
+		"-p", "127.0.0.1::5432",
+		postgresImage).Output()
+	if err != nil {
+		return "", fmt.Errorf("starting %s: %w", postgresImage, err)
+	}
+	server.container = strings.TrimSpace(string(out))
+
+	out, err = exec.Command("docker", "port", server.container, "5432/tcp").Output()
+	if err != nil {
+		return "", err
+	}
+	hostPort := strings.TrimSpace(strings.Split(string(out), "\n")[0])
+
+	return fmt.Sprintf("postgres://postgres:%s@%s/postgres?sslmode=disable", password, hostPort), nil
+}
+
+func waitForServer(dsn string, timeout time.Duration) error {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err = db.PingContext(ctx)
+		cancel()
+
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+func withDatabase(dsn, name string) (string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", err
+	}
+
+	u.Path = "/" + name
+	return u.String(), nil
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
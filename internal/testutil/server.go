@@ -0,0 +1,130 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/levisthors/greenlight/internal/data"
+)
+
+type TestServer struct {
+	*httptest.Server
+}
+
+// NewServer starts an HTTP test server for the given handler, which will
+// usually be the full application router. It is closed when the test ends.
+func NewServer(t testing.TB, h http.Handler) *TestServer {
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+
+	return &TestServer{ts}
+}
+
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Decode unmarshals the response body into dst, failing the test if the body
+// is not valid JSON.
+func (r Response) Decode(t testing.TB, dst interface{}) {
+	t.Helper()
+
+	err := json.Unmarshal(r.Body, dst)
+	if err != nil {
+		t.Fatalf("decoding response body %q: %s", r.Body, err)
+	}
+}
+
+// Do sends a request to the test server. A non-empty token is sent as a
+// bearer token, and a non-nil body is encoded as JSON.
+func (ts *TestServer) Do(t testing.TB, method, path, token string, body interface{}) Response {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, ts.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	respBody, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Response{Status: rs.StatusCode, Header: rs.Header, Body: bytes.TrimSpace(respBody)}
+}
+
+func (ts *TestServer) Get(t testing.TB, path, token string) Response {
+	t.Helper()
+	return ts.Do(t, http.MethodGet, path, token, nil)
+}
+
+var userCount atomic.Int64
+
+// CreateUser inserts a user with the given permissions and returns it along
+// with a plaintext authentication token that can be passed to Do.
+func CreateUser(t testing.TB, models data.Models, activated bool, permissions ...string) (*data.User, string) {
+	t.Helper()
+
+	n := userCount.Add(1)
+
+	user := &data.User{
+		Name:      fmt.Sprintf("Test User %d", n),
+		Email:     fmt.Sprintf("user%d@example.com", n),
+		Activated: activated,
+	}
+
+	err := user.Password.Set("pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = models.Users.Insert(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(permissions) > 0 {
+		err = models.Permissions.AddForUser(user.ID, permissions...)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	token, err := models.Tokens.New(user.ID, time.Hour, data.ScopeAuthentication)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user, token.Plaintext
+}
@@ -2,6 +2,7 @@ package validator
 
 import (
 	"regexp"
+	"unicode/utf8"
 )
 
 // Declare a regular expression for sanity checking the format of email addresses (we'll
@@ -57,3 +58,20 @@ func Unique(values []string) bool {
 	}
 	return len(values) == len(uniqueValues)
 }
+
+// All reports whether ok is true for every value.
+func All(values []string, ok func(string) bool) bool {
+	for _, value := range values {
+		if !ok(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// RuneCountBetween reports whether value has between min and max characters,
+// inclusive.
+func RuneCountBetween(value string, min, max int) bool {
+	n := utf8.RuneCountInString(value)
+	return n >= min && n <= max
+}
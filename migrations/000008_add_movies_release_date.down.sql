@@ -0,0 +1,6 @@
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_year_check;
+ALTER TABLE movies ADD CONSTRAINT movies_year_check CHECK (year BETWEEN 1888 AND date_part('year', now())) NOT VALID;
+
+DROP INDEX IF EXISTS movie_release_date_idx;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS release_date;
@@ -0,0 +1,8 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS release_date date;
+
+CREATE INDEX IF NOT EXISTS movie_release_date_idx ON movies (release_date);
+
+-- Announced movies can have a future year, so the upper bound is now
+-- enforced by the application using the configurable release date horizon.
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_year_check;
+ALTER TABLE movies ADD CONSTRAINT movies_year_check CHECK (year >= 1888);
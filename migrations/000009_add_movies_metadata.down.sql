@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS movie_country_idx;
+DROP INDEX IF EXISTS movie_original_language_idx;
+
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_certification_check;
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_box_office_check;
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_budget_check;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS certification;
+ALTER TABLE movies DROP COLUMN IF EXISTS box_office;
+ALTER TABLE movies DROP COLUMN IF EXISTS budget;
+ALTER TABLE movies DROP COLUMN IF EXISTS country;
+ALTER TABLE movies DROP COLUMN IF EXISTS original_language;
+ALTER TABLE movies DROP COLUMN IF EXISTS synopsis;
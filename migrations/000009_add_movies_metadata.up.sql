@@ -0,0 +1,13 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS synopsis text NOT NULL DEFAULT '';
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS original_language text NOT NULL DEFAULT '';
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS country text NOT NULL DEFAULT '';
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS budget bigint NOT NULL DEFAULT 0;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS box_office bigint NOT NULL DEFAULT 0;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS certification text NOT NULL DEFAULT '';
+
+ALTER TABLE movies ADD CONSTRAINT movies_budget_check CHECK (budget >= 0);
+ALTER TABLE movies ADD CONSTRAINT movies_box_office_check CHECK (box_office >= 0);
+ALTER TABLE movies ADD CONSTRAINT movies_certification_check CHECK (certification IN ('', 'G', 'PG', 'PG-13', 'R', 'NC-17', 'NR'));
+
+CREATE INDEX IF NOT EXISTS movie_original_language_idx ON movies (original_language);
+CREATE INDEX IF NOT EXISTS movie_country_idx ON movies (country);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS movie_series_idx;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS series_order;
+ALTER TABLE movies DROP COLUMN IF EXISTS series_id;
+
+DROP TABLE IF EXISTS series;
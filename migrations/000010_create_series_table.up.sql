@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS series (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS series_id bigint REFERENCES series ON DELETE SET NULL;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS series_order integer NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS movie_series_idx ON movies (series_id, series_order);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS movie_availability;
+DROP TABLE IF EXISTS providers;
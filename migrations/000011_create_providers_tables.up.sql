@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS providers (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name citext UNIQUE NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS movie_availability (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    provider_id bigint NOT NULL REFERENCES providers ON DELETE CASCADE,
+    region text NOT NULL,
+    type text NOT NULL CHECK (type IN ('stream', 'rent', 'buy')),
+    url text NOT NULL,
+    PRIMARY KEY (movie_id, provider_id, region, type)
+);
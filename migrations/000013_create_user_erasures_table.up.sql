@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS user_erasures (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    requested_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    scheduled_for timestamp(0) with time zone NOT NULL,
+    cancelled_at timestamp(0) with time zone,
+    completed_at timestamp(0) with time zone,
+    summary jsonb
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS user_erasures_pending_idx ON user_erasures (user_id)
+    WHERE cancelled_at IS NULL AND completed_at IS NULL;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS erased_at timestamp(0) with time zone;
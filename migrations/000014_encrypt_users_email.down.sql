@@ -0,0 +1,8 @@
+-- Encrypted addresses cannot be decrypted in SQL; rolling back leaves them as
+-- ciphertext, so this is only safe before any have been written.
+ALTER TABLE users ALTER COLUMN email TYPE citext;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+
+DROP INDEX IF EXISTS users_email_hash_key;
+
+ALTER TABLE users DROP COLUMN IF EXISTS email_hash;
@@ -0,0 +1,10 @@
+-- Email addresses are encrypted by the application, so uniqueness and lookups
+-- move to email_hash, a keyed hash of the lower-cased address. Existing rows
+-- keep their plaintext address and a NULL hash until "greenlight keys rotate"
+-- encrypts them.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_hash bytea;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_hash_key ON users (email_hash);
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+ALTER TABLE users ALTER COLUMN email TYPE text;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS ip_rules (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    cidr cidr NOT NULL,
+    list text NOT NULL CHECK (list IN ('admin_allow', 'deny')),
+    note text NOT NULL DEFAULT '',
+    UNIQUE (cidr, list)
+);
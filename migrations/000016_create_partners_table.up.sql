@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS partners (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    key_id text UNIQUE NOT NULL,
+    secret text NOT NULL,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE
+);
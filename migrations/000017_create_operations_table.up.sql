@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS operations (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    kind text NOT NULL,
+    status text NOT NULL CHECK (status IN ('pending', 'running', 'succeeded', 'failed')),
+    progress integer NOT NULL DEFAULT 0 CHECK (progress BETWEEN 0 AND 100),
+    result jsonb NOT NULL DEFAULT '{}',
+    errors text[] NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS operations_unfinished_idx ON operations (status) WHERE status IN ('pending', 'running');
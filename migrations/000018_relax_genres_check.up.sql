@@ -0,0 +1,6 @@
+-- The maximum number of genres is now configurable and enforced by the
+-- application. array_length() is NULL for an empty array, which let movies
+-- with no genres through, so cardinality() is used instead.
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS genres_length_check;
+
+ALTER TABLE movies ADD CONSTRAINT genres_length_check CHECK (cardinality(genres) >= 1);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS usage (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    client text NOT NULL,
+    day date NOT NULL,
+    requests bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, client, day)
+);
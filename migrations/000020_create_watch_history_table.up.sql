@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS watch_history (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    watched_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    progress integer CHECK (progress >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS watch_history_user_movie_idx ON watch_history (user_id, movie_id, watched_at DESC);
@@ -0,0 +1,3 @@
+-- NULL means the user has not chosen; -analytics-consent decides what that
+-- means.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS analytics_consent boolean;
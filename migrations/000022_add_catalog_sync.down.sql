@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS sync_cursors;
+ALTER TABLE movies DROP COLUMN IF EXISTS external_id;
@@ -0,0 +1,10 @@
+-- Movies synced from an upstream catalogue are matched on the upstream's ID.
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS external_id text UNIQUE;
+
+-- The last batch applied from each upstream source, so that it can resume
+-- after a failure and replayed batches are recognised.
+CREATE TABLE IF NOT EXISTS sync_cursors (
+    source text PRIMARY KEY,
+    cursor bigint NOT NULL DEFAULT 0,
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
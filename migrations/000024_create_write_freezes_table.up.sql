@@ -0,0 +1,6 @@
+-- Write endpoints that are temporarily read-only, by route name.
+CREATE TABLE IF NOT EXISTS write_freezes (
+    route text PRIMARY KEY,
+    message text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
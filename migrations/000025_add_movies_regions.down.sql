@@ -0,0 +1,2 @@
+ALTER TABLE movies DROP COLUMN IF EXISTS blocked_regions;
+ALTER TABLE movies DROP COLUMN IF EXISTS allowed_regions;
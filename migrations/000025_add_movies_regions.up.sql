@@ -0,0 +1,4 @@
+-- A movie with allowed regions is only shown in those regions; one with
+-- blocked regions is shown everywhere else.
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS allowed_regions text[] NOT NULL DEFAULT '{}';
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS blocked_regions text[] NOT NULL DEFAULT '{}';
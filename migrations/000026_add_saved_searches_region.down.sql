@@ -0,0 +1 @@
+ALTER TABLE saved_searches DROP COLUMN IF EXISTS region;
@@ -0,0 +1,4 @@
+-- The region the search was saved from, which limits the movies it is
+-- notified about. Searches saved before this are treated as from an unknown
+-- region.
+ALTER TABLE saved_searches ADD COLUMN IF NOT EXISTS region text NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+-- The download links cannot be put back.
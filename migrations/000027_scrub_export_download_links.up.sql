@@ -0,0 +1,3 @@
+-- Export operations used to record the download link, token included, in
+-- their result. Drop it from the ones already stored.
+UPDATE operations SET result = result - 'download' WHERE kind = 'export';
@@ -0,0 +1,3 @@
+-- The process running each operation, which keeps updated_at fresh while it
+-- works so that other processes can tell live operations from abandoned ones.
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS owner text NOT NULL DEFAULT '';
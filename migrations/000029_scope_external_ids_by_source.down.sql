@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS movies_source_external_id_key;
+ALTER TABLE movies ADD CONSTRAINT movies_external_id_key UNIQUE (external_id);
+ALTER TABLE movies DROP COLUMN IF EXISTS source;
@@ -0,0 +1,11 @@
+-- Upstream IDs are only unique within the source they came from, so synced
+-- movies record their source and are matched on both. Movies synced before
+-- this can only be attributed when a single source has ever synced; others
+-- keep a NULL source and are no longer touched by any source.
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS source text;
+
+UPDATE movies SET source = (SELECT min(source) FROM sync_cursors)
+WHERE external_id IS NOT NULL AND (SELECT count(*) FROM sync_cursors) = 1;
+
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_external_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS movies_source_external_id_key ON movies (source, external_id);